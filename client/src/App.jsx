@@ -17,6 +17,7 @@ import Login from "./pages/Auth/Login";
 import Profile from "./pages/Auth/Profile";
 import AccountPage from "./pages/AccountPage/AccountPage";
 import CheckoutPage from "./pages/CheckoutPage/CheckoutPage";
+import TrackOrderPage from "./pages/TrackOrderPage/TrackOrderPage";
 
 import AdminApp from "./admin/AdminApp";
 
@@ -44,6 +45,7 @@ function App() {
               <Route path="/product/:slug" element={<ProductPage />} />
               {/* <Route path="/cart" element={<CartPage />} /> */}
               <Route path="/checkout" element={<CheckoutPage />} />
+              <Route path="/track" element={<TrackOrderPage />} />
 
               <Route path="/register" element={<Register />} />
               <Route path="/login" element={<Login />} />
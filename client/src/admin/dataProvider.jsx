@@ -53,6 +53,16 @@ const uploadImage = async (file) => {
   return response.json();
 };
 
+// stocul se trimite doar dacă a fost modificat în formular, împreună cu valoarea
+// de la care a pornit adminul; serverul refuză (409) dacă între timp s-a schimbat
+const withExpectedStock = (data, previous) => {
+  if (!previous || data.stock_quantity === previous.stock_quantity) {
+    delete data.stock_quantity;
+    return;
+  }
+  data.expected_stock_quantity = previous.stock_quantity;
+};
+
 export const dataProvider = {
   ...baseDataProvider,
 
@@ -103,6 +113,17 @@ export const dataProvider = {
     const data = { ...params.data };
 
     if (resource === "products") {
+      withExpectedStock(data, params.previousData);
+      if (Array.isArray(data.variants)) {
+        const previousVariants = params.previousData?.variants || [];
+        data.variants = data.variants.map((variant) => {
+          const copy = { ...variant };
+          if (copy.id) {
+            withExpectedStock(copy, previousVariants.find((v) => v.id === copy.id));
+          }
+          return copy;
+        });
+      }
       if (data.category?.id) {
         data.category_id = data.category.id;
         delete data.category;
@@ -6,13 +6,17 @@ import {
   EditButton,
   FunctionField,
   DeleteButton,
+  TextInput,
 } from "react-admin";
 
+const orderFilters = [<TextInput key="reference" source="reference" alwaysOn />];
+
 export default function OrdersList(props) {
   return (
-    <List {...props}>
+    <List {...props} filters={orderFilters}>
       <Datagrid rowClick="edit">
         <TextField source="id" />
+        <TextField source="reference" />
         <FunctionField
           label="Customer"
           render={(record) => record.user?.name || record.name}
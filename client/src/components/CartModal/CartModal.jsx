@@ -82,9 +82,7 @@ export default function CartModal() {
                 const productImage = getProductImage(item);
                 const productName =
                   item.product?.name || `Produs #${item.productId}`;
-                const productPrice = (
-                  (item.product?.price_cents ?? 0) / 100
-                ).toFixed(2);
+                const productPrice = (item.unit_price_cents / 100).toFixed(2);
 
                 return (
                   <li key={itemId} className="cartItem">
@@ -98,6 +96,15 @@ export default function CartModal() {
                     <div className="cartItemDetails">
                       <h5>{productName}</h5>
                       <p>{productPrice} MDL</p>
+                      {item.status !== "ok" && (
+                        <p className="cartItemWarning">{item.message}</p>
+                      )}
+                      {item.price_changed && (
+                        <p className="cartItemWarning">
+                          Prețul s-a schimbat (era{" "}
+                          {(item.added_price_cents / 100).toFixed(2)} MDL)
+                        </p>
+                      )}
                       <div className="quantityContainer">
                         <button
                           className="quantityBtn"
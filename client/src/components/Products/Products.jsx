@@ -1,4 +1,4 @@
-import { useEffect, useState, useMemo } from "react";
+import { useEffect, useState } from "react";
 import { Link, useLocation } from "react-router-dom";
 import TabButton from "../TabButton";
 import { useCart } from "../../context/CartContext";
@@ -6,16 +6,20 @@ import { toast } from "react-toastify";
 import { getImageUrl } from "../Utils/image.js";
 import "react-toastify/dist/ReactToastify.css";
 import { API_URL } from "../../config/api";
-import { slugify } from "../Utils/utils";
+import { slugify, productPriceCents } from "../Utils/utils";
 import { useInView } from "react-intersection-observer";
 import "./products.scss";
 
+const PAGE_SIZE = 24;
+
 export default function Products({ selectedCategory, searchQuery }) {
   const { addItem } = useCart();
   const location = useLocation();
 
   const [activeCategory, setActiveCategory] = useState("canapele");
   const [products, setProducts] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
   const [loading, setLoading] = useState(false);
   // const [hoveredProductId, setHoveredProductId] = useState(false);
 
@@ -35,7 +39,12 @@ export default function Products({ selectedCategory, searchQuery }) {
     }
   }, [selectedCategory]);
 
-  // Fetch produse (global search + fallback)
+  // La schimbarea căutării sau a categoriei pornim de la prima pagină
+  useEffect(() => {
+    setPage(1);
+  }, [query, activeCategory]);
+
+  // Fetch produse (global search + catalog filtrat pe categorie, paginat)
   useEffect(() => {
     if (!inView) return;
 
@@ -45,15 +54,22 @@ export default function Products({ selectedCategory, searchQuery }) {
       try {
         setLoading(true);
         const url = query
-          ? `${API_URL}/api/search?query=${encodeURIComponent(query)}`
-          : `${API_URL}/api/products`;
+          ? `${API_URL}/api/search?query=${encodeURIComponent(query)}&page=${page}&per_page=${PAGE_SIZE}`
+          : `${API_URL}/api/products?category=${encodeURIComponent(activeCategory)}&page=${page}&per_page=${PAGE_SIZE}`;
 
         const res = await fetch(url, { signal: controller.signal });
         if (!res.ok) throw new Error();
         const data = await res.json();
-        console.log(data);
-        setProducts(Array.isArray(data) ? data : []);
-      } catch {
+        // /api/search întoarce { items, total, facets }, /api/products un array + X-Total-Count
+        const items = Array.isArray(data) ? data : data?.items || [];
+        const count = Array.isArray(data)
+          ? Number(res.headers.get("X-Total-Count") ?? items.length)
+          : data?.total ?? items.length;
+
+        setProducts((prev) => (page === 1 ? items : [...prev, ...items]));
+        setTotal(count);
+      } catch (err) {
+        if (err?.name === "AbortError") return;
         toast.error("Nu am putut încărca produsele.");
       } finally {
         setLoading(false);
@@ -62,17 +78,9 @@ export default function Products({ selectedCategory, searchQuery }) {
 
     loadProducts();
     return () => controller.abort();
-  }, [query, inView]);
-
-  // Filtrare produse
-  const displayedProducts = useMemo(() => {
-    if (query) return products;
+  }, [query, activeCategory, page, inView]);
 
-    return products.filter((product) => {
-      if (!product?.category?.name) return false;
-      return product.category.name.toLowerCase() === activeCategory;
-    });
-  }, [products, query, activeCategory]);
+  const hasMore = products.length < total;
 
   // handling hovering on product card
   // const handleMouseEnter = (id) => {
@@ -104,14 +112,14 @@ export default function Products({ selectedCategory, searchQuery }) {
       {/* Rezultate căutare */}
       {query && (
         <h3 className="mb-4 text-center">
-          Rezultate pentru: <strong>{query}</strong> ({displayedProducts.length}{" "}
+          Rezultate pentru: <strong>{query}</strong> ({total}{" "}
           produse)
         </h3>
       )}
 
       {/* Products Grid */}
       <div className="row row-cols-2 row-cols-sm-2 row-cols-md-4 g-4">
-        {displayedProducts.map((product) => (
+        {products.map((product) => (
           <Link
             key={product.id}
             to={`/product/${slugify(product.name)}-${product.id}`}
@@ -168,13 +176,31 @@ export default function Products({ selectedCategory, searchQuery }) {
               <div className="card-body">
                 <span className="card-title productName">{product.name}</span>
                 <span className="card-text productPrice">
-                  {((product.price_cents ?? 0) / 100).toFixed(2)} MDL
+                  {product.original_price_cents && (
+                    <s className="oldPrice me-2">
+                      {(product.original_price_cents / 100).toFixed(2)}
+                    </s>
+                  )}
+                  {(productPriceCents(product) / 100).toFixed(2)} MDL
                 </span>
               </div>
             </div>
           </Link>
         ))}
       </div>
+
+      {hasMore && (
+        <div className="text-center mt-4">
+          <button
+            type="button"
+            className="btn btn-outline-dark"
+            disabled={loading}
+            onClick={() => setPage((p) => p + 1)}
+          >
+            Încarcă mai multe
+          </button>
+        </div>
+      )}
     </div>
   );
 }
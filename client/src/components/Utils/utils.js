@@ -5,4 +5,8 @@ export function slugify(text) {
     .replace(/[\u0300-\u036f]/g, "")
     .replace(/[^a-z0-9]+/g, "-")
     .replace(/(^-|-$)+/g, "");
-}
\ No newline at end of file
+}
+// Prețul curent (cu reducerea activă, dacă există) în bani
+export function productPriceCents(product) {
+  return product?.effective_price_cents ?? product?.price_cents ?? 0;
+}
@@ -43,7 +43,6 @@ export function AuthProvider({ children }) {
       });
     } finally {
       setUser(null);
-      document.cookie = "guestCart=; path=/; max-age=0";
     }
   }
 
@@ -19,9 +19,9 @@ export function CartProvider({ children }) {
 
   const [isCartOpen, setIsCartOpen] = useState(false);
   const [cartItems, setCartItems] = useState([]);
+  const [cartSummary, setCartSummary] = useState(null);
   const [cartInitializing, setCartInitializing] = useState(false);
   const [cartVersion, setCartVersion] = useState(0);
-  const [migratingCart, setMigratingCart] = useState(false);
   const [initialized, setInitialized] = useState(false);
 
   // helpers
@@ -37,72 +37,20 @@ export function CartProvider({ children }) {
       return `server-${item.id || item.ID}`;
     }
 
-    if (item.productId) return `guest-${item.productId}`;
+    if (item.productId) {
+      return item.variantId
+        ? `guest-${item.productId}.${item.variantId}`
+        : `guest-${item.productId}`;
+    }
     if (item.tempId) return item.tempId;
 
     return null;
   };
 
-  // guest cart
-
-  const loadProductDetails = useCallback(async (items) => {
-    try {
-      return await Promise.all(
-        items.map(async (item) => {
-          if (item.product?.id || item.product?.ID) return item;
-
-          if (item.productId) {
-            const res = await fetch(
-              `${API_URL}/api/products/${item.productId}`
-            );
-            if (res.ok) {
-              return { ...item, product: await res.json() };
-            }
-          }
-          return item;
-        })
-      );
-    } catch {
-      return items;
-    }
-  }, []);
-
-  const getGuestCartFromCookie = useCallback(async () => {
-    try {
-      const raw = document.cookie
-        .split("; ")
-        .find((c) => c.startsWith("guestCart="))
-        ?.split("=")[1];
-
-      if (!raw) return [];
-      return await loadProductDetails(JSON.parse(decodeURIComponent(raw)));
-    } catch {
-      document.cookie = "guestCart=; path=/; max-age=0";
-      return [];
-    }
-  }, [loadProductDetails]);
-
-  const saveGuestCartToCookie = useCallback((items) => {
-    const minimal = items.map((i) => ({
-      productId: i.productId,
-      quantity: i.quantity,
-      tempId: i.tempId || `guest-${i.productId}`,
-    }));
-
-    document.cookie = `guestCart=${encodeURIComponent(
-      JSON.stringify(minimal)
-    )}; path=/; max-age=${30 * 24 * 60 * 60}; SameSite=Lax`;
-  }, []);
-
   // load cart
+  // Coșul oaspeților e ținut de server într-un cookie semnat, deci și el vine din API
 
   const loadCart = useCallback(async () => {
-    if (!user) {
-      const guestItems = await getGuestCartFromCookie();
-      setCartItems(guestItems);
-      return;
-    }
-
     setCartInitializing(true);
     try {
       const res = await fetch(`${API_URL}/api/cart`, {
@@ -110,45 +58,18 @@ export function CartProvider({ children }) {
       });
 
       if (!res.ok) throw new Error();
-      const items = await res.json();
-
-      setCartItems(
-        items.map((i) => ({
-          ...i,
-          id: i.id || i.ID,
-          productId: i.productId || i.product?.id || i.product?.ID,
-        }))
-      );
+      // serverul calculează prețurile și marchează liniile expirate
+      const summary = await res.json();
+
+      setCartSummary(summary);
+      setCartItems(summary.items);
     } catch {
+      setCartSummary(null);
       setCartItems([]);
     } finally {
       setCartInitializing(false);
     }
-  }, [user, getGuestCartFromCookie]);
-
-  // migration
-
-  const migrateGuestCartToServer = useCallback(async () => {
-    if (!user || migratingCart) return;
-
-    setMigratingCart(true);
-    const guestItems = await getGuestCartFromCookie();
-
-    for (const item of guestItems) {
-      await fetch(`${API_URL}/api/cart`, {
-        method: "POST",
-        credentials: "include",
-        headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({
-          productId: item.productId,
-          quantity: item.quantity,
-        }),
-      });
-    }
-
-    document.cookie = "guestCart=; path=/; max-age=0";
-    setMigratingCart(false);
-  }, [user, migratingCart, getGuestCartFromCookie]);
+  }, [user]);
 
   // effects
 
@@ -157,16 +78,10 @@ export function CartProvider({ children }) {
     loadCart();
   }, [loadCart, cartVersion, initialized]);
 
+  // la autentificare serverul mută coșul oaspetelui în cont
   useEffect(() => {
-    if (!user) return;
-    migrateGuestCartToServer().then(() => setCartVersion((v) => v + 1));
-  }, [user, migrateGuestCartToServer]);
-
-  useEffect(() => {
-    if (!user && !migratingCart) {
-      saveGuestCartToCookie(cartItems);
-    }
-  }, [cartItems, user, migratingCart, saveGuestCartToCookie]);
+    setCartVersion((v) => v + 1);
+  }, [user]);
 
   // ui actions
 
@@ -182,51 +97,40 @@ export function CartProvider({ children }) {
 
   // cart actions
 
-  const addItem = async (product, quantity = 1) => {
+  const addItem = async (product, quantity = 1, variantId) => {
     const productId = product?.id || product?.ID;
     if (!productId) return;
 
-    if (!user) {
-      setCartItems((prev) => {
-        const idx = prev.findIndex((i) => i.productId === productId);
-        if (idx !== -1) {
-          const copy = [...prev];
-          copy[idx] = { ...copy[idx], quantity: copy[idx].quantity + quantity };
-          return copy;
-        }
-        return [
-          ...prev,
-          {
-            productId,
-            quantity,
-            product,
-            tempId: `guest-${productId}`,
-            id: `guest-${productId}`,
-          },
-        ];
-      });
-      return;
-    }
-
-    await fetch(`${API_URL}/api/cart`, {
+    const res = await fetch(`${API_URL}/api/cart`, {
       method: "POST",
       credentials: "include",
       headers: { "Content-Type": "application/json" },
-      body: JSON.stringify({ productId, quantity }),
+      body: JSON.stringify({ productId, variantId, quantity }),
     });
+    if (!res.ok) {
+      throw new Error(await res.text());
+    }
 
     setCartVersion((v) => v + 1);
   };
 
+  // URL-ul liniei: ID-ul din DB pentru utilizatori, produs + variantă pentru oaspeți
+  const itemUrl = (id) => {
+    if (user) {
+      return `${API_URL}/api/cart/item/${id.replace("server-", "")}`;
+    }
+    const item = cartItems.find((i) => getCartItemId(i) === id);
+    const productId = item?.productId ?? id.replace("guest-", "").split(".")[0];
+    const variant = item?.variantId ? `?variantId=${item.variantId}` : "";
+    return `${API_URL}/api/cart/item/${productId}${variant}`;
+  };
+
   const removeItem = async (id) => {
+    const url = itemUrl(id);
     setCartItems((items) => items.filter((i) => getCartItemId(i) !== id));
 
-    if (!user) return;
-
-    const realId = id.replace("server-", "");
-
     try {
-      await fetch(`${API_URL}/api/cart/item/${realId}`, {
+      await fetch(url, {
         method: "DELETE",
         credentials: "include",
       });
@@ -238,33 +142,39 @@ export function CartProvider({ children }) {
   const updateQuantity = async (id, quantity) => {
     if (quantity < 1) return removeItem(id);
 
+    const url = itemUrl(id);
     setCartItems((items) =>
       items.map((i) => (getCartItemId(i) === id ? { ...i, quantity } : i))
     );
 
-    if (!user) return;
-
-    const realId = id.replace("server-", "");
-
     try {
-      await fetch(`${API_URL}/api/cart/item/${realId}`, {
+      const res = await fetch(url, {
         method: "PUT",
         credentials: "include",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ quantity }),
       });
+      if (!res.ok) throw new Error();
     } catch {
       setCartVersion((v) => v + 1);
     }
   };
 
-  const clearCart = async () => {
-    if (!user) {
-      setCartItems([]);
-      document.cookie = "guestCart=; path=/; max-age=0";
-      return;
-    }
+  // acceptă prețurile curente și scoate produsele șterse din catalog
+  const refreshCart = async () => {
+    const res = await fetch(`${API_URL}/api/cart/refresh`, {
+      method: "POST",
+      credentials: "include",
+    });
+    if (!res.ok) throw new Error(await res.text());
 
+    const summary = await res.json();
+    setCartSummary(summary);
+    setCartItems(summary.items);
+  };
+
+  const clearCart = async () => {
+    setCartItems([]);
     await fetch(`${API_URL}/api/cart`, {
       method: "DELETE",
       credentials: "include",
@@ -275,12 +185,7 @@ export function CartProvider({ children }) {
 
   // total
 
-  const cartSubtotal =
-    cartItems.reduce((sum, i) => {
-      const price =
-        i.product?.price_cents ?? Math.round((i.product?.price || 0) * 100);
-      return sum + price * i.quantity;
-    }, 0) / 100;
+  const cartSubtotal = (cartSummary?.subtotal_cents ?? 0) / 100;
 
   return (
     <CartContext.Provider
@@ -290,14 +195,15 @@ export function CartProvider({ children }) {
         removeItem,
         updateQuantity,
         clearCart,
+        refreshCart,
+        cartSummary,
         cartSubtotal,
         openCart,
         closeCart,
         isCartOpen,
-        loading: cartInitializing || migratingCart,
+        loading: cartInitializing,
         getProductId,
         getCartItemId,
-        migratingCart,
       }}
     >
       {children}
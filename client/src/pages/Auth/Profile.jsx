@@ -6,6 +6,17 @@ import trashIcon from "../../assets/cartModal/trashIcon.png";
 import defaultImage from "../../assets/default_image.png";
 import { API_URL } from "../../config/api";
 
+const orderStatusLabels = {
+  pending: "În așteptare",
+  confirmed: "Confirmată",
+  in_production: "În producție",
+  ready: "Gata de livrare",
+  delivering: "În livrare",
+  delivered: "Livrată",
+  cancelled: "Anulată",
+  returned: "Returnată",
+};
+
 export default function Profile() {
   const { user, logout } = useAuth();
   const { cartItems, removeItem } = useCart();
@@ -53,6 +64,41 @@ export default function Profile() {
     }
   }
 
+  // Descarcă factura finală dacă există, altfel proforma (emisă la cerere)
+  const handleDownloadInvoice = async (orderId) => {
+    try {
+      const listRes = await fetch(`${API_URL}/api/orders/${orderId}/invoices`, {
+        credentials: "include",
+      });
+      if (!listRes.ok) throw new Error(await listRes.text());
+      const invoices = await listRes.json();
+
+      let invoice = invoices.find((inv) => inv.kind === "invoice");
+      if (!invoice) {
+        const res = await fetch(
+          `${API_URL}/api/orders/${orderId}/invoices/proforma`,
+          { method: "POST", credentials: "include" }
+        );
+        if (!res.ok) throw new Error(await res.text());
+        invoice = await res.json();
+      }
+
+      const pdfRes = await fetch(
+        `${API_URL}/api/orders/${orderId}/invoices/${invoice.id}/pdf`,
+        { credentials: "include" }
+      );
+      if (!pdfRes.ok) throw new Error(await pdfRes.text());
+      const url = URL.createObjectURL(await pdfRes.blob());
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = `${invoice.code.replace(" ", "-")}.pdf`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError(err.message || "Eroare la descărcarea facturii");
+    }
+  };
+
   const handleRemoveItem = async (itemId) => {
     try {
       await removeItem(itemId);
@@ -164,7 +210,7 @@ export default function Profile() {
             {orders.map((order) => (
               <div key={order.id} className="orderCard">
                 <div className="orderHeader">
-                  <h4>Cererea #{order.id}</h4>
+                  <h4>Cererea {order.reference || `#${order.id}`}</h4>
                   <span className="orderDate">
                     {order.CreatedAt || order.created_at
                       ? formatDate(order.CreatedAt || order.created_at)
@@ -174,11 +220,7 @@ export default function Profile() {
                 <div className="orderStatus">
                   Status:
                   <span className={`status ${order.Status}`}>
-                    {order.status === "pending"
-                      ? "În așteptare"
-                      : order.status === "completed"
-                      ? "Finalizată"
-                      : order.status}
+                    {orderStatusLabels[order.status] || order.status}
                   </span>
                 </div>
                 <div className="orderItems">
@@ -231,9 +273,31 @@ export default function Profile() {
                   <strong>
                     {order.total || order.Total
                       ? (order.total || order.Total).toFixed(2)
-                      : "0.00"}{" "}
+                      : ((order.total_cents || 0) / 100).toFixed(2)}{" "}
                     MDL
                   </strong>
+                  {order.deposit_due_cents > 0 && (
+                    <p>
+                      Avans de achitat înainte de producție:{" "}
+                      <strong>
+                        {(order.deposit_due_cents / 100).toFixed(2)} MDL
+                      </strong>
+                    </p>
+                  )}
+                  {order.balance_cents > 0 && order.paid_cents > 0 && (
+                    <p>
+                      Achitat: {(order.paid_cents / 100).toFixed(2)} MDL · rest
+                      de plată: {(order.balance_cents / 100).toFixed(2)} MDL
+                    </p>
+                  )}
+                  {!["cancelled", "returned"].includes(order.status) && (
+                    <button
+                      className="invoiceBtn"
+                      onClick={() => handleDownloadInvoice(order.id)}
+                    >
+                      Descarcă factura
+                    </button>
+                  )}
                 </div>
               </div>
             ))}
@@ -1,4 +1,4 @@
-import { useState, useEffect } from "react";
+import { useState, useEffect, useRef } from "react";
 import { useNavigate, useLocation } from "react-router-dom";
 import { useCart } from "../../context/CartContext";
 import { useAuth } from "../../context/AuthContext";
@@ -10,7 +10,8 @@ import "./checkoutPage.scss";
 import { API_URL } from "../../config/api";
 
 export default function CheckoutPage() {
-  const { cartItems, cartSubtotal, clearCart } = useCart();
+  const { cartItems, cartSummary, cartSubtotal, clearCart, refreshCart } =
+    useCart();
   const { user } = useAuth();
   const navigate = useNavigate();
   const location = useLocation();
@@ -22,9 +23,74 @@ export default function CheckoutPage() {
     address: "",
     city: "",
     notes: "",
+    paymentMethod: "cash_on_delivery",
+    companyName: "",
+    fiscalCode: "",
   });
 
   const [isSubmitting, setIsSubmitting] = useState(false);
+  const [promoInput, setPromoInput] = useState("");
+  const [promo, setPromo] = useState(null);
+  const [quote, setQuote] = useState(null);
+  // aceeași cheie la reîncercare (dublu click, conexiune căzută), ca serverul
+  // să întoarcă comanda deja creată în loc să facă una nouă
+  const idempotencyKey = useRef(crypto.randomUUID());
+
+  const orderItems = () =>
+    cartItems.map((item) => ({
+      productId: item.productId || item.product?.ID,
+      variantId: item.variantId ?? undefined,
+      quantity: item.quantity,
+    }));
+
+  // Previzualizare reducere; codul e verificat din nou la plasarea comenzii
+  const handleApplyPromo = async () => {
+    if (!promoInput.trim()) return;
+    try {
+      const res = await fetch(`${API_URL}/api/cart/apply-promo`, {
+        method: "POST",
+        credentials: "include",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({
+          code: promoInput,
+          phone: formData.phone,
+          items: orderItems(),
+        }),
+      });
+      const data = await res.json();
+      if (!res.ok) throw new Error(data.error);
+      setPromo(data);
+      toast.success("Codul promoțional a fost aplicat");
+    } catch (err) {
+      setPromo(null);
+      toast.error(err.message || "Cod promoțional invalid");
+    }
+  };
+
+  // Costul livrării depinde de localitate; serverul recalculează tot coșul
+  useEffect(() => {
+    const city = formData.city.trim();
+    if (!city) {
+      setQuote(null);
+      return;
+    }
+
+    const timer = setTimeout(async () => {
+      const params = new URLSearchParams({ city });
+      if (promo?.code) params.set("promo", promo.code);
+      if (formData.phone) params.set("phone", formData.phone);
+      try {
+        const res = await fetch(`${API_URL}/api/delivery/quote?${params}`, {
+          credentials: "include",
+        });
+        if (!res.ok) throw new Error();
+        setQuote(await res.json());
+      } catch {
+        setQuote(null);
+      }
+    }, 400);
+    return () => clearTimeout(timer);
+  }, [formData.city, formData.phone, promo?.code, cartItems]);
 
   useEffect(() => {
     if (user) {
@@ -37,12 +103,27 @@ export default function CheckoutPage() {
     }
   }, [user]);
 
+  // Întoarcerea de la pagina de plată a băncii: ?order=12&payment=ok|failed
+  const paymentResult = new URLSearchParams(location.search).get("payment");
+  useEffect(() => {
+    if (!paymentResult) return;
+    if (paymentResult === "ok") {
+      toast.success("Plata a fost primită. Mulțumim!");
+    } else {
+      toast.error(
+        "Plata nu a reușit. Comanda a fost salvată, te vom contacta pentru confirmare."
+      );
+    }
+    navigate(user ? "/account" : "/", { replace: true });
+  }, [paymentResult, user, navigate]);
+
   // Verifică dacă coșul este gol
   useEffect(() => {
+    if (paymentResult) return;
     if (cartItems.length === 0 && !location.state?.fromCart) {
       navigate("/cart");
     }
-  }, [cartItems.length, navigate, location]);
+  }, [cartItems.length, navigate, location, paymentResult]);
 
   const handleInputChange = (e) => {
     const { name, value } = e.target;
@@ -74,6 +155,7 @@ export default function CheckoutPage() {
         credentials: "include",
         headers: {
           "Content-Type": "application/json",
+          "Idempotency-Key": idempotencyKey.current,
         },
         body: JSON.stringify({
           name: formData.name,
@@ -82,14 +164,20 @@ export default function CheckoutPage() {
           address: formData.address,
           city: formData.city,
           notes: formData.notes,
-          items: cartItems.map((item) => ({
-            productId: item.productId || item.product?.ID,
-            quantity: item.quantity,
-          })),
+          promoCode: promo?.code || "",
+          paymentMethod: formData.paymentMethod,
+          companyName: formData.companyName,
+          fiscalCode: formData.fiscalCode,
+          items: orderItems(),
         }),
       });
 
       if (!response.ok) {
+        // serverul a respins cererea: după corectarea datelor e o cerere nouă.
+        // Retry-After înseamnă că prima cerere e încă în lucru, deci păstrăm cheia.
+        if (!response.headers.get("Retry-After")) {
+          idempotencyKey.current = crypto.randomUUID();
+        }
         const errorText = await response.text();
         throw new Error(errorText);
       }
@@ -98,10 +186,27 @@ export default function CheckoutPage() {
 
       clearCart();
 
+      // plata cu cardul continuă pe pagina securizată a băncii
+      if (newOrder.payment_url) {
+        window.location.href = newOrder.payment_url;
+        return;
+      }
+      if (newOrder.payment_error) {
+        toast.warning(newOrder.payment_error);
+      }
+
       toast.success(
-        "Cererea ta a fost trimisă cu succes! Te vom contacta în curând pentru detalii."
+        `Cererea ${newOrder.reference} a fost trimisă cu succes! Te vom contacta în curând pentru detalii.`
       );
 
+      // fără cont, comanda se urmărește după referință și telefon
+      if (!user) {
+        navigate(`/track?ref=${encodeURIComponent(newOrder.reference)}`, {
+          state: { phone: formData.phone },
+        });
+        return;
+      }
+
       navigate("/account", {
         state: {
           orderSuccess: true,
@@ -209,19 +314,93 @@ export default function CheckoutPage() {
                         </h4>
                         <p>Cantitate: {item.quantity}</p>
                         <p>
-                          Preț:{" "}
-                          {((item.product?.price_cents ?? 0) / 100).toFixed(2) *
-                            item.quantity || 0}{" "}
-                          MDL
+                          Preț: {(item.line_total_cents / 100).toFixed(2)} MDL
                         </p>
+                        {item.status !== "ok" && (
+                          <p className="itemWarning">{item.message}</p>
+                        )}
+                        {item.price_changed && (
+                          <p className="itemWarning">
+                            Prețul s-a schimbat de la{" "}
+                            {(item.added_price_cents / 100).toFixed(2)} MDL
+                          </p>
+                        )}
                       </div>
                     </div>
                   );
                 })}
               </div>
 
+              <div className="formGroup promoCode">
+                <label>Cod promoțional</label>
+                <input
+                  type="text"
+                  value={promoInput}
+                  onChange={(e) => setPromoInput(e.target.value)}
+                  placeholder="Ex: VARA2026"
+                />
+                <button type="button" onClick={handleApplyPromo}>
+                  Aplică
+                </button>
+              </div>
+
+              {cartSummary && !cartSummary.can_checkout && (
+                <div className="cartIssues">
+                  <p>
+                    Unele produse s-au schimbat de când au fost adăugate.
+                    Verifică lista înainte de a trimite cererea.
+                  </p>
+                  <button
+                    type="button"
+                    onClick={() =>
+                      refreshCart().catch(() =>
+                        toast.error("Eroare la actualizarea listei")
+                      )
+                    }
+                  >
+                    Acceptă prețurile actuale
+                  </button>
+                </div>
+              )}
+
               <div className="orderTotal">
-                <h3>Total: {cartSubtotal} MDL</h3>
+                {promo && (
+                  <p>
+                    Reducere ({promo.code}): -{promo.discount.toFixed(2)} MDL
+                    {promo.free_delivery && " · livrare gratuită"}
+                  </p>
+                )}
+                {quote?.delivery && (
+                  <p>
+                    Livrare ({quote.delivery.zone_name}):{" "}
+                    {quote.delivery.free
+                      ? "gratuită"
+                      : `${(quote.delivery.fee_cents / 100).toFixed(2)} MDL`}
+                    {quote.delivery.remaining_for_free_cents > 0 &&
+                      ` · încă ${(
+                        quote.delivery.remaining_for_free_cents / 100
+                      ).toFixed(2)} MDL până la livrare gratuită`}
+                  </p>
+                )}
+                {quote?.delivery_error && (
+                  <p className="itemWarning">{quote.delivery_error}</p>
+                )}
+                <h3>
+                  Total:{" "}
+                  {quote
+                    ? (quote.total_cents / 100).toFixed(2)
+                    : promo
+                      ? promo.total.toFixed(2)
+                      : cartSubtotal}{" "}
+                  MDL
+                </h3>
+                {(quote || cartSummary)?.deposit_cents > 0 && (
+                  <p>
+                    Avans la comandă:{" "}
+                    {((quote || cartSummary).deposit_cents / 100).toFixed(2)}{" "}
+                    MDL · restul se achită la livrare
+                  </p>
+                )}
               </div>
             </div>
 
@@ -303,10 +482,55 @@ export default function CheckoutPage() {
                   />
                 </div>
 
+                <div className="formGroup">
+                  <label>Factură pe firmă (opțional)</label>
+                  <input
+                    type="text"
+                    name="companyName"
+                    value={formData.companyName}
+                    onChange={handleInputChange}
+                    placeholder="Denumirea companiei"
+                  />
+                  <input
+                    type="text"
+                    name="fiscalCode"
+                    value={formData.fiscalCode}
+                    onChange={handleInputChange}
+                    placeholder="IDNO (13 cifre)"
+                    inputMode="numeric"
+                    maxLength={13}
+                    required={Boolean(formData.companyName.trim())}
+                  />
+                </div>
+
+                <div className="formGroup paymentMethods">
+                  <label>Metoda de plată</label>
+                  {[
+                    ["cash_on_delivery", "Numerar la livrare"],
+                    ["bank_transfer", "Transfer bancar"],
+                    ["card", "Card online"],
+                  ].map(([value, label]) => (
+                    <label key={value} className="paymentOption">
+                      <input
+                        type="radio"
+                        name="paymentMethod"
+                        value={value}
+                        checked={formData.paymentMethod === value}
+                        onChange={handleInputChange}
+                      />
+                      {label}
+                    </label>
+                  ))}
+                </div>
+
                 <button
                   type="submit"
                   className="submitOrderBtn"
-                  disabled={isSubmitting}
+                  disabled={
+                    isSubmitting ||
+                    !cartSummary?.can_checkout ||
+                    Boolean(quote?.delivery_error)
+                  }
                 >
                   {isSubmitting ? "Se trimite cererea..." : "Trimite cererea"}
                 </button>
@@ -16,6 +16,7 @@ import Nav from "../../components/Nav/Nav";
 import Footer from "../../components/Footer/Footer";
 import defaultImage from "../../assets/default_image.png";
 import { getImageUrl } from "../../components/Utils/image.js";
+import { productPriceCents } from "../../components/Utils/utils";
 
 import { API_URL } from "../../config/api";
 
@@ -98,7 +99,7 @@ export default function ProductPage() {
             offers: {
               "@type": "Offer",
               priceCurrency: "MDL",
-              price: ((product.price_cents ?? 0) / 100).toFixed(2),
+              price: (productPriceCents(product) / 100).toFixed(2),
               availability: "https://schema.org/InStock",
               url: `https://www.simonialuxury.com/product/${id}`,
             },
@@ -193,7 +194,12 @@ export default function ProductPage() {
           <div className="col-md-6 d-flex flex-column justify-content-center">
             <h1 className="productName mb-3">{product.name}</h1>
             <p className="productPrice mb-3">
-              {((product.price_cents ?? 0) / 100).toFixed(2)} MDL
+              {product.original_price_cents && (
+                <s className="oldPrice me-2">
+                  {(product.original_price_cents / 100).toFixed(2)} MDL
+                </s>
+              )}
+              {(productPriceCents(product) / 100).toFixed(2)} MDL
             </p>
             <div className="productDescription">
               <p>{product.description}</p>
@@ -0,0 +1,176 @@
+import { useState, useEffect } from "react";
+import { useLocation } from "react-router-dom";
+import Nav from "../../components/Nav/Nav";
+import Footer from "../../components/Footer/Footer";
+import "./trackOrderPage.scss";
+import { API_URL } from "../../config/api";
+
+const orderStatusLabels = {
+  pending: "În așteptare",
+  confirmed: "Confirmată",
+  in_production: "În producție",
+  ready: "Gata de livrare",
+  delivering: "În livrare",
+  delivered: "Livrată",
+  cancelled: "Anulată",
+  returned: "Returnată",
+};
+
+const formatDate = (dateString) =>
+  new Date(dateString).toLocaleDateString("ro-RO", {
+    year: "numeric",
+    month: "long",
+    day: "numeric",
+  });
+
+const formatMDL = (cents) => `${(cents / 100).toFixed(2)} MDL`;
+
+// Urmărirea comenzii pentru cumpărătorii fără cont: referința + telefonul sau emailul
+export default function TrackOrderPage() {
+  const location = useLocation();
+  const params = new URLSearchParams(location.search);
+
+  const [reference, setReference] = useState(params.get("ref") || "");
+  const [contact, setContact] = useState(location.state?.phone || "");
+  const [order, setOrder] = useState(null);
+  const [error, setError] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  const track = async (ref, value) => {
+    if (!ref.trim() || !value.trim()) return;
+    setLoading(true);
+    setError(null);
+    try {
+      const query = new URLSearchParams({ reference: ref });
+      query.set(value.includes("@") ? "email" : "phone", value.trim());
+      const res = await fetch(`${API_URL}/api/orders/track?${query}`);
+      if (!res.ok) throw new Error(await res.text());
+      setOrder(await res.json());
+    } catch (err) {
+      setOrder(null);
+      setError(err.message || "Eroare la căutarea comenzii");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  // venind direct după plasarea comenzii, datele sunt deja completate
+  useEffect(() => {
+    if (reference && contact) track(reference, contact);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
+
+  const handleSubmit = (e) => {
+    e.preventDefault();
+    track(reference, contact);
+  };
+
+  return (
+    <>
+      <Nav />
+      <div className="trackOrderPage">
+        <div className="container">
+          <h1>Urmărește comanda</h1>
+
+          <form onSubmit={handleSubmit}>
+            <div className="formGroup">
+              <label>Numărul comenzii</label>
+              <input
+                type="text"
+                value={reference}
+                onChange={(e) => setReference(e.target.value)}
+                placeholder="Ex: SL-2026-7K3QF"
+                required
+              />
+            </div>
+            <div className="formGroup">
+              <label>Telefon sau email</label>
+              <input
+                type="text"
+                value={contact}
+                onChange={(e) => setContact(e.target.value)}
+                placeholder="Datele folosite la comandă"
+                required
+              />
+            </div>
+            <button type="submit" disabled={loading}>
+              {loading ? "Se caută..." : "Caută"}
+            </button>
+          </form>
+
+          {error && <p className="error-message">{error}</p>}
+
+          {order && (
+            <div className="trackedOrder">
+              <h2>Comanda {order.reference}</h2>
+              <p>
+                Status:{" "}
+                <strong>
+                  {orderStatusLabels[order.status] || order.status}
+                </strong>
+              </p>
+              <p>Plasată pe {formatDate(order.created_at)}</p>
+
+              {order.delivered_at ? (
+                <p>Livrată pe {formatDate(order.delivered_at)}</p>
+              ) : order.delivery_from || order.delivery_to ? (
+                <p>
+                  Livrare estimată:{" "}
+                  {order.delivery_from && formatDate(order.delivery_from)}
+                  {order.delivery_from && order.delivery_to && " – "}
+                  {order.delivery_to && formatDate(order.delivery_to)}
+                </p>
+              ) : (
+                !["cancelled", "returned"].includes(order.status) && (
+                  <p>
+                    Termenul de livrare îți va fi comunicat după finalizarea
+                    producției.
+                  </p>
+                )
+              )}
+
+              <h3>Produse</h3>
+              <ul className="trackedItems">
+                {order.items.map((item, index) => (
+                  <li key={index}>
+                    <span>
+                      {item.name}
+                      {item.variant && ` (${item.variant})`} × {item.quantity}
+                    </span>
+                    <span>{formatMDL(item.line_total_cents)}</span>
+                  </li>
+                ))}
+              </ul>
+              <p>
+                Total: <strong>{formatMDL(order.total_cents)}</strong>
+              </p>
+              {order.deposit_due_cents > 0 && (
+                <p>
+                  Avans de achitat înainte de producție:{" "}
+                  {formatMDL(order.deposit_due_cents)}
+                </p>
+              )}
+              {order.paid_cents > 0 && order.balance_cents > 0 && (
+                <p>
+                  Achitat: {formatMDL(order.paid_cents)} · rest de plată:{" "}
+                  {formatMDL(order.balance_cents)}
+                </p>
+              )}
+
+              <h3>Istoric</h3>
+              <ul className="trackedHistory">
+                {order.history.map((ev, index) => (
+                  <li key={index}>
+                    <span>{orderStatusLabels[ev.status] || ev.status}</span>
+                    <span>{formatDate(ev.at)}</span>
+                  </li>
+                ))}
+              </ul>
+            </div>
+          )}
+        </div>
+      </div>
+      <Footer />
+    </>
+  );
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
@@ -14,7 +17,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 	"github.com/lib/pq"
-	storage_go "github.com/supabase-community/storage-go"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func getCookieOptions() (sameSite http.SameSite, secure bool) {
@@ -31,12 +36,15 @@ type AdminCredentials struct {
 }
 
 type AdminClaims struct {
+	AdminID  uint   `json:"admin_id"`
 	Username string `json:"username"`
 	jwt.RegisteredClaims
 }
 
 var adminJWTKey []byte
 
+const adminUserKey contextKey = "adminUser"
+
 func init() {
 	adminJWTKey = []byte(os.Getenv("JWT_SECRET_ADMIN"))
 }
@@ -48,10 +56,15 @@ func adminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminUser := getEnv("ADMIN_USER", "admin")
-	adminPass := getEnv("ADMIN_PASS", "password123")
+	var admin AdminUser
+	username := strings.TrimSpace(strings.ToLower(creds.Username))
+	if err := DB.Where("username = ?", username).First(&admin).Error; err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
 
-	if creds.Username != adminUser || creds.Password != adminPass {
+	if !admin.IsActive || admin.PasswordHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(creds.Password)) != nil {
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
@@ -59,7 +72,8 @@ func adminLogin(w http.ResponseWriter, r *http.Request) {
 	// Generează JWT pentru admin
 	expirationTime := time.Now().Add(24 * time.Hour)
 	claims := &AdminClaims{
-		Username: creds.Username,
+		AdminID:  admin.ID,
+		Username: admin.Username,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -72,6 +86,8 @@ func adminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	DB.Model(&admin).Update("last_login_at", time.Now())
+
 	sameSite, secure := getCookieOptions()
 
 	http.SetCookie(w, &http.Cookie{
@@ -87,7 +103,8 @@ func adminLogin(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"message":  "Login successful",
-		"username": creds.Username,
+		"username": admin.Username,
+		"role":     admin.Role,
 	})
 }
 
@@ -111,99 +128,93 @@ func adminLogout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Middleware care validează doar admin_token
-func adminAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("ADMIN AUTH MIDDLEWARE")
-		log.Println("Request:", r.Method, r.URL.Path)
-
-		var tokenString string
-
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-			tokenString = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
-			if len(tokenString) > 10 {
-				log.Println("Found Bearer token in header:", tokenString[:10]+"...")
-			} else {
-				log.Println("Found Bearer token in header (short):", tokenString)
-			}
-		} else {
-			cookie, err := r.Cookie("admin_token")
-			if err == nil && cookie.Value != "" {
-				tokenString = cookie.Value
-				log.Println("Found admin_token in cookie")
-			}
-		}
-
-		if tokenString == "" {
-			log.Println("No admin token found in header or cookie")
-			http.Error(w, "Unauthorized - No token", http.StatusUnauthorized)
-			return
-		}
+// adminFromRequest validează admin_token (header sau cookie) și încarcă contul din DB,
+// astfel încât dezactivarea sau schimbarea rolului au efect imediat.
+func adminFromRequest(r *http.Request) (*AdminUser, error) {
+	var tokenString string
 
-		claims := &AdminClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return adminJWTKey, nil
-		})
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
+		tokenString = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	} else if cookie, err := r.Cookie("admin_token"); err == nil && cookie.Value != "" {
+		tokenString = cookie.Value
+	}
 
-		if err != nil {
-			log.Println("Token validation error:", err)
-			http.Error(w, "Unauthorized - Invalid token", http.StatusUnauthorized)
-			return
-		}
+	if tokenString == "" {
+		return nil, errors.New("no token")
+	}
 
-		if !token.Valid {
-			log.Println("Token is invalid")
-			http.Error(w, "Unauthorized - Token invalid", http.StatusUnauthorized)
-			return
+	claims := &AdminClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("metodă de semnare neașteptată")
 		}
-
-		log.Println("Admin authentificated:", claims.Username)
-		next.ServeHTTP(w, r)
+		return adminJWTKey, nil
 	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	var admin AdminUser
+	if err := DB.First(&admin, claims.AdminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if !admin.IsActive {
+		return nil, errors.New("admin disabled")
+	}
+	return &admin, nil
 }
 
-func adminMe(w http.ResponseWriter, r *http.Request) {
-	log.Println("ADMIN ME ENDPOINT")
+func currentAdmin(r *http.Request) *AdminUser {
+	admin, _ := r.Context().Value(adminUserKey).(*AdminUser)
+	return admin
+}
 
-	var tokenString string
+// adminActor identifică adminul curent în istoricul comenzilor și al prețurilor
+func adminActor(r *http.Request) string {
+	if admin := currentAdmin(r); admin != nil {
+		return "admin:" + admin.Username
+	}
+	return "admin"
+}
 
-	authHeader := r.Header.Get("Authorization")
-	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer") {
-		tokenString = strings.TrimPrefix(authHeader, "Bearer")
-		tokenString = strings.TrimSpace(tokenString)
-		log.Println("Using token from Authorization header")
-	} else {
-		// Cookie fallback
-		cookie, err := r.Cookie("admin_token")
+// Middleware care validează admin_token și permisiunea cerută de ruta curentă
+func adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin, err := adminFromRequest(r)
 		if err != nil {
-			log.Println("No token found in header or cookie")
-			http.Error(w, "Unauthorized - No token", http.StatusUnauthorized)
+			log.Println("Admin auth failed:", r.Method, r.URL.Path, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		tokenString = cookie.Value
-		log.Println("Using token from cookie")
-	}
+		perm, ok := routePermission(r)
+		if !ok || !admin.Can(perm) {
+			log.Printf("Admin %s (%s) fără acces la %s %s", admin.Username, admin.Role, r.Method, r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
 
-	claims := &AdminClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return adminJWTKey, nil
+		ctx := context.WithValue(r.Context(), adminUserKey, admin)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
+}
 
-	if err != nil || !token.Valid {
-		log.Println("Token validation failed:", err)
+func adminMe(w http.ResponseWriter, r *http.Request) {
+	admin, err := adminFromRequest(r)
+	if err != nil {
+		log.Println("Admin me failed:", err)
 		http.Error(w, "Unauthorized - Invalid token", http.StatusUnauthorized)
 		return
 	}
 
-	log.Println("Admin me successful:", claims.Username)
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"username": claims.Username,
-		"role":     "admin",
-		"id":       1,
+		"id":          admin.ID,
+		"username":    admin.Username,
+		"fullName":    admin.Name,
+		"role":        admin.Role,
+		"permissions": rolePermissions[admin.Role],
 	})
 }
 
@@ -299,7 +310,7 @@ func getAdminProducts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Execute query
-	if err := DB.Preload("Category").Order(sortField + " " + sortOrder).Offset(offset).Limit(limit).Find(&products).Error; err != nil {
+	if err := DB.Preload("Category").Preload("Variants").Order(sortField + " " + sortOrder).Offset(offset).Limit(limit).Find(&products).Error; err != nil {
 		http.Error(w, "Eroare la preluarea produselor", http.StatusInternalServerError)
 		return
 	}
@@ -322,7 +333,7 @@ func getAdminProduct(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	var product Product
 
-	if err := DB.Preload("Category").First(&product, id).Error; err != nil {
+	if err := DB.Preload("Category").Preload("Variants").First(&product, id).Error; err != nil {
 		http.Error(w, "Produsul nu a fost găsit", http.StatusNotFound)
 		return
 	}
@@ -349,7 +360,27 @@ func createAdminProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.StockQty < 0 {
+		http.Error(w, "Stocul nu poate fi negativ", http.StatusBadRequest)
+		return
+	}
+
+	if req.DepositPercent != nil && !validDepositPercent(*req.DepositPercent) {
+		http.Error(w, "Avansul trebuie să fie între 0 și 100%", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateVariantRequests(req.Variants); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	priceCents := int64(math.Round(req.Price * 100))
+	salePriceCents := centsPtr(req.SalePrice)
+	if err := validateSale(priceCents, salePriceCents, req.SaleStartsAt, req.SaleEndsAt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var cat Category
 	if err := DB.First(&cat, req.CategoryID).Error; err != nil {
@@ -362,26 +393,56 @@ func createAdminProduct(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 		PriceCents:  priceCents,
 		CategoryID:  req.CategoryID,
+		Dimensions:  req.Dimensions,
 		IsActive:    req.IsActive,
 		IsAvailable: req.IsAvailable,
+		TrackStock:  req.TrackStock,
+		StockQty:    req.StockQty,
 		ImageURLs:   pq.StringArray(req.ImageURLs),
+
+		DepositPercent: req.DepositPercent,
+
+		CompareAtCents: centsPtr(req.CompareAtPrice),
+		SalePriceCents: salePriceCents,
+		SaleStartsAt:   req.SaleStartsAt,
+		SaleEndsAt:     req.SaleEndsAt,
 	}
+	setProductDimensions(&product)
 
-	if err := DB.Create(&product).Error; err != nil {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if err := recordPriceChanges(tx, Product{}, product, adminActor(r)); err != nil {
+			return err
+		}
+		if err := recordStockAdjustment(tx, product.ID, nil, 0, product.StockQty); err != nil {
+			return err
+		}
+		return syncProductVariants(tx, product.ID, req.Variants)
+	})
+	if err != nil {
+		var variantErr *VariantSyncError
+		if errors.As(err, &variantErr) {
+			http.Error(w, variantErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Println("Eroare la crearea produsului:", err)
 		http.Error(w, "Eroare la creare", http.StatusInternalServerError)
 		return
 	}
 
-	DB.Preload("Category").First(&product, product.ID)
+	DB.Preload("Category").Preload("Variants").First(&product, product.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(productToResponse(product))
 }
 
 func adminUpload(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(10 << 20) // 10 MB
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageUpload+(1<<20))
+	err := r.ParseMultipartForm(maxImageUpload)
 	if err != nil {
-		http.Error(w, "Formular invalid", http.StatusBadRequest)
+		http.Error(w, "Formular invalid sau fișier prea mare", http.StatusBadRequest)
 		return
 	}
 
@@ -392,87 +453,171 @@ func adminUpload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), header.Filename)
-	path := "products/" + filename
-	contentType := header.Header.Get("Content-Type")
-	upsert := true
-
-	_, err = Supabase.Storage.UploadFile(
-		"products",
-		path,
-		file,
-		storage_go.FileOptions{
-			ContentType: &contentType,
-			Upsert:      &upsert,
-		},
-	)
+	data, err := io.ReadAll(io.LimitReader(file, maxImageUpload+1))
+	if err != nil || len(data) > maxImageUpload {
+		http.Error(w, "Fișierul depășește 10 MB", http.StatusBadRequest)
+		return
+	}
 
+	img, err := processAndStoreImage(data, header.Filename)
 	if err != nil {
-		log.Println("Eroare Supabase:", err)
+		if errors.Is(err, errUnsupportedImage) || errors.Is(err, errImageTooLarge) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		log.Println("Eroare la procesarea/stocarea imaginii:", err)
 		http.Error(w, "Eroare la stocare", http.StatusInternalServerError)
 		return
 	}
 
-	supabaseURL := os.Getenv("SUPABASE_URL")
-
-	publicURL := fmt.Sprintf(
-		"%s/storage/v1/object/public/products/%s",
-		supabaseURL,
-		path,
-	)
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"url": publicURL,
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":    img.Original,
+		"images": img,
 	})
 }
 
 func updateProduct(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	var product Product
-	if err := DB.First(&product, id).Error; err != nil {
-		http.Error(w, "Produsul nu a fost găsit", http.StatusNotFound)
-		return
-	}
-
 	var req ProductUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Date invalide", http.StatusBadRequest)
 		return
 	}
-
-	if req.Name != nil {
-		product.Name = *req.Name
-	}
-	if req.Description != nil {
-		product.Description = *req.Description
-	}
-	if req.Price != nil {
-		product.PriceCents = int64(math.Round(*req.Price * 100))
-	}
-	if req.Dimensions != nil {
-		product.Dimensions = *req.Dimensions
-	}
-	if req.IsActive != nil {
-		product.IsActive = *req.IsActive
+	if req.StockQty != nil && *req.StockQty < 0 {
+		http.Error(w, "Stocul nu poate fi negativ", http.StatusBadRequest)
+		return
 	}
-	if req.IsAvailable != nil {
-		product.IsAvailable = *req.IsAvailable
+	if req.StockQty != nil && req.ExpectedStockQty == nil {
+		http.Error(w, errExpectedStockRequired.Error(), http.StatusBadRequest)
+		return
 	}
-	if req.ImageURLs != nil {
-		product.ImageURLs = pq.StringArray(*req.ImageURLs)
+	if req.DepositPercent != nil && *req.DepositPercent != -1 && !validDepositPercent(*req.DepositPercent) {
+		http.Error(w, "Avansul trebuie să fie între 0 și 100%", http.StatusBadRequest)
+		return
 	}
-	if req.CategoryID != nil {
-		product.CategoryID = *req.CategoryID
+	if req.Variants != nil {
+		if err := validateVariantRequests(*req.Variants); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	if err := DB.Save(&product).Error; err != nil {
-		http.Error(w, "Eroare la actualizare", http.StatusInternalServerError)
+	// produsul e recitit blocat în tranzacție și se scriu doar coloanele primite.
+	// Stocul se schimbă doar dacă adminul l-a modificat pornind de la valoarea curentă,
+	// ca o editare să nu suprascrie stocul rezervat între timp de comenzi.
+	var product Product
+	var validationErr error
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, id).Error; err != nil {
+			return err
+		}
+		before := product
+		var columns []string
+
+		if req.Name != nil {
+			product.Name = *req.Name
+			columns = append(columns, "name")
+		}
+		if req.Description != nil {
+			product.Description = *req.Description
+			columns = append(columns, "description")
+		}
+		if req.Price != nil {
+			product.PriceCents = int64(math.Round(*req.Price * 100))
+			columns = append(columns, "price_cents")
+		}
+		if req.CompareAtPrice != nil {
+			product.CompareAtCents = centsPtr(req.CompareAtPrice)
+			columns = append(columns, "compare_at_cents")
+		}
+		if req.SalePrice != nil {
+			product.SalePriceCents = centsPtr(req.SalePrice)
+			product.SaleStartsAt = req.SaleStartsAt
+			product.SaleEndsAt = req.SaleEndsAt
+			columns = append(columns, "sale_price_cents", "sale_starts_at", "sale_ends_at")
+		}
+		if err := validateSale(product.PriceCents, product.SalePriceCents, product.SaleStartsAt, product.SaleEndsAt); err != nil {
+			validationErr = err
+			return err
+		}
+		if req.Dimensions != nil {
+			product.Dimensions = *req.Dimensions
+			setProductDimensions(&product)
+			columns = append(columns, "dimensions", "width_cm", "depth_cm", "height_cm")
+		}
+		if req.IsActive != nil {
+			product.IsActive = *req.IsActive
+			columns = append(columns, "is_active")
+		}
+		if req.IsAvailable != nil {
+			product.IsAvailable = *req.IsAvailable
+			columns = append(columns, "is_available")
+		}
+		if req.TrackStock != nil {
+			product.TrackStock = *req.TrackStock
+			columns = append(columns, "track_stock")
+		}
+		if req.StockQty != nil {
+			if err := checkExpectedStock(product.Name, product.StockQty, req.ExpectedStockQty); err != nil {
+				return err
+			}
+			product.StockQty = *req.StockQty
+			columns = append(columns, "stock_qty")
+		}
+		if req.ImageURLs != nil {
+			product.ImageURLs = pq.StringArray(*req.ImageURLs)
+			columns = append(columns, "image_urls")
+		}
+		if req.CategoryID != nil {
+			product.CategoryID = *req.CategoryID
+			columns = append(columns, "category_id")
+		}
+		if req.DepositPercent != nil {
+			product.DepositPercent = req.DepositPercent
+			if *req.DepositPercent == -1 {
+				product.DepositPercent = nil
+			}
+			columns = append(columns, "deposit_percent")
+		}
+
+		if len(columns) > 0 {
+			if err := tx.Model(&product).Select(columns).Updates(&product).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordPriceChanges(tx, before, product, adminActor(r)); err != nil {
+			return err
+		}
+		if err := recordStockAdjustment(tx, product.ID, nil, before.StockQty, product.StockQty); err != nil {
+			return err
+		}
+		if req.Variants != nil {
+			return syncProductVariants(tx, product.ID, *req.Variants)
+		}
+		return nil
+	})
+	if err != nil {
+		var variantErr *VariantSyncError
+		var stockErr *StockConflictError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Produsul nu a fost găsit", http.StatusNotFound)
+		case validationErr != nil:
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		case errors.As(err, &variantErr):
+			http.Error(w, variantErr.Error(), http.StatusBadRequest)
+		case errors.As(err, &stockErr):
+			http.Error(w, stockErr.Error(), http.StatusConflict)
+		default:
+			log.Println("Eroare la actualizarea produsului:", err)
+			http.Error(w, "Eroare la actualizare", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	DB.Preload("Category").First(&product, product.ID)
+	DB.Preload("Category").Preload("Variants").First(&product, product.ID)
 	json.NewEncoder(w).Encode(productToResponse(product))
 }
 
@@ -491,7 +636,8 @@ func updateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	allowedFields := []string{"status", "name", "phone", "email", "address", "city", "notes"}
+	// statusul se schimbă doar prin updateOrderStatus, care validează tranziția
+	allowedFields := []string{"name", "phone", "email", "address", "city", "notes", "billing_company", "billing_fiscal_code", "delivery_from", "delivery_to"}
 	updateMap := make(map[string]interface{})
 
 	for _, field := range allowedFields {
@@ -499,6 +645,12 @@ func updateOrder(w http.ResponseWriter, r *http.Request) {
 			updateMap[field] = value
 		}
 	}
+	// intervalul de livrare se șterge trimițând un șir gol
+	for _, field := range []string{"delivery_from", "delivery_to"} {
+		if value, ok := updateMap[field].(string); ok && value == "" {
+			updateMap[field] = nil
+		}
+	}
 
 	if err := DB.Model(&order).Updates(updateMap).Error; err != nil {
 		http.Error(w, "Eroare la actualzarea comenzii", http.StatusInternalServerError)
@@ -519,32 +671,54 @@ func deleteAdminProduct(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// errOrderHasDocuments: comenzile cu plăți sau facturi rămân în evidență și pot fi doar anulate
+var errOrderHasDocuments = errors.New("Comanda are plăți sau facturi și nu poate fi ștearsă. Anulați-o în schimb.")
+
+// deleteAdminOrder anulează întâi comanda (eliberând stocul rezervat și codul
+// promoțional), apoi o ascunde. Liniile și istoricul rămân pentru evidență.
 func deleteAdminOrder(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
+	actor := adminActor(r)
 
-	var order Order
-	if err := DB.First(&order, id).Error; err != nil {
-		http.Error(w, "Order not found", http.StatusNotFound)
-		return
-	}
-
-	tx := DB.Begin()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var order Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+			return err
+		}
 
-	// Șterge mai întâi order items
-	if err := tx.Where("order_id = ?", id).Delete(&OrderItem{}).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Eroare la ștergerea items", http.StatusInternalServerError)
-		return
-	}
+		var payments, invoices int64
+		if err := tx.Model(&Payment{}).Where("order_id = ?", order.ID).Count(&payments).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Invoice{}).Where("order_id = ?", order.ID).Count(&invoices).Error; err != nil {
+			return err
+		}
+		if payments > 0 || invoices > 0 || order.PaidCents > 0 {
+			return errOrderHasDocuments
+		}
 
-	// Apoi șterge order-ul
-	if err := tx.Delete(&Order{}, id).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Eroare la ștergerea order-ului", http.StatusInternalServerError)
+		if !releasesStock(order.Status) {
+			if err := transitionOrder(tx, &order, OrderCancelled, actor, "Comandă ștearsă din admin"); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&order).Error
+	})
+	if err != nil {
+		var transitionErr *InvalidTransitionError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Comanda nu a fost gasita", http.StatusNotFound)
+		case errors.Is(err, errOrderHasDocuments):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.As(err, &transitionErr):
+			http.Error(w, "Comanda nu poate fi ștearsă în statusul curent: "+transitionErr.Error(), http.StatusConflict)
+		default:
+			log.Printf("Eroare la ștergerea comenzii %s: %v", id, err)
+			http.Error(w, "Eroare la ștergerea comenzii", http.StatusInternalServerError)
+		}
 		return
 	}
-
-	tx.Commit()
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -579,7 +753,7 @@ func getCategories(w http.ResponseWriter, r *http.Request) {
 	limit := pageSize
 
 	// Execute query
-	if err := DB.Select("id", "name", "created_at", "updated_at").Offset(offset).Limit(limit).Find(&categories).Error; err != nil {
+	if err := DB.Select("id", "name", "deposit_percent", "created_at", "updated_at").Offset(offset).Limit(limit).Find(&categories).Error; err != nil {
 		http.Error(w, "Eroare la preluarea categoriilor", http.StatusInternalServerError)
 		return
 	}
@@ -604,6 +778,10 @@ func createCategory(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Numele categoriesi este obligatoriu", http.StatusBadRequest)
 		return
 	}
+	if !validDepositPercent(category.DepositPercent) {
+		http.Error(w, "Avansul trebuie să fie între 0 și 100%", http.StatusBadRequest)
+		return
+	}
 
 	if err := DB.Create(&category).Error; err != nil {
 		http.Error(w, "Eroare la crearea categoriei", http.StatusInternalServerError)
@@ -614,6 +792,45 @@ func createCategory(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(category)
 }
 
+func updateCategory(w http.ResponseWriter, r *http.Request) {
+	var category Category
+	if err := DB.First(&category, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Categoria nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name           *string `json:"name"`
+		DepositPercent *int    `json:"deposit_percent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil {
+		if *req.Name == "" {
+			http.Error(w, "Numele categoriesi este obligatoriu", http.StatusBadRequest)
+			return
+		}
+		category.Name = *req.Name
+	}
+	if req.DepositPercent != nil {
+		if !validDepositPercent(*req.DepositPercent) {
+			http.Error(w, "Avansul trebuie să fie între 0 și 100%", http.StatusBadRequest)
+			return
+		}
+		category.DepositPercent = *req.DepositPercent
+	}
+
+	if err := DB.Save(&category).Error; err != nil {
+		http.Error(w, "Eroare la actualizarea categoriei", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
 func getAllOrders(w http.ResponseWriter, r *http.Request) {
 	var orders []Order
 	var total int64
@@ -637,15 +854,29 @@ func getAllOrders(w http.ResponseWriter, r *http.Request) {
 	}
 	pageSize := end - start + 1
 
+	// filter={"reference":"SL-2026-7K3QF"} pentru comenzile dictate la telefon.
+	// Nu filtrăm după telefon sau email: atelierul, care nu vede datele clienților,
+	// ar putea afla așa cine a comandat.
+	var filter struct {
+		Reference string `json:"reference"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+	q := DB.Model(&Order{})
+	if ref := normalizeOrderReference(filter.Reference); ref != "" {
+		q = q.Where("reference = ?", ref)
+	}
+
 	// Get total count
-	DB.Model(&Order{}).Count(&total)
+	q.Count(&total)
 
 	// Apply pagination
 	offset := start
 	limit := pageSize
 
 	// Execute query with preloading
-	if err := DB.Preload("Items.Product").
+	if err := q.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("History", orderHistoryScope).
 		Offset(offset).
 		Limit(limit).
 		Order("created_at DESC").
@@ -655,13 +886,13 @@ func getAllOrders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for i := range orders {
-		var total int64
 		for j := range orders[i].Items {
 			orders[i].Items[j].Price =
 				float64(orders[i].Items[j].PriceCents) / 100
-			total += orders[i].Items[j].PriceCents * int64(orders[i].Items[j].Quantity)
 		}
-		orders[i].Total = float64(total) / 100
+		orders[i].Total = float64(orders[i].TotalCents) / 100
+		withPaymentTotals(&orders[i])
+		redactOrderPII(currentAdmin(r), &orders[i])
 	}
 
 	// Set headers for React Admin
@@ -673,6 +904,27 @@ func getAllOrders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(orders)
 }
 
+func getAdminOrder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var order Order
+
+	if err := DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("History", orderHistoryScope).
+		First(&order, id).Error; err != nil {
+		http.Error(w, "Comanda nu a fost gasita", http.StatusNotFound)
+		return
+	}
+
+	for j := range order.Items {
+		order.Items[j].Price = float64(order.Items[j].PriceCents) / 100
+	}
+	order.Total = float64(order.TotalCents) / 100
+	withPaymentTotals(&order)
+	redactOrderPII(currentAdmin(r), &order)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
 func updateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi((vars["id"]))
@@ -683,30 +935,72 @@ func updateOrderStatus(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		Status string `json:"status"`
+		Note   string `json:"note"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Date invalide", http.StatusBadRequest)
 		return
 	}
 
-	validStatuses := []string{"pending", "processing", "completed", "cancelled"}
-	valid := false
-	for _, s := range validStatuses {
-		if req.Status == s {
-			valid = true
-			break
+	actor := adminActor(r)
+
+	var order Order
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+			return err
+		}
+		// PUT-ul din React-Admin retrimite tot obiectul; fără schimbare de status nu avem ce face
+		if order.Status == req.Status {
+			return nil
+		}
+		if err := transitionOrder(tx, &order, req.Status, actor, req.Note); err != nil {
+			return err
+		}
+		kind, ok := statusEmails[order.Status]
+		if !ok {
+			return nil
+		}
+		// la confirmare clientul primește și proforma, dacă facturarea e configurată
+		var proforma *Invoice
+		if kind == EmailOrderConfirmed && order.Email != "" {
+			inv, err := issueInvoice(tx, order.ID, InvoiceProforma, actor)
+			switch {
+			case err == nil:
+				proforma = &inv
+			case !errors.Is(err, errInvoicesDisabled):
+				return err
+			}
+		}
+		if err := tx.Preload("Product").Where("order_id = ?", order.ID).Find(&order.Items).Error; err != nil {
+			return err
+		}
+		return enqueueOrderEmailWithInvoice(tx, order, kind, req.Note, proforma)
+	})
+	if err != nil {
+		var transitionErr *InvalidTransitionError
+		var depositErr *DepositDueError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Comanda nu a fost gasita", http.StatusNotFound)
+		case errors.Is(err, errUnknownOrderStatus):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.As(err, &transitionErr):
+			http.Error(w, transitionErr.Error(), http.StatusConflict)
+		case errors.As(err, &depositErr):
+			http.Error(w, depositErr.Error(), http.StatusConflict)
+		default:
+			log.Printf("Eroare la actualizarea statusului pentru order #%d: %v", id, err)
+			http.Error(w, "Eroare la actualizarea statusului", http.StatusInternalServerError)
 		}
-	}
-	if !valid {
-		http.Error(w, "Status invalid", http.StatusBadRequest)
 		return
 	}
 
-	if err := DB.Model(&Order{}).Where("id = ?", id).Update("status", req.Status).Error; err != nil {
-		http.Error(w, "Eroare la actualizarea statusului", http.StatusInternalServerError)
-		return
-	}
+	wakeOutbox()
+
+	DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("History", orderHistoryScope).First(&order, id)
+	withPaymentTotals(&order)
+	redactOrderPII(currentAdmin(r), &order)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Status actualizat cu succes"})
+	json.NewEncoder(w).Encode(order)
 }
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleOwner    = "owner"
+	RoleManager  = "manager"
+	RoleSales    = "sales"
+	RoleWorkshop = "workshop"
+)
+
+const (
+	PermProductsRead   = "products:read"
+	PermProductsWrite  = "products:write"
+	PermOrdersRead     = "orders:read"
+	PermOrdersWrite    = "orders:write"
+	PermOrdersDelete   = "orders:delete"
+	PermCustomersRead  = "customers:read"
+	PermStockRead      = "stock:read"
+	PermStaffManage    = "staff:manage"
+	PermAuditRead      = "audit:read"
+	PermPromosRead     = "promotions:read"
+	PermPromosWrite    = "promotions:write"
+	PermEmailsRead     = "emails:read"
+	PermEmailsWrite    = "emails:write"
+	PermDeliveryRead   = "delivery:read"
+	PermDeliveryWrite  = "delivery:write"
+	PermPaymentsRefund = "payments:refund"
+	PermPaymentsWrite  = "payments:write"
+	PermInvoices       = "invoices:manage"
+)
+
+const adminInviteTTL = 72 * time.Hour
+
+var errUnknownRole = errors.New("Rol invalid")
+
+// rolePermissions: atelierul vede doar comenzile, fără datele de contact ale clienților
+var rolePermissions = map[string][]string{
+	RoleOwner: {
+		PermProductsRead, PermProductsWrite,
+		PermOrdersRead, PermOrdersWrite, PermOrdersDelete,
+		PermCustomersRead, PermStockRead, PermStaffManage,
+		PermAuditRead, PermPromosRead, PermPromosWrite,
+		PermEmailsRead, PermEmailsWrite, PermDeliveryRead, PermDeliveryWrite,
+		PermPaymentsRefund, PermPaymentsWrite, PermInvoices,
+	},
+	RoleManager: {
+		PermProductsRead, PermProductsWrite,
+		PermOrdersRead, PermOrdersWrite, PermOrdersDelete,
+		PermCustomersRead, PermStockRead, PermAuditRead,
+		PermPromosRead, PermPromosWrite, PermEmailsRead, PermEmailsWrite,
+		PermDeliveryRead, PermDeliveryWrite, PermPaymentsRefund, PermPaymentsWrite,
+		PermInvoices,
+	},
+	RoleSales: {
+		PermProductsRead,
+		PermOrdersRead, PermOrdersWrite,
+		PermCustomersRead, PermStockRead, PermPromosRead,
+		PermEmailsRead, PermDeliveryRead, PermPaymentsWrite, PermInvoices,
+	},
+	RoleWorkshop: {
+		PermOrdersRead, PermOrdersWrite,
+	},
+}
+
+// adminRoutePermissions leagă fiecare rută protejată de permisiunea necesară.
+// Rutele care lipsesc de aici sunt refuzate.
+var adminRoutePermissions = map[string]string{
+	"POST /api/admin/upload":                         PermProductsWrite,
+	"GET /api/admin/products":                        PermProductsRead,
+	"POST /api/admin/products":                       PermProductsWrite,
+	"GET /api/admin/products/{id}":                   PermProductsRead,
+	"PUT /api/admin/products/{id}":                   PermProductsWrite,
+	"DELETE /api/admin/products/{id}":                PermProductsWrite,
+	"POST /api/admin/products/{id}/images/reprocess": PermProductsWrite,
+	"GET /api/admin/products/{id}/price-history":     PermProductsRead,
+	"GET /api/admin/price-changes":                   PermProductsRead,
+	"POST /api/admin/price-changes":                  PermProductsWrite,
+	"GET /api/admin/price-changes/{id}":              PermProductsRead,
+	"DELETE /api/admin/price-changes/{id}":           PermProductsWrite,
+	"GET /api/admin/categories":                      PermProductsRead,
+	"POST /api/admin/categories":                     PermProductsWrite,
+	"PUT /api/admin/categories/{id}":                 PermProductsWrite,
+	"GET /api/admin/orders":                          PermOrdersRead,
+	"GET /api/admin/orders/{id}":                     PermOrdersRead,
+	"PUT /api/admin/orders/{id}":                     PermOrdersWrite,
+	"DELETE /api/admin/orders/{id}":                  PermOrdersDelete,
+	"GET /api/admin/orders/{id}/payments":            PermOrdersRead,
+	"POST /api/admin/orders/{id}/payments":           PermPaymentsWrite,
+	"POST /api/admin/payments/{id}/refund":           PermPaymentsRefund,
+	"GET /api/admin/orders/{id}/invoices":            PermInvoices,
+	"POST /api/admin/orders/{id}/invoices":           PermInvoices,
+	"GET /api/admin/invoices/{id}/pdf":               PermInvoices,
+	"GET /api/admin/promotions":                      PermPromosRead,
+	"POST /api/admin/promotions":                     PermPromosWrite,
+	"GET /api/admin/promotions/{id}":                 PermPromosRead,
+	"PUT /api/admin/promotions/{id}":                 PermPromosWrite,
+	"DELETE /api/admin/promotions/{id}":              PermPromosWrite,
+	"GET /api/admin/delivery-zones":                  PermDeliveryRead,
+	"POST /api/admin/delivery-zones":                 PermDeliveryWrite,
+	"GET /api/admin/delivery-zones/{id}":             PermDeliveryRead,
+	"PUT /api/admin/delivery-zones/{id}":             PermDeliveryWrite,
+	"DELETE /api/admin/delivery-zones/{id}":          PermDeliveryWrite,
+	"GET /api/admin/emails":                          PermEmailsRead,
+	"GET /api/admin/emails/{id}":                     PermEmailsRead,
+	"POST /api/admin/emails/{id}/resend":             PermEmailsWrite,
+	"GET /api/admin/stock-movements":                 PermStockRead,
+	"GET /api/admin/users":                           PermCustomersRead,
+	"GET /api/admin/staff":                           PermStaffManage,
+	"POST /api/admin/staff":                          PermStaffManage,
+	"GET /api/admin/staff/{id}":                      PermStaffManage,
+	"PUT /api/admin/staff/{id}":                      PermStaffManage,
+	"POST /api/admin/staff/{id}/reinvite":            PermStaffManage,
+	"GET /api/admin/audit":                           PermAuditRead,
+}
+
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func (a *AdminUser) Can(perm string) bool {
+	for _, p := range rolePermissions[a.Role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func routePermission(r *http.Request) (string, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+	perm, ok := adminRoutePermissions[r.Method+" "+tpl]
+	return perm, ok
+}
+
+// redactOrderPII ascunde datele clientului pentru rolurile fără acces la clienți:
+// atelierul vede produsele, statusul și notele, dar nu cine a comandat și unde se livrează
+func redactOrderPII(admin *AdminUser, order *Order) {
+	if admin == nil || admin.Can(PermCustomersRead) {
+		return
+	}
+	order.Name = ""
+	order.Email = ""
+	order.Phone = ""
+	order.Address = ""
+	order.City = ""
+	order.BillingCompany = ""
+	order.BillingFiscalCode = ""
+	order.User = User{}
+}
+
+// ensureBootstrapAdmin creează primul cont owner din ADMIN_USER/ADMIN_PASS
+// când tabela de administratori e goală.
+func ensureBootstrapAdmin() {
+	var count int64
+	if err := DB.Model(&AdminUser{}).Count(&count).Error; err != nil {
+		log.Println("Eroare la verificarea conturilor admin:", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	username := strings.ToLower(getEnv("ADMIN_USER", "admin"))
+	password := getEnv("ADMIN_PASS", "")
+	if len(password) < 8 {
+		log.Println("⚠️ Nu există conturi admin: setează ADMIN_PASS (minim 8 caractere) pentru a crea contul owner")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Eroare la hash-ul parolei admin:", err)
+		return
+	}
+
+	admin := AdminUser{
+		Username:     username,
+		Name:         username,
+		PasswordHash: string(hash),
+		Role:         RoleOwner,
+		IsActive:     true,
+	}
+	if err := DB.Create(&admin).Error; err != nil {
+		log.Println("Eroare la crearea contului owner:", err)
+		return
+	}
+	log.Printf("Cont owner creat: %s", username)
+}
+
+// --- Staff (React-Admin) ---
+func getAdminStaff(w http.ResponseWriter, r *http.Request) {
+	var staff []AdminUser
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	DB.Model(&AdminUser{}).Count(&total)
+
+	if err := DB.Order("id ASC").Offset(start).Limit(pageSize).Find(&staff).Error; err != nil {
+		http.Error(w, "Eroare la preluarea personalului", http.StatusInternalServerError)
+		return
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("staff %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(staff)
+}
+
+func getAdminStaffMember(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var admin AdminUser
+
+	if err := DB.First(&admin, id).Error; err != nil {
+		http.Error(w, "Contul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admin)
+}
+
+// issueAdminInvite generează tokenul de invitație și pune emailul în outbox,
+// în tranzacția primită
+func issueAdminInvite(tx *gorm.DB, admin *AdminUser) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(adminInviteTTL)
+
+	// o invitație retrimisă de owner anulează și dezactivarea anterioară
+	if err := tx.Model(admin).Updates(map[string]interface{}{
+		"invite_token_hash": hashToken(token),
+		"invite_expires_at": expires,
+		"disabled_at":       nil,
+	}).Error; err != nil {
+		return err
+	}
+
+	inviteURL := fmt.Sprintf("%s/admin/accept-invite?token=%s", getEnv("FRONTEND_URL", "http://localhost:5173"), token)
+	data := map[string]string{"Name": admin.Name, "Role": admin.Role, "URL": inviteURL}
+
+	return enqueueEmail(tx, OutboxEmail{
+		Template: "admin_invite",
+		ToEmail:  admin.Username,
+		ToName:   admin.Name,
+	}, defaultLocale, data)
+}
+
+func inviteAdminStaff(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+		Role  string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(strings.ToLower(req.Email))
+	if !validEmail(email) {
+		http.Error(w, "Email invalid", http.StatusBadRequest)
+		return
+	}
+	if !validRole(req.Role) {
+		http.Error(w, "Rol invalid", http.StatusBadRequest)
+		return
+	}
+
+	var exists AdminUser
+	if err := DB.Where("username = ?", email).First(&exists).Error; err == nil {
+		http.Error(w, "Există deja un cont cu acest email", http.StatusConflict)
+		return
+	}
+
+	inviter := currentAdmin(r)
+	admin := AdminUser{
+		Username:    email,
+		Name:        req.Name,
+		Role:        req.Role,
+		InvitedByID: &inviter.ID,
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		return issueAdminInvite(tx, &admin)
+	}); err != nil {
+		http.Error(w, "Eroare la crearea invitației", http.StatusInternalServerError)
+		return
+	}
+	wakeOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admin)
+}
+
+func reinviteAdminStaff(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var admin AdminUser
+
+	if err := DB.First(&admin, id).Error; err != nil {
+		http.Error(w, "Contul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+	if admin.PasswordHash != "" {
+		http.Error(w, "Contul este deja activat", http.StatusConflict)
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		return issueAdminInvite(tx, &admin)
+	}); err != nil {
+		http.Error(w, "Eroare la retrimiterea invitației", http.StatusInternalServerError)
+		return
+	}
+	wakeOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admin)
+}
+
+// updateAdminStaff schimbă rolul, numele sau dezactivează un cont.
+// Ultimul owner activ nu poate fi retrogradat sau dezactivat.
+func updateAdminStaff(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req struct {
+		Name     *string `json:"name"`
+		Role     *string `json:"role"`
+		IsActive *bool   `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+
+	errLastOwner := errors.New("Trebuie să rămână cel puțin un owner activ")
+	errNotActivated := errors.New("Contul nu și-a setat încă parola")
+
+	var admin AdminUser
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&admin, id).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if req.Name != nil {
+			updates["name"] = *req.Name
+		}
+		if req.Role != nil {
+			if !validRole(*req.Role) {
+				return errUnknownRole
+			}
+			updates["role"] = *req.Role
+		}
+		if req.IsActive != nil {
+			if *req.IsActive && admin.PasswordHash == "" {
+				return errNotActivated
+			}
+			updates["is_active"] = *req.IsActive
+			updates["disabled_at"] = nil
+			if !*req.IsActive {
+				// o invitație încă neacceptată nu trebuie să poată reactiva contul
+				updates["disabled_at"] = time.Now()
+				updates["invite_token_hash"] = ""
+				updates["invite_expires_at"] = nil
+			}
+		}
+
+		losesOwner := admin.Role == RoleOwner && admin.IsActive &&
+			((req.Role != nil && *req.Role != RoleOwner) || (req.IsActive != nil && !*req.IsActive))
+		if losesOwner {
+			var owners int64
+			if err := tx.Model(&AdminUser{}).
+				Where("role = ? AND is_active = ? AND id <> ?", RoleOwner, true, admin.ID).
+				Count(&owners).Error; err != nil {
+				return err
+			}
+			if owners == 0 {
+				return errLastOwner
+			}
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&admin).Updates(updates).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Contul nu a fost găsit", http.StatusNotFound)
+		case errors.Is(err, errUnknownRole), errors.Is(err, errNotActivated):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errLastOwner):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Eroare la actualizare", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	DB.First(&admin, admin.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admin)
+}
+
+// acceptAdminInvite setează parola pentru un cont invitat și îl activează (rută publică)
+func acceptAdminInvite(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "Token lipsă", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) < 8 {
+		http.Error(w, "Parola trebuie să aibă cel puțin 8 caractere", http.StatusBadRequest)
+		return
+	}
+
+	var admin AdminUser
+	if err := DB.Where("invite_token_hash = ? AND invite_expires_at > ? AND disabled_at IS NULL", hashToken(req.Token), time.Now()).
+		First(&admin).Error; err != nil {
+		http.Error(w, "Invitație invalidă sau expirată", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Eroare server", http.StatusInternalServerError)
+		return
+	}
+
+	// condiția se repetă la scriere: contul poate fi dezactivat cât se calculează hash-ul
+	result := DB.Model(&admin).
+		Where("invite_token_hash = ? AND disabled_at IS NULL", admin.InviteTokenHash).
+		Updates(map[string]interface{}{
+			"password_hash":     string(hash),
+			"is_active":         true,
+			"invite_token_hash": "",
+			"invite_expires_at": nil,
+		})
+	if result.Error != nil {
+		http.Error(w, "Eroare la activarea contului", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Invitație invalidă sau expirată", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":  "Cont activat. Te poți autentifica.",
+		"username": admin.Username,
+	})
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/gorilla/mux"
+)
+
+const requestIDKey contextKey = "requestID"
+
+// auditTarget descrie ce entitate modifică o rută și cum se încarcă starea ei din DB
+type auditTarget struct {
+	Entity string
+	Action string
+	Load   func(id string) (interface{}, error)
+}
+
+func loadAuditProduct(id string) (interface{}, error) {
+	var p Product
+	err := DB.Preload("Variants").First(&p, id).Error
+	return p, err
+}
+
+func loadAuditOrder(id string) (interface{}, error) {
+	var o Order
+	err := DB.Preload("Items").First(&o, id).Error
+	return o, err
+}
+
+func loadAuditCategory(id string) (interface{}, error) {
+	var c Category
+	err := DB.Select("id", "name", "deposit_percent", "created_at", "updated_at").First(&c, id).Error
+	return c, err
+}
+
+func loadAuditPromotion(id string) (interface{}, error) {
+	var p Promotion
+	err := DB.First(&p, id).Error
+	return p, err
+}
+
+func loadAuditPayment(id string) (interface{}, error) {
+	var p Payment
+	err := DB.First(&p, id).Error
+	return p, err
+}
+
+func loadAuditDeliveryZone(id string) (interface{}, error) {
+	var z DeliveryZone
+	err := DB.First(&z, id).Error
+	return z, err
+}
+
+func loadAuditPriceChange(id string) (interface{}, error) {
+	var c ScheduledPriceChange
+	err := DB.First(&c, id).Error
+	return c, err
+}
+
+func loadAuditEmail(id string) (interface{}, error) {
+	var e OutboxEmail
+	err := DB.Omit("html", "text").First(&e, id).Error
+	return e, err
+}
+
+func loadAuditStaff(id string) (interface{}, error) {
+	var a AdminUser
+	err := DB.First(&a, id).Error
+	return a, err
+}
+
+// auditRoutes: toate rutele admin care modifică date și sunt înregistrate în jurnal
+var auditRoutes = map[string]auditTarget{
+	"POST /api/admin/upload":                         {Entity: "upload", Action: "create"},
+	"POST /api/admin/products":                       {Entity: "product", Action: "create", Load: loadAuditProduct},
+	"PUT /api/admin/products/{id}":                   {Entity: "product", Action: "update", Load: loadAuditProduct},
+	"DELETE /api/admin/products/{id}":                {Entity: "product", Action: "delete", Load: loadAuditProduct},
+	"POST /api/admin/products/{id}/images/reprocess": {Entity: "product", Action: "reprocess_images", Load: loadAuditProduct},
+	"POST /api/admin/categories":                     {Entity: "category", Action: "create", Load: loadAuditCategory},
+	"PUT /api/admin/categories/{id}":                 {Entity: "category", Action: "update", Load: loadAuditCategory},
+	"PUT /api/admin/orders/{id}":                     {Entity: "order", Action: "update", Load: loadAuditOrder},
+	"DELETE /api/admin/orders/{id}":                  {Entity: "order", Action: "delete", Load: loadAuditOrder},
+	"POST /api/admin/price-changes":                  {Entity: "price_change", Action: "create", Load: loadAuditPriceChange},
+	"DELETE /api/admin/price-changes/{id}":           {Entity: "price_change", Action: "cancel", Load: loadAuditPriceChange},
+	"POST /api/admin/promotions":                     {Entity: "promotion", Action: "create", Load: loadAuditPromotion},
+	"PUT /api/admin/promotions/{id}":                 {Entity: "promotion", Action: "update", Load: loadAuditPromotion},
+	"DELETE /api/admin/promotions/{id}":              {Entity: "promotion", Action: "delete", Load: loadAuditPromotion},
+	"POST /api/admin/orders/{id}/payments":           {Entity: "order", Action: "record_payment", Load: loadAuditOrder},
+	"POST /api/admin/payments/{id}/refund":           {Entity: "payment", Action: "refund", Load: loadAuditPayment},
+	"POST /api/admin/orders/{id}/invoices":           {Entity: "order", Action: "issue_invoice", Load: loadAuditOrder},
+	"POST /api/admin/delivery-zones":                 {Entity: "delivery_zone", Action: "create", Load: loadAuditDeliveryZone},
+	"PUT /api/admin/delivery-zones/{id}":             {Entity: "delivery_zone", Action: "update", Load: loadAuditDeliveryZone},
+	"DELETE /api/admin/delivery-zones/{id}":          {Entity: "delivery_zone", Action: "delete", Load: loadAuditDeliveryZone},
+	"POST /api/admin/emails/{id}/resend":             {Entity: "email", Action: "resend", Load: loadAuditEmail},
+	"POST /api/admin/staff":                          {Entity: "staff", Action: "create", Load: loadAuditStaff},
+	"PUT /api/admin/staff/{id}":                      {Entity: "staff", Action: "update", Load: loadAuditStaff},
+	"POST /api/admin/staff/{id}/reinvite":            {Entity: "staff", Action: "reinvite", Load: loadAuditStaff},
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// captureWriter păstrează statusul și corpul răspunsului pentru jurnal
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	c.status = code
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// auditMiddleware scrie în AuditLog fiecare acțiune admin reușită de tip POST/PUT/DELETE
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, requestID))
+
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tpl, _ := route.GetPathTemplate()
+		target, ok := auditRoutes[r.Method+" "+tpl]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entityID := mux.Vars(r)["id"]
+		var before interface{}
+		if entityID != "" && target.Load != nil {
+			if v, err := target.Load(entityID); err == nil {
+				before = v
+			}
+		}
+
+		cw := &captureWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		if cw.status < 200 || cw.status >= 300 {
+			return
+		}
+
+		var after interface{}
+		if target.Action != "delete" {
+			if entityID == "" {
+				// la creare, ID-ul vine din răspuns
+				var created struct {
+					ID uint `json:"id"`
+				}
+				if json.Unmarshal(cw.body.Bytes(), &created) == nil && created.ID != 0 {
+					entityID = fmt.Sprint(created.ID)
+				}
+			}
+			if entityID != "" && target.Load != nil {
+				if v, err := target.Load(entityID); err == nil {
+					after = v
+				}
+			} else if target.Load == nil {
+				after = json.RawMessage(cw.body.Bytes())
+			}
+		}
+
+		entry := AuditLog{
+			Action:    target.Action,
+			Entity:    target.Entity,
+			EntityID:  entityID,
+			Before:    toAuditJSON(before),
+			After:     toAuditJSON(after),
+			Diff:      toAuditJSON(auditDiff(before, after)),
+			RequestID: requestID,
+			IP:        clientIP(r),
+		}
+		if admin := currentAdmin(r); admin != nil {
+			entry.AdminID = admin.ID
+			entry.AdminUsername = admin.Username
+		}
+
+		if err := DB.Create(&entry).Error; err != nil {
+			log.Printf("Eroare la scrierea în jurnalul de audit (%s %s): %v", r.Method, r.URL.Path, err)
+		}
+	})
+}
+
+func toAuditMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	m := map[string]interface{}{}
+	if json.Unmarshal(raw, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+func toAuditJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return json.RawMessage(raw)
+}
+
+// auditDiff întoarce doar câmpurile schimbate: {"price_cents": {"from": 100, "to": 120}}
+func auditDiff(before, after interface{}) map[string]interface{} {
+	b, a := toAuditMap(before), toAuditMap(after)
+	if b == nil && a == nil {
+		return nil
+	}
+
+	diff := map[string]interface{}{}
+	keys := map[string]bool{}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range keys {
+		if k == "updated_at" {
+			continue
+		}
+		if !reflect.DeepEqual(b[k], a[k]) {
+			diff[k] = map[string]interface{}{"from": b[k], "to": a[k]}
+		}
+	}
+	return diff
+}
+
+func getAuditLogs(w http.ResponseWriter, r *http.Request) {
+	var logs []AuditLog
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	// Filtru opțional {"entity": "product", "entity_id": "3", "admin_id": 1}
+	var filter struct {
+		Entity   string `json:"entity"`
+		EntityID string `json:"entity_id"`
+		AdminID  uint   `json:"admin_id"`
+		Action   string `json:"action"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+
+	q := DB.Model(&AuditLog{})
+	if filter.Entity != "" {
+		q = q.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		q = q.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.AdminID != 0 {
+		q = q.Where("admin_id = ?", filter.AdminID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+
+	q.Count(&total)
+
+	if err := q.Order("created_at DESC, id DESC").Offset(start).Limit(pageSize).Find(&logs).Error; err != nil {
+		http.Error(w, "Eroare la preluarea jurnalului", http.StatusInternalServerError)
+		return
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("audit %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(logs)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -107,20 +106,26 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 		VerificationExpiresAt: time.Now().Add(24 * time.Hour),
 	}
 
-	if err := DB.Create(&user).Error; err != nil {
-		httpError(w, http.StatusInternalServerError, "Eroare server")
-		return
-	}
-
-	// Trimitem e-mail-ul de confirmare
+	// Contul și e-mail-ul de confirmare se salvează împreună; trimiterea o face outbox-ul
 	verifyURL := fmt.Sprintf("%s/api/verify?token=%s", getEnv("APP_BASE_URL", "http://localhost:8080"), token)
-	subject := "Confirmă-ți adresa de email"
-	body := fmt.Sprintf("Salut %s,\n\nTe rugăm să confirmi contul tău accesând linkul de mai jos:\n\n%s\n\nLinkul expiră în 24 ore.\n\nMulțumim!", user.Name, verifyURL)
+	data := map[string]string{"Name": user.Name, "URL": verifyURL}
 
-	if err := SendEmail(user.Email, subject, body); err != nil {
-		// nu opri flow-ul — arată mesaj, dar loghează eroarea
-		log.Println("Eroare trimitere email verificare:", err)
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return enqueueEmail(tx, OutboxEmail{
+			Template: "verify_email",
+			ToEmail:  user.Email,
+			ToName:   user.Name,
+			UserID:   &user.ID,
+		}, defaultLocale, data)
+	}); err != nil {
+		log.Println("Eroare la crearea contului:", err)
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
 	}
+	wakeOutbox()
 
 	okJSON(w, map[string]string{
 		"message": "Cont creat. Verifică email-ul pentru confirmare.",
@@ -150,9 +155,8 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := issueToken(user.ID, user.Email)
-	if err != nil {
-		log.Println("Token generation error:", err)
+	if err := startSession(w, r, user); err != nil {
+		log.Println("Session creation error:", err)
 		httpError(w, http.StatusInternalServerError, "Eroare server")
 		return
 	}
@@ -161,16 +165,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	if len(items) > 0 {
 		mergeGuestCartToUser(w, r, user.ID)
 	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "authToken",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-		MaxAge:   24 * 60 * 60,
-	})
+	mergeGuestWishlistToUser(w, r, user.ID)
 
 	resp := authResp{
 		User: safeUser{
@@ -184,16 +179,17 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleLogout(w http.ResponseWriter, r *http.Request) {
-	// șterge cookie-ul
-	http.SetCookie(w, &http.Cookie{
-		Name:     "authToken",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-	})
+	// revocă sesiunea curentă, dacă tokenul mai e valid
+	if _, sessionID, err := parseAccessToken(r); err == nil {
+		revokeSession(DB, sessionID)
+	} else if cookie, err := r.Cookie(refreshCookieName); err == nil && cookie.Value != "" {
+		DB.Model(&Session{}).
+			Where("refresh_token_hash = ? AND revoked_at IS NULL", hashToken(cookie.Value)).
+			Update("revoked_at", time.Now())
+	}
+
+	// șterge cookie-urile
+	clearAuthCookie(w)
 	okJSON(w, map[string]string{"message": "Logged out"})
 }
 
@@ -223,104 +219,117 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(safeUser)
 }
 
-// --- JWT ---
-func issueToken(userID uint, email string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":   userID,
-		"email": email,
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
+type changePasswordReq struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// handleChangePassword schimbă parola și închide toate sesiunile; dispozitivul curent primește una nouă
+func handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(uint)
+
+	var req changePasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "JSON invalid")
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		panic("JWT_SECRET not set")
+	if len(req.NewPassword) < 6 {
+		httpError(w, http.StatusBadRequest, "Parola este prea scurtă")
+		return
 	}
-	return token.SignedString([]byte(secret))
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		httpError(w, http.StatusUnauthorized, "user not found")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)) != nil {
+		httpError(w, http.StatusUnauthorized, "Parola curentă este greșită")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password_hash", string(hash)).Error; err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	if err := startSession(w, r, user); err != nil {
+		clearAuthCookie(w)
+	}
+	okJSON(w, map[string]string{"message": "Parola a fost schimbată"})
 }
 
 // --- Middleware ---
 func authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Log all cookies for debugging
-		// for _, cookie := range r.Cookies() {
-		// 	log.Printf("Cookie: %s = %s\n", cookie.Name, cookie.Value)
-		// }
-
-		// Încearcă să citești token-ul din cookie
-		cookie, err := r.Cookie("authToken")
-		if err != nil {
-			// NU returna eroare, doar treci mai departe fără userID
-			next.ServeHTTP(w, r)
-			return
+		userID, sessionID, err := parseAccessToken(r)
+		if err == nil {
+			if _, err := activeSession(r, sessionID, userID); err == nil {
+				next.ServeHTTP(w, withSession(r, userID, sessionID))
+				return
+			}
 		}
 
-		tokenStr := cookie.Value
-
-		if tokenStr == "" {
-			next.ServeHTTP(w, r)
+		// Access token lipsă sau expirat: încercăm cu refresh tokenul
+		if session, err := rotateRefreshToken(w, r); err == nil {
+			next.ServeHTTP(w, withSession(r, session.UserID, session.ID))
 			return
 		}
 
-		// Parsează token-ul
-		tkn, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			if token.Method != jwt.SigningMethodHS256 {
-				return nil, errors.New("metodă de semnare neașteptată")
-			}
-			secret := os.Getenv("JWT_SECRET")
-			if secret == "" {
-				return nil, errors.New("JWT_SECRET not set")
-			}
-			return []byte(secret), nil
-		})
-
-		if err != nil {
-			// Șterge cookie-ul invalid
-			http.SetCookie(w, &http.Cookie{
-				Name:     "authToken",
-				Value:    "",
-				Path:     "/",
-				MaxAge:   -1,
-				HttpOnly: true,
-				Secure:   true,
-				SameSite: http.SameSiteNoneMode,
-			})
-			next.ServeHTTP(w, r)
-			return
+		// NU returna eroare, doar treci mai departe fără userID
+		if _, err := r.Cookie("authToken"); err == nil {
+			clearAuthCookie(w)
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		if !tkn.Valid {
-			// Șterge cookie-ul invalid
-			http.SetCookie(w, &http.Cookie{
-				Name:     "authToken",
-				Value:    "",
-				Path:     "/",
-				MaxAge:   -1,
-				HttpOnly: true,
-				Secure:   true,
-				SameSite: http.SameSiteNoneMode,
-			})
-			next.ServeHTTP(w, r)
-			return
-		}
+// parseAccessToken validează JWT-ul din cookie și întoarce utilizatorul și sesiunea
+func parseAccessToken(r *http.Request) (uint, uint, error) {
+	cookie, err := r.Cookie("authToken")
+	if err != nil || cookie.Value == "" {
+		return 0, 0, errSessionInvalid
+	}
 
-		claims, ok := tkn.Claims.(jwt.MapClaims)
-		if !ok {
-			next.ServeHTTP(w, r)
-			return
+	tkn, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("metodă de semnare neașteptată")
 		}
-
-		idFloat, ok := claims["sub"].(float64)
-		if !ok {
-			next.ServeHTTP(w, r)
-			return
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET not set")
 		}
-
-		userID := uint(idFloat)
-
-		// Adaugă userID-ul în context
-		ctx := context.WithValue(r.Context(), userIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		return []byte(secret), nil
 	})
+	if err != nil || !tkn.Valid {
+		return 0, 0, errSessionInvalid
+	}
+
+	claims, ok := tkn.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, 0, errSessionInvalid
+	}
+	idFloat, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, 0, errSessionInvalid
+	}
+	sidFloat, ok := claims["sid"].(float64)
+	if !ok {
+		return 0, 0, errSessionInvalid
+	}
+	return uint(idFloat), uint(sidFloat), nil
 }
 
 func requireAuth(next http.Handler) http.Handler {
@@ -335,6 +344,27 @@ func requireAuth(next http.Handler) http.Handler {
 }
 
 // --- Helpers ---
+func clearAuthCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "authToken",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    "",
+		Path:     "/api",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
 func okJSON(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(v)
@@ -249,9 +249,8 @@ func handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// issue our JWT
-	jwtToken, err := issueToken(user.ID, user.Email)
-	if err != nil {
+	// issue our JWT + refresh token
+	if err := startSession(w, r, user); err != nil {
 		httpError(w, http.StatusInternalServerError, "Token issue failed")
 		return
 	}
@@ -264,17 +263,8 @@ func handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 
 		mergeGuestCartToUser(w, r, user.ID)
 	}
+	mergeGuestWishlistToUser(w, r, user.ID)
 
-	// send JWT to frontend (cookie)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "authToken",
-		Value:    jwtToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-		MaxAge:   24 * 60 * 60,
-	})
 	frontendURL := getEnv("FRONTEND_URL", "http://localhost:5173")
 	http.Redirect(w, r, frontendURL+"/account", http.StatusSeeOther)
 }
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Starea unei linii din coș, recalculată la fiecare citire
+const (
+	CartLineOK          = "ok"
+	CartLineDeleted     = "deleted"     // produsul a fost șters din catalog
+	CartLineInactive    = "inactive"    // produsul a fost ascuns de pe site
+	CartLineUnavailable = "unavailable" // produs/variantă indisponibilă sau stoc insuficient
+)
+
+// CartLine e o linie din coș cu prețul curent și starea ei.
+// ID e setat doar pentru coșul din DB; oaspeții își identifică liniile după produs și variantă.
+type CartLine struct {
+	ID              uint            `json:"id,omitempty"`
+	ProductID       uint            `json:"productId"`
+	VariantID       *uint           `json:"variantId"`
+	Quantity        int             `json:"quantity"`
+	Product         *Product        `json:"product"`
+	Variant         *ProductVariant `json:"variant,omitempty"`
+	VariantLabel    string          `json:"variant_label,omitempty"`
+	Status          string          `json:"status"`
+	Message         string          `json:"message,omitempty"`
+	UnitPriceCents  int64           `json:"unit_price_cents"`
+	AddedPriceCents int64           `json:"added_price_cents,omitempty"`
+	PriceChanged    bool            `json:"price_changed"`
+	LineTotalCents  int64           `json:"line_total_cents"`
+	DiscountCents   int64           `json:"discount_cents"`
+}
+
+// CartSummary e coșul calculat de server. Liniile cu probleme nu intră în totaluri,
+// iar CanCheckout e false până când clientul le rezolvă.
+type CartSummary struct {
+	Items         []CartLine `json:"items"`
+	SubtotalCents int64      `json:"subtotal_cents"`
+	DiscountCents int64      `json:"discount_cents"`
+	// Delivery apare doar când clientul a indicat localitatea
+	DeliveryCents int64          `json:"delivery_cents"`
+	Delivery      *DeliveryQuote `json:"delivery,omitempty"`
+	DeliveryError string         `json:"delivery_error,omitempty"`
+	TotalCents    int64          `json:"total_cents"`
+	DepositCents  int64          `json:"deposit_cents"` // avansul cerut la plasarea comenzii
+	FreeDelivery  bool           `json:"free_delivery"`
+	PromoCode     string         `json:"promo_code,omitempty"`
+	PromoError    string         `json:"promo_error,omitempty"`
+	CanCheckout   bool           `json:"can_checkout"`
+}
+
+// cartUnitPrice e prețul unitar curent: prețul efectiv al produsului plus diferența variantei
+func cartUnitPrice(product Product, variant *ProductVariant, now time.Time) int64 {
+	unit, _ := effectivePrice(product, now)
+	if variant != nil {
+		unit += variant.PriceDeltaCents
+	}
+	return unit
+}
+
+// loadCartLines citește coșul curent: din DB pentru utilizatori, din cookie pentru vizitatori
+func loadCartLines(r *http.Request) ([]CartLine, error) {
+	lines := []CartLine{}
+	if userID, ok := r.Context().Value(userIDKey).(uint); ok {
+		var items []CartItem
+		if err := DB.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			lines = append(lines, CartLine{
+				ID:              it.ID,
+				ProductID:       it.ProductID,
+				VariantID:       it.VariantID,
+				Quantity:        it.Quantity,
+				AddedPriceCents: it.PriceCents,
+			})
+		}
+		return lines, nil
+	}
+
+	items, err := getGuestCart(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		lines = append(lines, CartLine{
+			ProductID:       it.ProductID,
+			VariantID:       it.VariantID,
+			Quantity:        it.Quantity,
+			AddedPriceCents: it.PriceCents,
+		})
+	}
+	return lines, nil
+}
+
+// checkCartLine încarcă produsul și varianta liniei și îi stabilește starea și prețul
+func checkCartLine(db *gorm.DB, line *CartLine, now time.Time) error {
+	var product Product
+	if err := db.Unscoped().First(&product, line.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			line.Status, line.Message = CartLineDeleted, "Produsul nu mai există"
+			return nil
+		}
+		return err
+	}
+	resolveProductImages(&product)
+	withEffectivePrice(&product)
+	line.Product = &product
+
+	switch {
+	case product.DeletedAt.Valid:
+		line.Status, line.Message = CartLineDeleted, "Produsul nu mai există"
+	case !product.IsActive:
+		line.Status, line.Message = CartLineInactive, "Produsul nu mai este disponibil pe site"
+	case !product.IsAvailable:
+		line.Status, line.Message = CartLineUnavailable, "Produsul nu este disponibil momentan"
+	}
+	if line.Status != "" {
+		return nil
+	}
+
+	variant, err := resolveVariant(db, product.ID, line.VariantID)
+	if err != nil {
+		if isVariantError(err) {
+			line.Status, line.Message = CartLineUnavailable, err.Error()
+			return nil
+		}
+		return err
+	}
+	line.Variant = variant
+
+	trackStock, stock := product.TrackStock, product.StockQty
+	if variant != nil {
+		line.VariantLabel = variantLabel(*variant)
+		trackStock, stock = variant.TrackStock, variant.StockQty
+	}
+	line.UnitPriceCents = cartUnitPrice(product, variant, now)
+	line.LineTotalCents = line.UnitPriceCents * int64(line.Quantity)
+	line.PriceChanged = line.AddedPriceCents > 0 && line.AddedPriceCents != line.UnitPriceCents
+
+	if trackStock && stock < line.Quantity {
+		line.Status = CartLineUnavailable
+		line.Message = fmt.Sprintf("Stoc insuficient: disponibil %d", stock)
+		return nil
+	}
+	line.Status = CartLineOK
+	return nil
+}
+
+// cartOptions sunt datele opționale din checkout care influențează totalul
+type cartOptions struct {
+	PromoCode string
+	Phone     string
+	City      string
+	UserID    *uint
+}
+
+// summarizeCart verifică liniile și calculează totalurile. Codul promoțional și
+// localitatea sunt opționale; un cod invalid sau o localitate fără livrare nu
+// sunt erori, motivul apare în PromoError / DeliveryError.
+func summarizeCart(db *gorm.DB, lines []CartLine, opts cartOptions) (CartSummary, error) {
+	now := time.Now()
+	summary := CartSummary{Items: lines, CanCheckout: len(lines) > 0}
+
+	valid := []int{}
+	orderItems := []OrderItem{}
+	for i := range lines {
+		line := &lines[i]
+		if err := checkCartLine(db, line, now); err != nil {
+			return summary, err
+		}
+		if line.Status != CartLineOK || line.PriceChanged {
+			summary.CanCheckout = false
+		}
+		if line.Status != CartLineOK {
+			continue
+		}
+		summary.SubtotalCents += line.LineTotalCents
+		valid = append(valid, i)
+		orderItems = append(orderItems, OrderItem{
+			ProductID:  line.ProductID,
+			VariantID:  line.VariantID,
+			Quantity:   line.Quantity,
+			PriceCents: line.UnitPriceCents,
+		})
+	}
+	summary.TotalCents = summary.SubtotalCents
+
+	if promoCode := normalizePromoCode(opts.PromoCode); promoCode != "" && len(orderItems) > 0 {
+		var promo Promotion
+		err := db.Where("code = ?", promoCode).First(&promo).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			summary.PromoError = "Cod promoțional invalid"
+		} else if err != nil {
+			return summary, err
+		} else {
+			res, err := evaluatePromotion(db, &promo, orderItems, opts.UserID, opts.Phone, now)
+			var promoErr *PromoError
+			switch {
+			case errors.As(err, &promoErr):
+				summary.PromoError = promoErr.Message
+			case err != nil:
+				return summary, err
+			default:
+				for j, i := range valid {
+					lines[i].DiscountCents = res.LineDiscounts[j]
+				}
+				summary.PromoCode = promo.Code
+				summary.DiscountCents = res.DiscountCents
+				summary.FreeDelivery = res.FreeDelivery
+				summary.TotalCents = res.TotalCents
+			}
+		}
+	}
+
+	if strings.TrimSpace(opts.City) != "" && len(orderItems) > 0 {
+		zone, err := findDeliveryZone(db, opts.City)
+		var deliveryErr *DeliveryError
+		switch {
+		case errors.As(err, &deliveryErr):
+			summary.DeliveryError = deliveryErr.Message
+			summary.CanCheckout = false
+		case err != nil:
+			return summary, err
+		case zone != nil:
+			pieces := 0
+			for _, it := range orderItems {
+				pieces += it.Quantity
+			}
+			quote := quoteDelivery(zone, pieces, summary.TotalCents, summary.FreeDelivery)
+			summary.Delivery = &quote
+			summary.DeliveryCents = quote.FeeCents
+		}
+	}
+
+	summary.TotalCents += summary.DeliveryCents
+
+	if len(orderItems) > 0 {
+		for j, i := range valid {
+			orderItems[j].DiscountCents = lines[i].DiscountCents
+		}
+		deposit := Order{Items: orderItems, TotalCents: summary.TotalCents}
+		if err := applyDeposit(db, &deposit); err != nil {
+			return summary, err
+		}
+		summary.DepositCents = deposit.DepositCents
+	}
+	return summary, nil
+}
+
+// cartSummaryResponse calculează și trimite coșul curent. Parametri opționali:
+// promo (cod promoțional), phone (pentru limita per client) și city (pentru livrare).
+func cartSummaryResponse(w http.ResponseWriter, r *http.Request, lines []CartLine) {
+	query := r.URL.Query()
+	opts := cartOptions{
+		PromoCode: query.Get("promo"),
+		Phone:     strings.TrimSpace(query.Get("phone")),
+		City:      query.Get("city"),
+	}
+	if id, ok := r.Context().Value(userIDKey).(uint); ok {
+		opts.UserID = &id
+	}
+	summary, err := summarizeCart(DB, lines, opts)
+	if err != nil {
+		log.Println("Eroare la calcularea coșului:", err)
+		http.Error(w, "Eroare la preluarea coșului", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// getCart: GET /api/cart?promo=&phone=&city=
+func getCart(w http.ResponseWriter, r *http.Request) {
+	lines, err := loadCartLines(r)
+	if err != nil {
+		http.Error(w, "Eroare la preluarea coșului", http.StatusInternalServerError)
+		return
+	}
+	cartSummaryResponse(w, r, lines)
+}
+
+// refreshCart: POST /api/cart/refresh
+// Clientul confirmă prețurile curente: liniile cu produse șterse sunt eliminate,
+// iar prețul memorat al celorlalte devine prețul de acum.
+func refreshCart(w http.ResponseWriter, r *http.Request) {
+	lines, err := loadCartLines(r)
+	if err != nil {
+		http.Error(w, "Eroare la preluarea coșului", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	kept := []CartLine{}
+	removedIDs := []uint{}
+	for _, line := range lines {
+		if err := checkCartLine(DB, &line, now); err != nil {
+			http.Error(w, "Eroare la actualizarea coșului", http.StatusInternalServerError)
+			return
+		}
+		if line.Status == CartLineDeleted {
+			if line.ID != 0 {
+				removedIDs = append(removedIDs, line.ID)
+			}
+		} else {
+			if line.UnitPriceCents > 0 {
+				line.AddedPriceCents = line.UnitPriceCents
+			}
+			kept = append(kept, CartLine{
+				ID:              line.ID,
+				ProductID:       line.ProductID,
+				VariantID:       line.VariantID,
+				Quantity:        line.Quantity,
+				AddedPriceCents: line.AddedPriceCents,
+			})
+		}
+	}
+
+	if userID, ok := r.Context().Value(userIDKey).(uint); ok {
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			for _, line := range kept {
+				if err := tx.Model(&CartItem{}).Where("id = ?", line.ID).
+					Update("price_cents", line.AddedPriceCents).Error; err != nil {
+					return err
+				}
+			}
+			if len(removedIDs) == 0 {
+				return nil
+			}
+			return tx.Where("user_id = ? AND id IN ?", userID, removedIDs).Delete(&CartItem{}).Error
+		})
+		if err != nil {
+			log.Printf("Eroare la actualizarea coșului pentru user %d: %v", userID, err)
+			http.Error(w, "Eroare la actualizarea coșului", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		items := make([]GuestCartItem, 0, len(kept))
+		for _, line := range kept {
+			items = append(items, GuestCartItem{
+				ProductID:  line.ProductID,
+				VariantID:  line.VariantID,
+				Quantity:   line.Quantity,
+				PriceCents: line.AddedPriceCents,
+			})
+		}
+		saveGuestCart(w, items)
+	}
+
+	cartSummaryResponse(w, r, kept)
+}
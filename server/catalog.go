@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// productSorts: valorile acceptate pentru ?sort= în catalog. Ultima coloană (id)
+// face ordinea stabilă între pagini când valorile de sortare sunt egale.
+var productSorts = map[string]string{
+	"newest":     "created_at DESC, id DESC",
+	"price_asc":  effectivePriceSQL("products") + " ASC, id ASC",
+	"price_desc": effectivePriceSQL("products") + " DESC, id DESC",
+	"name":       "name ASC, id ASC",
+}
+
+// dimensionFilters leagă parametrii de interval de coloanele în cm
+var dimensionFilters = []struct {
+	Param, Column string
+}{
+	{"width", "width_cm"},
+	{"depth", "depth_cm"},
+	{"height", "height_cm"},
+}
+
+var dimensionsPattern = regexp.MustCompile(`(\d+)\s*[xX×*]\s*(\d+)(?:\s*[xX×*]\s*(\d+))?`)
+
+// parseDimensions extrage lățime x adâncime x înălțime (cm) din textul liber
+// al produsului, ex. "220x95x85 cm" sau "L 300 × 160". Întoarce nil pentru lipsă.
+func parseDimensions(s string) (width, depth, height *int) {
+	m := dimensionsPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, nil, nil
+	}
+	atoi := func(v string) *int {
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil
+		}
+		return &n
+	}
+	return atoi(m[1]), atoi(m[2]), atoi(m[3])
+}
+
+func setProductDimensions(p *Product) {
+	p.WidthCm, p.DepthCm, p.HeightCm = parseDimensions(p.Dimensions)
+}
+
+// backfillProductDimensions completează coloanele numerice pentru produsele vechi
+func backfillProductDimensions() {
+	var products []Product
+	if err := DB.Where("dimensions <> '' AND width_cm IS NULL").Find(&products).Error; err != nil {
+		log.Println("Eroare la citirea dimensiunilor produselor:", err)
+		return
+	}
+	for _, p := range products {
+		setProductDimensions(&p)
+		if p.WidthCm == nil {
+			continue
+		}
+		DB.Model(&Product{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+			"width_cm":  p.WidthCm,
+			"depth_cm":  p.DepthCm,
+			"height_cm": p.HeightCm,
+		})
+	}
+}
+
+// intParam citește un întreg pozitiv din query
+func intParam(r *http.Request, name string) (int, bool) {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultLocale = "ro"
+
+// Limbile pentru care avem șabloane de email către clienți
+var supportedLocales = map[string]bool{"ro": true, "ru": true}
+
+// Emailurile trimise clientului, după evenimentul comenzii
+const (
+	EmailOrderReceived   = "received"
+	EmailOrderConfirmed  = "confirmed"
+	EmailOrderDelivering = "delivering"
+	EmailOrderDelivered  = "delivered"
+	EmailOrderCancelled  = "cancelled"
+)
+
+// statusEmails: statusurile pentru care clientul primește un email
+var statusEmails = map[string]string{
+	OrderConfirmed:  EmailOrderConfirmed,
+	OrderDelivering: EmailOrderDelivering,
+	OrderDelivered:  EmailOrderDelivered,
+	OrderCancelled:  EmailOrderCancelled,
+}
+
+// normalizeLocale acceptă "ro", "ru", "ru-RU" etc.; orice altceva devine română
+func normalizeLocale(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 2 && supportedLocales[s[:2]] {
+		return s[:2]
+	}
+	return defaultLocale
+}
+
+// requestLocale: limba explicită din comandă, altfel primul Accept-Language cunoscut
+func requestLocale(r *http.Request, explicit string) string {
+	if explicit != "" {
+		return normalizeLocale(explicit)
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if len(tag) >= 2 && supportedLocales[tag[:2]] {
+			return tag[:2]
+		}
+	}
+	return defaultLocale
+}
+
+type orderEmailItem struct {
+	Name      string
+	Variant   string
+	SKU       string
+	Quantity  int
+	UnitPrice string
+	LineTotal string
+}
+
+// orderEmailData e contextul șabloanelor order_status și admin_new_order
+type orderEmailData struct {
+	Kind      string
+	Note      string
+	OrderID   uint
+	Reference string
+	TrackURL  string // pagina publică de urmărire, precompletată cu referința
+	Name      string
+	Phone     string
+	Email     string
+	Address   string
+	City      string
+	Notes     string
+	PromoCode string
+	Items     []orderEmailItem
+	Subtotal  string
+	Discount  string
+	// Delivery e setat doar când comanda are o zonă de livrare
+	Delivery     string
+	DeliveryZone string
+	DeliveryFree bool
+	Total        string
+	// PaymentMethod e una din constantele Payment*; șabloanele o traduc
+	PaymentMethod string
+	// Deposit e setat doar pentru comenzile cu avans; Balance e restul de achitat la livrare
+	Deposit string
+	Paid    string
+	Balance string
+	// Invoice e numărul facturii atașate emailului
+	Invoice         string
+	InvoiceProforma bool
+}
+
+func formatMDL(cents int64) string {
+	return fmt.Sprintf("%.2f MDL", float64(cents)/100)
+}
+
+// newOrderEmailData pregătește sumele deja formatate; comanda trebuie să aibă Items.Product încărcate
+func newOrderEmailData(order Order, kind, note string) orderEmailData {
+	data := orderEmailData{
+		Kind:      kind,
+		Note:      note,
+		OrderID:   order.ID,
+		Reference: order.Reference,
+		Name:      order.Name,
+		Phone:     order.Phone,
+		Email:     order.Email,
+		Address:   order.Address,
+		City:      order.City,
+		Notes:     order.Notes,
+		PromoCode: order.PromoCode,
+		Total:     formatMDL(order.TotalCents),
+
+		PaymentMethod: order.PaymentMethod,
+	}
+	if order.Reference != "" {
+		data.TrackURL = orderTrackURL(order.Reference)
+	}
+	if order.DiscountCents > 0 {
+		data.Subtotal = formatMDL(order.SubtotalCents)
+		data.Discount = "-" + formatMDL(order.DiscountCents)
+	}
+	if order.DeliveryZoneID != nil {
+		data.Subtotal = formatMDL(order.SubtotalCents)
+		data.Delivery = formatMDL(order.DeliveryCents)
+		data.DeliveryZone = order.DeliveryZoneName
+		data.DeliveryFree = order.DeliveryCents == 0
+	}
+	if order.DepositCents > 0 {
+		data.Deposit = formatMDL(order.DepositCents)
+		covered := order.DepositCents
+		if order.PaidCents > 0 {
+			data.Paid = formatMDL(order.PaidCents)
+			if order.PaidCents > covered {
+				covered = order.PaidCents
+			}
+		}
+		data.Balance = formatMDL(order.TotalCents - covered)
+	}
+	for _, it := range order.Items {
+		data.Items = append(data.Items, orderEmailItem{
+			Name:      it.Product.Name,
+			Variant:   it.VariantLabel,
+			SKU:       it.VariantSKU,
+			Quantity:  it.Quantity,
+			UnitPrice: formatMDL(it.PriceCents),
+			LineTotal: formatMDL(it.PriceCents*int64(it.Quantity) - it.DiscountCents),
+		})
+	}
+	return data
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// DeliveryError e motivul pentru care nu putem livra la adresa dată, arătat clientului
+type DeliveryError struct {
+	Message string
+}
+
+func (e *DeliveryError) Error() string { return e.Message }
+
+var cityDiacritics = strings.NewReplacer(
+	"ă", "a", "â", "a", "î", "i", "ș", "s", "ş", "s", "ț", "t", "ţ", "t",
+)
+
+// normalizeCity compară localitățile fără diacritice, majuscule și prefixe ("mun.", "or.", "s.")
+func normalizeCity(s string) string {
+	s = cityDiacritics.Replace(strings.ToLower(strings.TrimSpace(s)))
+	for _, prefix := range []string{"mun.", "or.", "s.", "com."} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// findDeliveryZone caută zona activă care conține localitatea, apoi zona implicită.
+// Întoarce nil fără eroare dacă magazinul nu are nicio zonă configurată.
+func findDeliveryZone(db *gorm.DB, city string) (*DeliveryZone, error) {
+	var zones []DeliveryZone
+	if err := db.Where("is_active = ?", true).Order("id ASC").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+
+	target := normalizeCity(city)
+	if target == "" {
+		return nil, &DeliveryError{"Indică localitatea pentru livrare"}
+	}
+	var fallback *DeliveryZone
+	for i := range zones {
+		for _, c := range zones[i].Cities {
+			if normalizeCity(c) == target {
+				return &zones[i], nil
+			}
+		}
+		if zones[i].IsDefault && fallback == nil {
+			fallback = &zones[i]
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, &DeliveryError{fmt.Sprintf("Momentan nu livrăm în localitatea %s", strings.TrimSpace(city))}
+}
+
+// DeliveryQuote e costul livrării pentru un coș într-o zonă
+type DeliveryQuote struct {
+	ZoneID                uint   `json:"zone_id"`
+	ZoneName              string `json:"zone_name"`
+	FeeCents              int64  `json:"fee_cents"`
+	Free                  bool   `json:"free"`
+	FreeThresholdCents    int64  `json:"free_threshold_cents"`
+	RemainingForFreeCents int64  `json:"remaining_for_free_cents"`
+	LeadTimeDays          int    `json:"lead_time_days"`
+}
+
+// quoteDelivery calculează taxa pentru numărul de bucăți și suma după reduceri.
+// freeDelivery vine de la un cod promoțional de tip free_delivery.
+func quoteDelivery(zone *DeliveryZone, pieces int, amountCents int64, freeDelivery bool) DeliveryQuote {
+	quote := DeliveryQuote{
+		ZoneID:             zone.ID,
+		ZoneName:           zone.Name,
+		FreeThresholdCents: zone.FreeThresholdCents,
+		LeadTimeDays:       zone.LeadTimeDays,
+	}
+	if pieces < 1 {
+		return quote
+	}
+
+	underThreshold := zone.FreeThresholdCents == 0 || amountCents < zone.FreeThresholdCents
+	if underThreshold && zone.FreeThresholdCents > 0 {
+		quote.RemainingForFreeCents = zone.FreeThresholdCents - amountCents
+	}
+	if freeDelivery || !underThreshold {
+		quote.Free = true
+		return quote
+	}
+	quote.FeeCents = zone.BaseFeeCents + zone.PerItemFeeCents*int64(pieces-1)
+	quote.Free = quote.FeeCents == 0
+	return quote
+}
+
+// applyDelivery stabilește zona și taxa de livrare pe comandă și o adaugă la total.
+// Se apelează după applyPromotion, ca pragul de gratuitate să folosească suma redusă.
+func applyDelivery(db *gorm.DB, order *Order) error {
+	zone, err := findDeliveryZone(db, order.City)
+	if err != nil || zone == nil {
+		return err
+	}
+
+	pieces := 0
+	for _, it := range order.Items {
+		pieces += it.Quantity
+	}
+	quote := quoteDelivery(zone, pieces, order.TotalCents, order.FreeDelivery)
+
+	order.DeliveryZoneID = &zone.ID
+	order.DeliveryZoneName = zone.Name
+	order.DeliveryLeadDays = zone.LeadTimeDays
+	order.DeliveryCents = quote.FeeCents
+	order.TotalCents += quote.FeeCents
+	return nil
+}
+
+func withDeliveryZoneAmounts(z *DeliveryZone) {
+	z.BaseFee = float64(z.BaseFeeCents) / 100
+	z.PerItemFee = float64(z.PerItemFeeCents) / 100
+	z.FreeThreshold = float64(z.FreeThresholdCents) / 100
+}
+
+// getDeliveryZones: GET /api/delivery/zones (zonele active, pentru pagina de checkout)
+func getDeliveryZones(w http.ResponseWriter, r *http.Request) {
+	var zones []DeliveryZone
+	if err := DB.Where("is_active = ?", true).Order("id ASC").Find(&zones).Error; err != nil {
+		http.Error(w, "Eroare la preluarea zonelor de livrare", http.StatusInternalServerError)
+		return
+	}
+	for i := range zones {
+		withDeliveryZoneAmounts(&zones[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zones)
+}
+
+// getDeliveryQuote: GET /api/delivery/quote?city=&promo=&phone=
+// Calculează livrarea pentru coșul curent; răspunsul e același sumar ca la GET /api/cart.
+func getDeliveryQuote(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimSpace(r.URL.Query().Get("city")) == "" {
+		http.Error(w, "Indică localitatea pentru livrare", http.StatusBadRequest)
+		return
+	}
+	getCart(w, r)
+}
+
+// --- Admin ---
+
+type DeliveryZoneRequest struct {
+	Name          string   `json:"name"`
+	Cities        []string `json:"cities"`
+	IsDefault     bool     `json:"is_default"`
+	BaseFee       float64  `json:"base_fee"`
+	PerItemFee    float64  `json:"per_item_fee"`
+	FreeThreshold float64  `json:"free_threshold"`
+	LeadTimeDays  int      `json:"lead_time_days"`
+	IsActive      *bool    `json:"is_active"`
+}
+
+func (req *DeliveryZoneRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("Numele zonei este obligatoriu")
+	}
+	if req.BaseFee < 0 || req.PerItemFee < 0 || req.FreeThreshold < 0 || req.LeadTimeDays < 0 {
+		return errors.New("Valorile nu pot fi negative")
+	}
+
+	seen := map[string]bool{}
+	cities := []string{}
+	for _, c := range req.Cities {
+		c = strings.TrimSpace(c)
+		key := normalizeCity(c)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cities = append(cities, c)
+	}
+	if len(cities) == 0 && !req.IsDefault {
+		return errors.New("Adaugă cel puțin o localitate sau marchează zona ca implicită")
+	}
+	req.Cities = cities
+	return nil
+}
+
+func (req *DeliveryZoneRequest) apply(z *DeliveryZone) {
+	z.Name = req.Name
+	z.Cities = pq.StringArray(req.Cities)
+	z.IsDefault = req.IsDefault
+	z.BaseFeeCents = int64(math.Round(req.BaseFee * 100))
+	z.PerItemFeeCents = int64(math.Round(req.PerItemFee * 100))
+	z.FreeThresholdCents = int64(math.Round(req.FreeThreshold * 100))
+	z.LeadTimeDays = req.LeadTimeDays
+	if req.IsActive != nil {
+		z.IsActive = *req.IsActive
+	}
+}
+
+func getAdminDeliveryZones(w http.ResponseWriter, r *http.Request) {
+	var zones []DeliveryZone
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	// Filtru opțional {"q": "Chișinău", "is_active": true}; q caută și în localități
+	var filter struct {
+		Q        string `json:"q"`
+		IsActive *bool  `json:"is_active"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+
+	q := DB.Model(&DeliveryZone{})
+	if filter.Q != "" {
+		q = q.Where("(name ILIKE ? OR array_to_string(cities, ',') ILIKE ?)", "%"+filter.Q+"%", "%"+filter.Q+"%")
+	}
+	if filter.IsActive != nil {
+		q = q.Where("is_active = ?", *filter.IsActive)
+	}
+
+	q.Count(&total)
+
+	if err := q.Order("id ASC").Offset(start).Limit(pageSize).Find(&zones).Error; err != nil {
+		http.Error(w, "Eroare la preluarea zonelor de livrare", http.StatusInternalServerError)
+		return
+	}
+	for i := range zones {
+		withDeliveryZoneAmounts(&zones[i])
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("delivery-zones %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(zones)
+}
+
+func getAdminDeliveryZone(w http.ResponseWriter, r *http.Request) {
+	var zone DeliveryZone
+	if err := DB.First(&zone, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Zona de livrare nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	withDeliveryZoneAmounts(&zone)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zone)
+}
+
+// saveDeliveryZone verifică să nu existe o localitate în două zone active și
+// păstrează o singură zonă implicită.
+func saveDeliveryZone(w http.ResponseWriter, zone *DeliveryZone, create bool) {
+	var others []DeliveryZone
+	DB.Where("is_active = ? AND id <> ?", true, zone.ID).Find(&others)
+	if zone.IsActive {
+		for _, other := range others {
+			for _, c := range other.Cities {
+				for _, mine := range zone.Cities {
+					if normalizeCity(c) == normalizeCity(mine) {
+						http.Error(w, fmt.Sprintf("Localitatea %s aparține deja zonei %s", mine, other.Name), http.StatusConflict)
+						return
+					}
+				}
+			}
+		}
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if zone.IsDefault {
+			if err := tx.Model(&DeliveryZone{}).Where("id <> ? AND is_default = ?", zone.ID, true).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if create {
+			return tx.Create(zone).Error
+		}
+		return tx.Save(zone).Error
+	})
+	if err != nil {
+		log.Println("Eroare la salvarea zonei de livrare:", err)
+		http.Error(w, "Eroare la salvarea zonei de livrare", http.StatusInternalServerError)
+		return
+	}
+	withDeliveryZoneAmounts(zone)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zone)
+}
+
+func createAdminDeliveryZone(w http.ResponseWriter, r *http.Request) {
+	var req DeliveryZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zone := DeliveryZone{IsActive: true}
+	req.apply(&zone)
+	saveDeliveryZone(w, &zone, true)
+}
+
+func updateAdminDeliveryZone(w http.ResponseWriter, r *http.Request) {
+	var zone DeliveryZone
+	if err := DB.First(&zone, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Zona de livrare nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	var req DeliveryZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.apply(&zone)
+	saveDeliveryZone(w, &zone, false)
+}
+
+func deleteAdminDeliveryZone(w http.ResponseWriter, r *http.Request) {
+	var zone DeliveryZone
+	if err := DB.First(&zone, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Zona de livrare nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	if err := DB.Delete(&zone).Error; err != nil {
+		http.Error(w, "Eroare la ștergerea zonei de livrare", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zone)
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestQuoteDelivery(t *testing.T) {
+	zone := &DeliveryZone{
+		ID:                 3,
+		Name:               "Chișinău",
+		BaseFeeCents:       20000,
+		PerItemFeeCents:    5000,
+		FreeThresholdCents: 1000000,
+		LeadTimeDays:       2,
+	}
+	noThreshold := &DeliveryZone{ID: 4, Name: "Raioane", BaseFeeCents: 50000, PerItemFeeCents: 10000}
+	freeZone := &DeliveryZone{ID: 5, Name: "Ridicare din atelier"}
+
+	tests := []struct {
+		name          string
+		zone          *DeliveryZone
+		pieces        int
+		amount        int64
+		freeDelivery  bool
+		wantFee       int64
+		wantFree      bool
+		wantRemaining int64
+	}{
+		{name: "o bucată", zone: zone, pieces: 1, amount: 300000, wantFee: 20000, wantRemaining: 700000},
+		{name: "mai multe bucăți", zone: zone, pieces: 3, amount: 300000, wantFee: 30000, wantRemaining: 700000},
+		{name: "cu un ban sub prag", zone: zone, pieces: 1, amount: 999999, wantFee: 20000, wantRemaining: 1},
+		{name: "exact la prag", zone: zone, pieces: 2, amount: 1000000, wantFree: true},
+		{name: "peste prag", zone: zone, pieces: 5, amount: 2500000, wantFree: true},
+		{name: "cod de livrare gratuită", zone: zone, pieces: 2, amount: 100000, freeDelivery: true, wantFree: true, wantRemaining: 900000},
+		{name: "zonă fără prag", zone: noThreshold, pieces: 2, amount: 9000000, wantFee: 60000},
+		{name: "zonă fără taxă", zone: freeZone, pieces: 4, amount: 100000, wantFree: true},
+		{name: "coș gol", zone: zone, pieces: 0, amount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := quoteDelivery(tt.zone, tt.pieces, tt.amount, tt.freeDelivery)
+			if q.FeeCents != tt.wantFee || q.Free != tt.wantFree || q.RemainingForFreeCents != tt.wantRemaining {
+				t.Errorf("taxă %d, gratuit %v, rămas %d; vrem %d, %v, %d",
+					q.FeeCents, q.Free, q.RemainingForFreeCents, tt.wantFee, tt.wantFree, tt.wantRemaining)
+			}
+			if q.ZoneID != tt.zone.ID || q.LeadTimeDays != tt.zone.LeadTimeDays || q.FreeThresholdCents != tt.zone.FreeThresholdCents {
+				t.Errorf("datele zonei nu au fost copiate: %+v", q)
+			}
+		})
+	}
+}
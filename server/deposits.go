@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentProviderManual marchează plățile înregistrate de admin (numerar, transfer, terminal)
+const PaymentProviderManual = "manual"
+
+// Modurile în care atelierul poate încasa manual o plată
+var manualPaymentMethods = map[string]bool{
+	"cash":          true,
+	"bank_transfer": true,
+	"card_terminal": true,
+}
+
+// DepositDueError e returnată când comanda intră în producție fără avansul achitat
+type DepositDueError struct {
+	DueCents int64
+}
+
+func (e *DepositDueError) Error() string {
+	return fmt.Sprintf("Avansul nu este achitat: mai lipsesc %s", formatMDL(e.DueCents))
+}
+
+var errOrderClosed = errors.New("Comanda este anulată sau returnată")
+
+// PaymentAmountError e returnată când plata depășește restul de achitat
+type PaymentAmountError struct {
+	MaxCents int64
+}
+
+func (e *PaymentAmountError) Error() string {
+	if e.MaxCents == 0 {
+		return "Comanda este deja achitată integral"
+	}
+	return fmt.Sprintf("Suma depășește restul de plată (%s)", formatMDL(e.MaxCents))
+}
+
+func validDepositPercent(percent int) bool {
+	return percent >= 0 && percent <= 100
+}
+
+// depositPercent e procentul produsului sau, dacă nu e setat, cel al categoriei.
+// Produsul trebuie să aibă Category încărcată.
+func depositPercent(product Product) int {
+	if product.DepositPercent != nil {
+		return *product.DepositPercent
+	}
+	return product.Category.DepositPercent
+}
+
+// applyDeposit calculează avansul pe fiecare linie din valoarea ei după reduceri.
+// Livrarea nu intră în avans, se achită odată cu restul.
+func applyDeposit(db *gorm.DB, order *Order) error {
+	ids := make([]uint, 0, len(order.Items))
+	for _, it := range order.Items {
+		ids = append(ids, it.ProductID)
+	}
+	var products []Product
+	if err := db.Unscoped().Preload("Category").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return err
+	}
+	percents := make(map[uint]int, len(products))
+	for _, p := range products {
+		percents[p.ID] = depositPercent(p)
+	}
+
+	order.DepositCents = 0
+	for i := range order.Items {
+		it := &order.Items[i]
+		lineCents := it.PriceCents*int64(it.Quantity) - it.DiscountCents
+		it.DepositCents = (lineCents*int64(percents[it.ProductID]) + 50) / 100
+		order.DepositCents += it.DepositCents
+	}
+	if order.DepositCents > order.TotalCents {
+		order.DepositCents = order.TotalCents
+	}
+	return nil
+}
+
+// withPaymentTotals completează restul de plată și avansul rămas. O comandă
+// anulată sau returnată nu mai datorează nimic.
+func withPaymentTotals(order *Order) {
+	order.BalanceCents, order.DepositDueCents = 0, 0
+	if releasesStock(order.Status) {
+		return
+	}
+	if order.TotalCents > order.PaidCents {
+		order.BalanceCents = order.TotalCents - order.PaidCents
+	}
+	if order.DepositCents > order.PaidCents {
+		order.DepositDueCents = order.DepositCents - order.PaidCents
+	}
+}
+
+// amountDueNow e suma cerută la o plată online: avansul rămas, iar după avans tot restul
+func amountDueNow(order Order) int64 {
+	withPaymentTotals(&order)
+	if order.DepositDueCents > 0 {
+		return order.DepositDueCents
+	}
+	return order.BalanceCents
+}
+
+// orderPaymentStatus derivă starea plății din suma încasată
+func orderPaymentStatus(paidCents, totalCents int64) string {
+	switch {
+	case paidCents <= 0:
+		return OrderUnpaid
+	case paidCents >= totalCents:
+		return OrderPaid
+	default:
+		return OrderPartiallyPaid
+	}
+}
+
+// checkDepositPaid blochează intrarea în producție până la achitarea avansului
+func checkDepositPaid(order *Order, to string) error {
+	if to != OrderInProduction || order.DepositCents <= order.PaidCents {
+		return nil
+	}
+	return &DepositDueError{DueCents: order.DepositCents - order.PaidCents}
+}
+
+// recordOrderPayment adaugă o încasare la comandă, îi actualizează starea plății
+// și notează încasarea în istoric
+func recordOrderPayment(tx *gorm.DB, orderID uint, amountCents int64, paidAt time.Time, actor, note string) error {
+	var order Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return err
+	}
+	paidCents := order.PaidCents + amountCents
+	status := orderPaymentStatus(paidCents, order.TotalCents)
+	updates := map[string]interface{}{
+		"paid_cents":     paidCents,
+		"payment_status": status,
+	}
+	if status == OrderPaid {
+		updates["paid_at"] = paidAt
+	}
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		return err
+	}
+	return tx.Create(&OrderStatusEvent{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   order.Status,
+		Actor:      actor,
+		Note:       note,
+	}).Error
+}
+
+// migrateOrderPaidAmounts completează suma încasată la comenzile plătite
+// integral înainte de evidența avansurilor
+func migrateOrderPaidAmounts() {
+	res := DB.Model(&Order{}).
+		Where("payment_status = ? AND paid_cents = 0", OrderPaid).
+		Update("paid_cents", gorm.Expr("total_cents"))
+	if res.Error != nil {
+		log.Println("Eroare la migrarea sumelor încasate:", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("Completată suma încasată pentru %d comenzi", res.RowsAffected)
+	}
+}
+
+// recordAdminOrderPayment: POST /api/admin/orders/{id}/payments
+// {amount, method: cash|bank_transfer|card_terminal, note, paid_at}
+func recordAdminOrderPayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount float64    `json:"amount"`
+		Method string     `json:"method"`
+		Note   string     `json:"note"`
+		PaidAt *time.Time `json:"paid_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if !manualPaymentMethods[req.Method] {
+		http.Error(w, "Metodă de plată invalidă (cash, bank_transfer, card_terminal)", http.StatusBadRequest)
+		return
+	}
+	amountCents := amountToCents(req.Amount)
+	if amountCents <= 0 {
+		http.Error(w, "Suma trebuie să fie pozitivă", http.StatusBadRequest)
+		return
+	}
+	paidAt := time.Now()
+	if req.PaidAt != nil {
+		if req.PaidAt.After(paidAt) {
+			http.Error(w, "Data plății nu poate fi în viitor", http.StatusBadRequest)
+			return
+		}
+		paidAt = *req.PaidAt
+	}
+
+	actor := adminActor(r)
+	var payment Payment
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var order Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, mux.Vars(r)["id"]).Error; err != nil {
+			return err
+		}
+		if releasesStock(order.Status) {
+			return errOrderClosed
+		}
+		withPaymentTotals(&order)
+		if amountCents > order.BalanceCents {
+			return &PaymentAmountError{MaxCents: order.BalanceCents}
+		}
+
+		payment = Payment{
+			OrderID:     order.ID,
+			Provider:    PaymentProviderManual,
+			Method:      req.Method,
+			Status:      PaymentSucceeded,
+			AmountCents: amountCents,
+			Currency:    "MDL",
+			PaidAt:      &paidAt,
+			Note:        strings.TrimSpace(req.Note),
+			RecordedBy:  actor,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		note := fmt.Sprintf("Plată înregistrată: %s (%s)", formatMDL(amountCents), req.Method)
+		if payment.Note != "" {
+			note += " — " + payment.Note
+		}
+		return recordOrderPayment(tx, order.ID, amountCents, paidAt, actor, note)
+	})
+	if err != nil {
+		var amountErr *PaymentAmountError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Comanda nu a fost gasita", http.StatusNotFound)
+		case errors.Is(err, errOrderClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.As(err, &amountErr):
+			http.Error(w, amountErr.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("Eroare la înregistrarea plății pentru comanda %s: %v", mux.Vars(r)["id"], err)
+			http.Error(w, "Eroare la înregistrarea plății", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
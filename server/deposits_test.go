@@ -0,0 +1,148 @@
+package main
+
+import "testing"
+
+func intPtr(v int) *int { return &v }
+
+func TestApplyDeposit(t *testing.T) {
+	db := newTestDB(t, &Category{}, &Product{})
+	if err := db.Create(&[]Category{
+		{ID: 1, Name: "Canapele", DepositPercent: 30},
+		{ID: 2, Name: "Accesorii", DepositPercent: 0},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&[]Product{
+		{ID: 1, Name: "Canapea", CategoryID: 1, PriceCents: 33333},
+		{ID: 2, Name: "Pernă", CategoryID: 2, PriceCents: 1999},
+		{ID: 3, Name: "Pat la comandă", CategoryID: 2, PriceCents: 10001, DepositPercent: intPtr(50)},
+		{ID: 4, Name: "Fotoliu fără avans", CategoryID: 1, PriceCents: 20000, DepositPercent: intPtr(0)},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// un produs șters după plasarea comenzii păstrează avansul categoriei
+	if err := db.Delete(&Product{}, 4).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		items     []OrderItem
+		total     int64
+		wantLines []int64
+		wantTotal int64
+	}{
+		{
+			// 33333 * 30% = 9999.9, rotunjit la 10000
+			name:      "procentul categoriei, rotunjit la ban",
+			items:     []OrderItem{{ProductID: 1, Quantity: 1, PriceCents: 33333}},
+			total:     33333,
+			wantLines: []int64{10000},
+			wantTotal: 10000,
+		},
+		{
+			// 10001 * 50% = 5000.5, jumătatea se rotunjește în sus
+			name:      "procentul produsului are prioritate",
+			items:     []OrderItem{{ProductID: 3, Quantity: 1, PriceCents: 10001}},
+			total:     10001,
+			wantLines: []int64{5001},
+			wantTotal: 5001,
+		},
+		{
+			name:      "produs cu 0% într-o categorie cu avans",
+			items:     []OrderItem{{ProductID: 4, Quantity: 2, PriceCents: 20000}},
+			total:     40000,
+			wantLines: []int64{0},
+			wantTotal: 0,
+		},
+		{
+			// (2*33333 - 6667) * 30% = 17999.7 -> 18000
+			name:      "avansul se calculează după reducere",
+			items:     []OrderItem{{ProductID: 1, Quantity: 2, PriceCents: 33333, DiscountCents: 6667}},
+			total:     59999,
+			wantLines: []int64{18000},
+			wantTotal: 18000,
+		},
+		{
+			name: "mai multe linii, rotunjire pe fiecare",
+			items: []OrderItem{
+				{ProductID: 1, Quantity: 1, PriceCents: 33333},
+				{ProductID: 2, Quantity: 3, PriceCents: 1999},
+				{ProductID: 3, Quantity: 1, PriceCents: 10001},
+			},
+			total:     49331,
+			wantLines: []int64{10000, 0, 5001},
+			wantTotal: 15001,
+		},
+		{
+			name:      "avansul nu depășește totalul comenzii",
+			items:     []OrderItem{{ProductID: 3, Quantity: 1, PriceCents: 10001}},
+			total:     4000,
+			wantLines: []int64{5001},
+			wantTotal: 4000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := Order{Items: tt.items, TotalCents: tt.total}
+			if err := applyDeposit(db, &order); err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.wantLines {
+				if order.Items[i].DepositCents != want {
+					t.Errorf("linia %d: avans %d, vrem %d", i, order.Items[i].DepositCents, want)
+				}
+			}
+			if order.DepositCents != tt.wantTotal {
+				t.Errorf("avans total %d, vrem %d", order.DepositCents, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestOrderPaymentStatus(t *testing.T) {
+	tests := []struct {
+		paid, total int64
+		want        string
+	}{
+		{0, 100000, OrderUnpaid},
+		{-500, 100000, OrderUnpaid}, // după o rambursare integrală
+		{1, 100000, OrderPartiallyPaid},
+		{99999, 100000, OrderPartiallyPaid},
+		{100000, 100000, OrderPaid},
+		{100500, 100000, OrderPaid},
+		{0, 0, OrderUnpaid},
+	}
+	for _, tt := range tests {
+		if got := orderPaymentStatus(tt.paid, tt.total); got != tt.want {
+			t.Errorf("orderPaymentStatus(%d, %d) = %s, vrem %s", tt.paid, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestWithPaymentTotals(t *testing.T) {
+	tests := []struct {
+		name                     string
+		order                    Order
+		wantBalance, wantDeposit int64
+		wantDueNow               int64
+	}{
+		{"neachitată", Order{Status: OrderPending, TotalCents: 100000, DepositCents: 30000}, 100000, 30000, 30000},
+		{"avans parțial", Order{Status: OrderConfirmed, TotalCents: 100000, DepositCents: 30000, PaidCents: 10000}, 90000, 20000, 20000},
+		{"avans achitat", Order{Status: OrderInProduction, TotalCents: 100000, DepositCents: 30000, PaidCents: 30000}, 70000, 0, 70000},
+		{"achitată integral", Order{Status: OrderDelivered, TotalCents: 100000, DepositCents: 30000, PaidCents: 100000}, 0, 0, 0},
+		{"anulată", Order{Status: OrderCancelled, TotalCents: 100000, DepositCents: 30000, PaidCents: 10000}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			withPaymentTotals(&order)
+			if order.BalanceCents != tt.wantBalance || order.DepositDueCents != tt.wantDeposit {
+				t.Errorf("rest %d, avans rămas %d; vrem %d, %d", order.BalanceCents, order.DepositDueCents, tt.wantBalance, tt.wantDeposit)
+			}
+			if got := amountDueNow(tt.order); got != tt.wantDueNow {
+				t.Errorf("de plată acum %d, vrem %d", got, tt.wantDueNow)
+			}
+		})
+	}
+}
@@ -2,35 +2,143 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
 	"log"
+	"mime"
+	"mime/quotedprintable"
 	"net/http"
+	"net/mail"
+	"net/smtp"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
 	"time"
 )
 
-func sendEmail(order Order) error {
-	log.Printf("Order ID %d:", order.ID)
+// Message e un email gata de trimis; Text e obligatoriu, HTML și atașamentele opționale
+type Message struct {
+	To          string
+	ToName      string
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// Attachment e un fișier atașat emailului (ex. factura PDF)
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Mailer abstractizează furnizorul de email (SMTP, Brevo sau sink local)
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var Mail Mailer
+
+// InitMailer alege driverul după MAIL_DRIVER (smtp, brevo sau sink).
+// Fără configurare explicită: Brevo dacă are cheie, SMTP dacă are parolă, altfel sink.
+func InitMailer() {
+	if err := loadEmailTemplates(); err != nil {
+		log.Fatalf("Eroare la încărcarea șabloanelor de email: %v", err)
+	}
+
+	driver := getEnv("MAIL_DRIVER", "")
+	if driver == "" {
+		driver = "sink"
+		if os.Getenv("BREVO_API_KEY") != "" {
+			driver = "brevo"
+		} else if os.Getenv("EMAIL_APP_PASSWORD") != "" {
+			driver = "smtp"
+		}
+	}
 
-	apiKey := os.Getenv("BREVO_API_KEY")
-	if apiKey == "" {
-		return fmt.Errorf("BREVO_API_KEY not set")
+	from := mail.Address{Name: getEnv("EMAIL_FROM_NAME", "Simonia Luxury"), Address: os.Getenv("EMAIL_FROM")}
+
+	switch driver {
+	case "smtp":
+		Mail = &smtpMailer{
+			from:     from,
+			host:     getEnv("SMTP_HOST", "smtp.gmail.com"),
+			port:     getEnv("SMTP_PORT", "587"),
+			username: getEnv("SMTP_USERNAME", from.Address),
+			password: getEnv("SMTP_PASSWORD", os.Getenv("EMAIL_APP_PASSWORD")),
+		}
+	case "brevo":
+		Mail = &brevoMailer{from: from, apiKey: os.Getenv("BREVO_API_KEY")}
+	case "sink":
+		Mail = &sinkMailer{from: from, dir: getEnv("MAIL_SINK_DIR", "")}
+	default:
+		log.Fatalf("MAIL_DRIVER necunoscut: %s", driver)
+	}
+
+	log.Printf("Trimitere email: %s", driver)
+}
+
+// --- SMTP ---
+type smtpMailer struct {
+	from     mail.Address
+	host     string
+	port     string
+	username string
+	password string
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	data, err := buildMIMEMessage(m.from, msg)
+	if err != nil {
+		return err
 	}
+	auth := smtp.PlainAuth("", m.username, m.password, m.host)
+	return smtp.SendMail(m.host+":"+m.port, auth, m.from.Address, []string{msg.To}, data)
+}
 
+// --- Brevo (API HTTP) ---
+type brevoMailer struct {
+	from   mail.Address
+	apiKey string
+}
+
+func (m *brevoMailer) Send(msg Message) error {
 	emailRequest := map[string]interface{}{
 		"sender": map[string]string{
-			"name":  "Simonia Luxury",
-			"email": os.Getenv("EMAIL_FROM"),
+			"name":  m.from.Name,
+			"email": m.from.Address,
 		},
 		"to": []map[string]string{
 			{
-				"email": os.Getenv("EMAIL_TO"),
-				"name":  "Administrator",
+				"email": msg.To,
+				"name":  msg.ToName,
 			},
 		},
-		"subject":     fmt.Sprintf("Comandă Nouă #%d de la %s", order.ID, order.Name),
-		"htmlContent": buildOrderHTML(order),
+		"subject":     msg.Subject,
+		"textContent": msg.Text,
+	}
+	if msg.HTML != "" {
+		emailRequest["htmlContent"] = msg.HTML
+	}
+	if len(msg.Attachments) > 0 {
+		attachments := make([]map[string]string, 0, len(msg.Attachments))
+		for _, a := range msg.Attachments {
+			attachments = append(attachments, map[string]string{
+				"name":    a.Filename,
+				"content": base64.StdEncoding.EncodeToString(a.Data),
+			})
+		}
+		emailRequest["attachment"] = attachments
 	}
 
 	requestBody, err := json.Marshal(emailRequest)
@@ -49,7 +157,7 @@ func sendEmail(order Order) error {
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("api-key", apiKey)
+	req.Header.Set("api-key", m.apiKey)
 
 	client := &http.Client{
 		Timeout: 10 * time.Second,
@@ -62,7 +170,6 @@ func sendEmail(order Order) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Email trimis cu succes pentru order #%d", order.ID)
 		return nil
 	}
 
@@ -75,46 +182,192 @@ func sendEmail(order Order) error {
 	return fmt.Errorf("Brevo API error (status %d)", resp.StatusCode)
 }
 
-func buildOrderHTML(order Order) string {
-	return fmt.Sprintf(`
-		<h2>Comandă nouă #%d</h2>
-		<p><strong>Client:</strong> %s</p>
-		<p><strong>Telefon:</strong> %s</p>
-		<p><strong>Email client:</strong> <a href="mailto:%s">%s</a></p>
-		<p><strong>Adresă:</strong> %s</p>
-		<p><strong>Oraș:</strong> %s</p>
-		<p><strong>Note:</strong> %s</p>
-
-		<h3>Produse comandate:</h3>
-		<ul>
-			%s
-		</ul>
-
-		<h3>Total: %.2f MDL</h3>
-	`,
-		order.ID,
-		order.Name,
-		order.Phone,
-		order.Email,
-		order.Email,
-		order.Address,
-		order.City,
-		order.Notes,
-		generateProductsHTML(order.Items),
-		order.Total,
-	)
+// --- Sink: pentru dezvoltare, scrie mesajele în log și, opțional, ca .eml pe disc ---
+type sinkMailer struct {
+	from mail.Address
+	dir  string
 }
 
-// generateProductsHTML construiește lista produselor
-func generateProductsHTML(items []OrderItem) string {
-	html := ""
-	for _, item := range items {
-		html += fmt.Sprintf(
-			"<li>%s × %d — %.2f MDL</li>",
-			item.Product.Name,
-			item.Quantity,
-			item.Price*float64(item.Quantity),
-		)
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m *sinkMailer) Send(msg Message) error {
+	log.Printf("[mail] către %s | %s", msg.To, msg.Subject)
+	if m.dir == "" {
+		return nil
 	}
-	return html
+
+	data, err := buildMIMEMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o644)
+}
+
+// buildMIMEMessage construiește mesajul RFC 5322: antetele cu diacritice sunt
+// codate RFC 2047, părțile text/HTML în quoted-printable UTF-8, iar
+// atașamentele în base64, într-un multipart/mixed în jurul corpului.
+func buildMIMEMessage(from mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	to := mail.Address{Name: msg.ToName, Address: msg.To}
+
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(msg.Attachments) == 0 {
+		if err := writeMIMEBody(&buf, msg, hex.EncodeToString(id)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := "mix_" + hex.EncodeToString(id)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed)
+	fmt.Fprintf(&buf, "--%s\r\n", mixed)
+	if err := writeMIMEBody(&buf, msg, hex.EncodeToString(id)); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+	for _, a := range msg.Attachments {
+		fmt.Fprintf(&buf, "--%s\r\n", mixed)
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n", a.ContentType)
+		fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n", a.Filename)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", mixed)
+	return buf.Bytes(), nil
+}
+
+// writeMIMEBody scrie partea text sau, cu HTML, un multipart/alternative
+func writeMIMEBody(buf *bytes.Buffer, msg Message, id string) error {
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		return writeQuotedPrintable(buf, msg.Text)
+	}
+
+	boundary := "alt_" + id
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	parts := []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	}
+	for _, p := range parts {
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+		fmt.Fprintf(buf, "Content-Type: %s; charset=UTF-8\r\n", p.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(buf, p.body); err != nil {
+			return err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+	return nil
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, s string) error {
+	w := quotedprintable.NewWriter(buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// --- Șabloane ---
+
+// Fiecare email are, per limbă, templates/email/<limba>/<nume>.txt (definește
+// "subject" și corpul text) și opțional <nume>.html (definește "content",
+// inclus în layout.html). Definițiile din .txt sunt disponibile și în HTML,
+// ca textele comune să fie scrise o singură dată. Dacă limba cerută nu are
+// șablonul, se folosește româna.
+//
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var emailTemplates = map[string]*emailTemplate{}
+
+func loadEmailTemplates() error {
+	txtFiles, err := fs.Glob(emailTemplateFS, "templates/email/*/*.txt")
+	if err != nil {
+		return err
+	}
+
+	for _, txtFile := range txtFiles {
+		lang := path.Base(path.Dir(txtFile))
+		name := strings.TrimSuffix(path.Base(txtFile), ".txt")
+
+		t := &emailTemplate{}
+		if t.text, err = texttemplate.ParseFS(emailTemplateFS, txtFile); err != nil {
+			return err
+		}
+		if t.text.Lookup("subject") == nil {
+			return fmt.Errorf("%s nu definește \"subject\"", txtFile)
+		}
+
+		htmlFile := strings.TrimSuffix(txtFile, ".txt") + ".html"
+		if _, err := fs.Stat(emailTemplateFS, htmlFile); err == nil {
+			if t.html, err = htmltemplate.ParseFS(emailTemplateFS, "templates/email/layout.html", txtFile, htmlFile); err != nil {
+				return err
+			}
+		}
+
+		emailTemplates[lang+"/"+name] = t
+	}
+	return nil
+}
+
+// renderEmail întoarce subiectul și corpurile emailului, fără destinatar
+func renderEmail(lang, name string, data interface{}) (Message, error) {
+	t, ok := emailTemplates[normalizeLocale(lang)+"/"+name]
+	if !ok {
+		if t, ok = emailTemplates[defaultLocale+"/"+name]; !ok {
+			return Message{}, fmt.Errorf("șablon de email inexistent: %s", name)
+		}
+	}
+
+	var msg Message
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return msg, err
+	}
+	if err := t.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return msg, err
+	}
+	if t.html != nil {
+		if err := t.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+			return msg, err
+		}
+	}
+
+	msg.Subject = strings.TrimSpace(subject.String())
+	msg.Text = strings.TrimSpace(text.String()) + "\n"
+	msg.HTML = html.String()
+	return msg, nil
 }
@@ -19,25 +19,135 @@ type contextKey string
 const userIDKey contextKey = "userID"
 
 type OrderRequest struct {
-	Name    string `json:"name"`
-	Phone   string `json:"phone"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
-	City    string `json:"city"`
-	Notes   string `json:"notes"`
-	Items   []struct {
-		ProductID uint `json:"productId"`
-		Quantity  int  `json:"quantity"`
-	} `json:"items"`
+	Name      string `json:"name"`
+	Phone     string `json:"phone"`
+	Email     string `json:"email"`
+	Address   string `json:"address"`
+	City      string `json:"city"`
+	Notes     string `json:"notes"`
+	PromoCode string `json:"promoCode"`
+	// firma și IDNO-ul, pentru factură pe persoană juridică
+	CompanyName string `json:"companyName"`
+	FiscalCode  string `json:"fiscalCode"`
+	Locale      string `json:"locale"` // limba emailurilor către client (ro/ru)
+	// PaymentMethod: cash_on_delivery (implicit), bank_transfer sau card
+	PaymentMethod string            `json:"paymentMethod"`
+	Items         []CartLineRequest `json:"items"`
+}
+
+type CartLineRequest struct {
+	ProductID uint  `json:"productId"`
+	VariantID *uint `json:"variantId"`
+	Quantity  int   `json:"quantity"`
+}
+
+// OrderItemError e o problemă cu un produs din comandă, arătată clientului
+type OrderItemError struct {
+	Status  int
+	Message string
+}
+
+func (e *OrderItemError) Error() string { return e.Message }
+
+// priceOrderItems verifică produsele și variantele cerute cu aceleași reguli ca
+// rezumatul coșului și calculează prețul unitar curent (inclusiv reducerile active)
+// pentru fiecare linie. O linie care nu e „ok” (produs șters, ascuns, indisponibil
+// sau fără stoc) oprește comanda cu 409.
+func priceOrderItems(db *gorm.DB, lines []CartLineRequest) ([]OrderItem, error) {
+	items := make([]OrderItem, 0, len(lines))
+	now := time.Now()
+	for _, item := range lines {
+		if item.Quantity < 1 {
+			return nil, &OrderItemError{http.StatusBadRequest, "Cantitatea trebuie să fie cel puțin 1"}
+		}
+
+		line := CartLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+		if err := checkCartLine(db, &line, now); err != nil {
+			return nil, err
+		}
+		if line.Status != CartLineOK {
+			msg := line.Message
+			if line.Product != nil {
+				msg = fmt.Sprintf("%s: %s", line.Product.Name, line.Message)
+			}
+			return nil, &OrderItemError{http.StatusConflict, msg}
+		}
+
+		orderItem := OrderItem{
+			ProductID:  item.ProductID,
+			Quantity:   item.Quantity,
+			PriceCents: line.UnitPriceCents,
+		}
+		if line.Variant != nil {
+			orderItem.VariantID = &line.Variant.ID
+			orderItem.VariantSKU = line.Variant.SKU
+			orderItem.VariantLabel = line.VariantLabel
+		}
+		items = append(items, orderItem)
+	}
+	return items, nil
 }
 
 // --- Produse ---
+// getProducts: GET /api/products
+// Filtre: category_id sau category (nume), min_price/max_price (lei), available,
+// min_width/max_width, min_depth/max_depth, min_height/max_height (cm).
+// Sortare: sort=newest|price_asc|price_desc|name. Paginare: page, per_page.
+// Corpul rămâne un array; totalul vine în header-ul X-Total-Count.
 func getProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "newest"
+	}
+	order, ok := productSorts[sort]
+	if !ok {
+		http.Error(w, "Sortare invalidă", http.StatusBadRequest)
+		return
+	}
+	page, perPage := pageParams(r)
+
+	q := DB.Model(&Product{}).Where("is_active = ?", true)
+	if id, ok := intParam(r, "category_id"); ok && id > 0 {
+		q = q.Where("category_id = ?", id)
+	} else if name := query.Get("category"); name != "" {
+		q = q.Where("category_id IN (SELECT id FROM categories WHERE LOWER(name) = LOWER(?))", name)
+	}
+	if v, ok := priceParam(r, "min_price"); ok {
+		q = q.Where(effectivePriceSQL("products")+" >= ?", v)
+	}
+	if v, ok := priceParam(r, "max_price"); ok {
+		q = q.Where(effectivePriceSQL("products")+" <= ?", v)
+	}
+	if v, err := strconv.ParseBool(query.Get("available")); err == nil {
+		q = q.Where("is_available = ?", v)
+	}
+	for _, d := range dimensionFilters {
+		if v, ok := intParam(r, "min_"+d.Param); ok {
+			q = q.Where(d.Column+" >= ?", v)
+		}
+		if v, ok := intParam(r, "max_"+d.Param); ok {
+			q = q.Where(d.Column+" <= ?", v)
+		}
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		http.Error(w, "Eroare la preluarea produselor", http.StatusInternalServerError)
+		return
+	}
+
 	var products []Product
-	if err := DB.Preload("Category").Where("is_active = ?", true).Find(&products).Error; err != nil {
+	if err := q.Preload("Category").Order(order).Offset((page - 1) * perPage).Limit(perPage).Find(&products).Error; err != nil {
 		http.Error(w, "Eroare la preluarea produselor", http.StatusInternalServerError)
 		return
 	}
+	for i := range products {
+		resolveProductImages(&products[i])
+		withEffectivePrice(&products[i])
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(products)
 }
@@ -67,6 +177,17 @@ func createOrder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Numărul de telefon este obligatoriu pentru comandă", http.StatusBadRequest)
 		return
 	}
+	paymentMethod, err := validatePaymentMethod(req.PaymentMethod)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.CompanyName = strings.TrimSpace(req.CompanyName)
+	req.FiscalCode = strings.TrimSpace(req.FiscalCode)
+	if req.CompanyName != "" && !validFiscalCode(req.FiscalCode) {
+		http.Error(w, "IDNO-ul firmei trebuie să aibă 13 cifre", http.StatusBadRequest)
+		return
+	}
 
 	userID, ok := r.Context().Value(userIDKey).(uint)
 	var userIDPtr *uint
@@ -92,32 +213,108 @@ func createOrder(w http.ResponseWriter, r *http.Request) {
 		Address: req.Address,
 		City:    req.City,
 		Notes:   req.Notes,
-		Status:  "pending",
+		Status:  OrderPending,
+		Locale:  requestLocale(r, req.Locale),
+
+		PaymentMethod: paymentMethod,
+		PaymentStatus: OrderUnpaid,
+
+		BillingCompany: req.CompanyName,
+	}
+	if req.CompanyName != "" {
+		order.BillingFiscalCode = req.FiscalCode
 	}
 
-	var totalCents int64
-	for _, item := range req.Items {
-		var product Product
-		if err := DB.First(&product, item.ProductID).Error; err != nil {
-			http.Error(w, fmt.Sprintf("Produsul cu ID %d nu există", item.ProductID), http.StatusBadRequest)
+	if len(req.Items) == 0 {
+		http.Error(w, "Comanda nu conține produse", http.StatusBadRequest)
+		return
+	}
+
+	items, err := priceOrderItems(DB, req.Items)
+	if err != nil {
+		var itemErr *OrderItemError
+		if errors.As(err, &itemErr) {
+			http.Error(w, itemErr.Message, itemErr.Status)
 			return
 		}
+		http.Error(w, "Eroare la verificarea produselor", http.StatusInternalServerError)
+		return
+	}
+	order.Items = items
 
-		total := product.PriceCents * int64(item.Quantity)
-		totalCents += total
-
-		order.Items = append(order.Items, OrderItem{
-			ProductID:  item.ProductID,
-			Quantity:   item.Quantity,
-			PriceCents: product.PriceCents,
-		})
-
+	var subtotalCents int64
+	for _, item := range order.Items {
+		subtotalCents += item.PriceCents * int64(item.Quantity)
 	}
+	order.SubtotalCents = subtotalCents
+	order.TotalCents = subtotalCents
 
-	order.TotalCents = totalCents
-	order.Total = float64(totalCents) / 100
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var promo *Promotion
+		if req.PromoCode != "" {
+			var err error
+			if promo, err = applyPromotion(tx, req.PromoCode, &order); err != nil {
+				return err
+			}
+		}
+		if err := applyDelivery(tx, &order); err != nil {
+			return err
+		}
+		if err := applyDeposit(tx, &order); err != nil {
+			return err
+		}
+		order.Total = float64(order.TotalCents) / 100
 
-	if err := DB.Create(&order).Error; err != nil {
+		var err error
+		if order.Reference, err = newOrderReference(tx, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if promo != nil {
+			if err := redeemPromotion(tx, promo, &order); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&OrderStatusEvent{
+			OrderID:  order.ID,
+			ToStatus: OrderPending,
+			Actor:    orderActor(userIDPtr),
+		}).Error; err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			if err := reserveStock(tx, order.ID, item); err != nil {
+				return err
+			}
+		}
+		// emailurile intră în outbox în aceeași tranzacție cu comanda
+		var full Order
+		if err := tx.Preload("Items.Product").First(&full, order.ID).Error; err != nil {
+			return err
+		}
+		if err := enqueueAdminOrderEmail(tx, full); err != nil {
+			return err
+		}
+		return enqueueOrderEmail(tx, full, EmailOrderReceived, "")
+	})
+	if err != nil {
+		var stockErr *InsufficientStockError
+		if errors.As(err, &stockErr) {
+			http.Error(w, stockErr.Error(), http.StatusConflict)
+			return
+		}
+		var promoErr *PromoError
+		if errors.As(err, &promoErr) {
+			http.Error(w, promoErr.Error(), http.StatusBadRequest)
+			return
+		}
+		var deliveryErr *DeliveryError
+		if errors.As(err, &deliveryErr) {
+			http.Error(w, deliveryErr.Error(), http.StatusBadRequest)
+			return
+		}
 		log.Printf("Error creating order: %v", err)
 		http.Error(w, "Eroare la salvarea comenzii", http.StatusInternalServerError)
 		return
@@ -125,34 +322,24 @@ func createOrder(w http.ResponseWriter, r *http.Request) {
 
 	// Reîncarcă order-ul cu toate datele din baza de date
 	var completeOrder Order
-	if err := DB.Preload("Items.Product").First(&completeOrder, order.ID).Error; err != nil {
+	if err := DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("History", orderHistoryScope).First(&completeOrder, order.ID).Error; err != nil {
 		log.Printf("Error reloading order: %v", err)
 		completeOrder = order
 	}
+	withPaymentTotals(&completeOrder)
 
-	log.Printf("Sending email for order ID=%d, Address='%s', City='%s'",
-		completeOrder.ID, completeOrder.Address, completeOrder.City)
-
-	go func(order Order) {
-		if err := sendEmail(order); err != nil {
-			log.Printf("CRITICAL: Failed to send email for order #%d: %v", order.ID, err)
-
-			DB.Model(&Order{}).Where("id = ?", order.ID).
-				Update("email_sent", false).
-				Update("email_error", err.Error())
-		}
-	}(completeOrder)
+	wakeOutbox()
 
-	go func(orderID uint) {
-		time.Sleep(5 * time.Second)
-		if err := DB.Model(&Order{}).
-			Where("id = ?", orderID).
-			Update("status", "completed").Error; err != nil {
-			log.Printf("Eroare la actualizarea statusului pentru order #%d: %v", orderID, err)
-		} else {
-			log.Printf("Order #%d marcat ca 'completed'", orderID)
+	// plata cu cardul începe după commit; dacă procesatorul nu răspunde,
+	// comanda rămâne neplătită și clientul poate relua plata
+	if completeOrder.PaymentMethod == PaymentCard {
+		url, err := startPayment(completeOrder, clientIP(r))
+		if err != nil {
+			log.Printf("Eroare la inițierea plății pentru comanda %d: %v", completeOrder.ID, err)
+			completeOrder.PaymentError = "Plata nu a putut fi inițiată. Te vom contacta pentru confirmare."
 		}
-	}(completeOrder.ID)
+		completeOrder.PaymentURL = url
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(completeOrder)
@@ -167,7 +354,7 @@ func GetProductByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var product Product
-	if err := DB.First(&product, id).Error; err != nil {
+	if err := DB.Preload("Variants").First(&product, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			w.WriteHeader(http.StatusNotFound)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Produsul nu a fost găsit"})
@@ -176,30 +363,13 @@ func GetProductByID(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Eroare server", http.StatusInternalServerError)
 		return
 	}
+	resolveProductImages(&product)
+	withEffectivePrice(&product)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(product)
 }
 
-func SearchProducts(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("query")
-	var products []Product
-
-	if query == "" {
-		DB.Preload("Category").Where("is_active = ?", true).Find(&products)
-	} else {
-		searchPattern := "%" + strings.ToLower(query) + "%"
-
-		DB.Preload("Category").
-			Where("(unaccent(LOWER(name)) LIKE unaccent(LOWER(?)) OR unaccent(LOWER(description)) LIKE unaccent(LOWER(?))) AND is_active = ?",
-				searchPattern, searchPattern, true).
-			Find(&products)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
-}
-
 // --- Cart ---
 func getUserOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(uint)
@@ -209,11 +379,14 @@ func getUserOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var orders []Order
-	if err := DB.Preload("Items.Product").Where("user_id = ?", userID).Order("created_at DESC").Find(&orders).Error; err != nil {
+	if err := DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("History", orderHistoryScope).Where("user_id = ?", userID).Order("created_at DESC").Find(&orders).Error; err != nil {
 		log.Println("Error fetching orders:", err)
 		http.Error(w, "Eroare la fetch orders", http.StatusInternalServerError)
 		return
 	}
+	for i := range orders {
+		withPaymentTotals(&orders[i])
+	}
 	log.Printf("Orders fetched for user %d: %d orders found\n", userID, len(orders))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(orders)
@@ -223,27 +396,48 @@ func addToCart(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(uint)
 
 	var input struct {
-		ProductID uint `json:"productId"`
-		Quantity  int  `json:"quantity"`
+		ProductID uint  `json:"productId"`
+		VariantID *uint `json:"variantId"`
+		Quantity  int   `json:"quantity"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	if input.Quantity < 1 || input.Quantity > maxCartLineQuantity {
+		http.Error(w, fmt.Sprintf("Cantitatea trebuie să fie între 1 și %d", maxCartLineQuantity), http.StatusBadRequest)
+		return
+	}
+
+	var product Product
+	if err := DB.Where("id = ? AND is_active = ?", input.ProductID, true).First(&product).Error; err != nil {
+		http.Error(w, "Produsul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+
+	variant, err := resolveVariant(DB, input.ProductID, input.VariantID)
+	if err != nil {
+		if isVariantError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to check variant", http.StatusInternalServerError)
+		return
+	}
+	var variantID *uint
+	if variant != nil {
+		variantID = &variant.ID
+	}
+	priceCents := cartUnitPrice(product, variant, time.Now())
 
 	// Guest -> Cookies
 	if !ok {
 		items, _ := getGuestCart(r)
-		updated := false
-		for i, it := range items {
-			if it.ProductID == input.ProductID {
-				items[i].Quantity += input.Quantity
-				updated = true
-			}
-		}
-		if !updated {
-			items = append(items, GuestCartItem{ProductID: input.ProductID, Quantity: input.Quantity})
+		items = addGuestCartItem(items, input.ProductID, variantID, input.Quantity, priceCents)
+		if len(items) > maxGuestCartItems {
+			http.Error(w, fmt.Sprintf("Coșul poate conține cel mult %d produse", maxGuestCartItems), http.StatusConflict)
+			return
 		}
 		saveGuestCart(w, items)
 		json.NewEncoder(w).Encode(items)
@@ -251,17 +445,46 @@ func addToCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Logged-in -> DB
+	item, err := addUserCartItem(DB, userID, input.ProductID, variantID, input.Quantity, priceCents)
+	if err != nil {
+		http.Error(w, "Failed to add item to cart", http.StatusInternalServerError)
+		return
+	}
+	DB.Preload("Product").Preload("Variant").First(&item, item.ID)
+	json.NewEncoder(w).Encode(item)
+}
+
+// addGuestCartItem adaugă cantitatea la linia existentă sau creează una nouă.
+// priceCents e prețul văzut de client; 0 păstrează prețul memorat al liniei.
+func addGuestCartItem(items []GuestCartItem, productID uint, variantID *uint, quantity int, priceCents int64) []GuestCartItem {
+	for i, it := range items {
+		if it.ProductID == productID && sameVariant(it.VariantID, variantID) {
+			items[i].Quantity = clampCartQuantity(it.Quantity + quantity)
+			if priceCents > 0 {
+				items[i].PriceCents = priceCents
+			}
+			return items
+		}
+	}
+	return append(items, GuestCartItem{ProductID: productID, VariantID: variantID, Quantity: quantity, PriceCents: priceCents})
+}
+
+// addUserCartItem adaugă cantitatea în coșul din DB al utilizatorului
+func addUserCartItem(db *gorm.DB, userID, productID uint, variantID *uint, quantity int, priceCents int64) (CartItem, error) {
 	var item CartItem
-	err := DB.Where("user_id = ? AND product_id = ?", userID, input.ProductID).First(&item).Error
+	err := whereVariant(db.Where("user_id = ? AND product_id = ?", userID, productID), variantID).First(&item).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		item = CartItem{UserID: userID, ProductID: input.ProductID, Quantity: input.Quantity}
-		DB.Create(&item)
-	} else {
-		item.Quantity += input.Quantity
-		DB.Save(&item)
+		item = CartItem{UserID: userID, ProductID: productID, VariantID: variantID, Quantity: clampCartQuantity(quantity), PriceCents: priceCents}
+		return item, db.Create(&item).Error
 	}
-	DB.Preload("Product").First(&item, item.ID)
-	json.NewEncoder(w).Encode(item)
+	if err != nil {
+		return item, err
+	}
+	item.Quantity = clampCartQuantity(item.Quantity + quantity)
+	if priceCents > 0 {
+		item.PriceCents = priceCents
+	}
+	return item, db.Save(&item).Error
 }
 
 func updateCartItem(w http.ResponseWriter, r *http.Request) {
@@ -274,6 +497,7 @@ func updateCartItem(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
 	}
 
 	var req struct {
@@ -285,18 +509,19 @@ func updateCartItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Quantity < 1 {
-		http.Error(w, "Quantity must be at least 1", http.StatusBadRequest)
+	if req.Quantity < 1 || req.Quantity > maxCartLineQuantity {
+		http.Error(w, fmt.Sprintf("Cantitatea trebuie să fie între 1 și %d", maxCartLineQuantity), http.StatusBadRequest)
 		return
 	}
 
 	// Guest
 	if !ok {
 		items, _ := getGuestCart(r)
+		variantID := variantIDFromQuery(r.URL.Query().Get("variantId"))
 		found := false
 
 		for i, it := range items {
-			if int(it.ProductID) == itemIDInt {
+			if int(it.ProductID) == itemIDInt && sameVariant(it.VariantID, variantID) {
 				items[i].Quantity = req.Quantity
 				found = true
 				break
@@ -342,10 +567,11 @@ func removeCartItem(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		// Guest mode → remove from cookie
 		productID, _ := strconv.Atoi(itemID)
+		variantID := variantIDFromQuery(r.URL.Query().Get("variantId"))
 		items, _ := getGuestCart(r)
 		newItems := []GuestCartItem{}
 		for _, it := range items {
-			if int(it.ProductID) != productID {
+			if int(it.ProductID) != productID || !sameVariant(it.VariantID, variantID) {
 				newItems = append(newItems, it)
 			}
 		}
@@ -384,8 +610,9 @@ func syncCart(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
 		Items []struct {
-			ProductID uint `json:"productId"`
-			Quantity  int  `json:"quantity"`
+			ProductID uint  `json:"productId"`
+			VariantID *uint `json:"variantId"`
+			Quantity  int   `json:"quantity"`
 		} `json:"items"`
 	}
 
@@ -400,142 +627,65 @@ func syncCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, item := range input.Items {
-		var existing CartItem
-		if err := DB.Where("user_id = ? AND product_id = ?", userID, item.ProductID).First(&existing).Error; err == nil {
-			existing.Quantity += item.Quantity
-			DB.Save(&existing)
-		} else {
-			cartItem := CartItem{
-				UserID:    userID,
-				ProductID: item.ProductID,
-				Quantity:  item.Quantity,
-			}
-			DB.Create(&cartItem)
+		if item.Quantity < 1 {
+			continue
 		}
-	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Cart synced successfully"})
-}
-
-func getCart(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value(userIDKey).(uint)
-	if ok {
-		// Logged-in -> DB
-		var cartItems []CartItem
-		if err := DB.Preload("Product").Where("user_id = ?", userID).Find(&cartItems).Error; err != nil {
-			http.Error(w, "Failed to fetch cart", http.StatusInternalServerError)
-			return
+		var count int64
+		if DB.Model(&Product{}).Where("id = ?", item.ProductID).Count(&count); count == 0 {
+			continue
 		}
-
-		result := []map[string]interface{}{}
-		for _, item := range cartItems {
-			result = append(result, map[string]interface{}{
-				"id":        item.ID,
-				"productId": item.ProductID,
-				"quantity":  item.Quantity,
-				"product":   item.Product,
-			})
+		// varianta vine de la client: o păstrăm doar dacă aparține produsului și e disponibilă
+		variant, err := resolveVariant(DB, item.ProductID, item.VariantID)
+		if err != nil {
+			continue
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
-		return
-	}
-
-	// Guest -> Cookie
-	items, _ := getGuestCart(r)
-	result := []map[string]interface{}{}
-	for _, it := range items {
-		var prod Product
-		if err := DB.First(&prod, it.ProductID).Error; err == nil {
-			result = append(result, map[string]interface{}{
-				"id":        it.ProductID,
-				"productId": it.ProductID,
-				"quantity":  it.Quantity,
-				"product":   prod,
-				"tempId":    fmt.Sprintf("guest-%d", it.ProductID),
-			})
+		var variantID *uint
+		if variant != nil {
+			variantID = &variant.ID
 		}
+		addUserCartItem(DB, userID, item.ProductID, variantID, item.Quantity, 0)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Cart synced successfully"})
 }
 
 func mergeGuestCartToUser(w http.ResponseWriter, r *http.Request, userID uint) {
-	log.Println("mergeGuestCartToUser CALLED!")
-	log.Printf("UserID: %d", userID)
-	log.Printf("Request URL: %s", r.URL.Path)
-
-	// 1. Get guest cart
 	items, err := getGuestCart(r)
 	if err != nil {
 		log.Printf("Error getting guest cart: %v", err)
 		return
 	}
-
-	log.Printf("Guest cart items count: %d", len(items))
-
-	// 2. DEBUG: Log each item
-	for i, item := range items {
-		log.Printf("Item %d: ProductID=%d, Quantity=%d", i+1, item.ProductID, item.Quantity)
-
-		// Verify product exists
-		var product Product
-		if err := DB.First(&product, item.ProductID).Error; err != nil {
-			log.Printf("Product %d not found in DB!", item.ProductID)
-			continue
-		}
-		log.Printf("Product %d exists: %s", item.ProductID, product.Name)
-	}
-
 	if len(items) == 0 {
-		log.Println("No items to migrate")
 		return
 	}
 
-	// 3. Migrate each item
 	migratedCount := 0
 	for _, item := range items {
-		// Check if product exists
+		// produsele șterse sau dezactivate între timp rămân în afara coșului
 		var product Product
-		if err := DB.First(&product, item.ProductID).Error; err != nil {
-			log.Printf("Skipping product %d - not found", item.ProductID)
+		if err := DB.First(&product, item.ProductID).Error; err != nil || !product.IsActive {
+			log.Printf("Skipping product %d - not found or inactive", item.ProductID)
 			continue
 		}
-
-		// Check if already in user's cart
-		var existing CartItem
-		if err := DB.Where("user_id = ? AND product_id = ?", userID, item.ProductID).
-			First(&existing).Error; err == nil {
-			// Update existing
-			oldQty := existing.Quantity
-			existing.Quantity += item.Quantity
-			if err := DB.Save(&existing).Error; err != nil {
-				log.Printf("Error updating cart item: %v", err)
-			} else {
-				log.Printf("Updated: product %d, %d -> %d",
-					item.ProductID, oldQty, existing.Quantity)
-				migratedCount++
-			}
-		} else {
-			// Create new
-			cartItem := CartItem{
-				UserID:    userID,
-				ProductID: item.ProductID,
-				Quantity:  item.Quantity,
-			}
-			if err := DB.Create(&cartItem).Error; err != nil {
-				log.Printf("Error creating cart item: %v", err)
-			} else {
-				log.Printf("Created: product %d, quantity %d",
-					item.ProductID, item.Quantity)
-				migratedCount++
-			}
+		// cookie-ul poate fi vechi: varianta trebuie să aparțină produsului și să fie disponibilă
+		variant, err := resolveVariant(DB, item.ProductID, item.VariantID)
+		if err != nil {
+			log.Printf("Skipping product %d - variant: %v", item.ProductID, err)
+			continue
+		}
+		var variantID *uint
+		if variant != nil {
+			variantID = &variant.ID
+		}
+		if _, err := addUserCartItem(DB, userID, item.ProductID, variantID, item.Quantity, item.PriceCents); err != nil {
+			log.Printf("Error merging cart item: %v", err)
+			continue
 		}
+		migratedCount++
 	}
 
-	// 4. Clear guest cart
+	// Clear guest cart
 	saveGuestCart(w, []GuestCartItem{})
-	log.Printf("MIGRATION COMPLETE: %d/%d items migrated", migratedCount, len(items))
+	log.Printf("Guest cart merged for user %d: %d/%d items", userID, migratedCount, len(items))
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMergeGuestCartToUser(t *testing.T) {
+	withGuestCookieSecret(t)
+	db := newTestDB(t, &Product{}, &ProductVariant{}, &CartItem{})
+
+	products := []Product{
+		{ID: 1, Name: "Masă"},
+		{ID: 2, Name: "Canapea"},
+		{ID: 3, Name: "Fotoliu dezactivat"},
+		{ID: 4, Name: "Scaun șters"},
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&Product{}).Where("id = ?", 3).Update("is_active", false)
+	db.Delete(&Product{}, 4)
+	variants := []ProductVariant{
+		{ID: 10, ProductID: 2, SKU: "CANAPEA-GRI", IsAvailable: true},
+		{ID: 11, ProductID: 2, SKU: "CANAPEA-ROSU", IsAvailable: true},
+	}
+	if err := db.Create(&variants).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&ProductVariant{}).Where("id = ?", 11).Update("is_available", false)
+
+	guest := []GuestCartItem{
+		{ProductID: 1, Quantity: 1},
+		{ProductID: 2, VariantID: uintPtr(10), Quantity: 2},
+		{ProductID: 2, Quantity: 1},                         // produs cu variante, fără variantă
+		{ProductID: 2, VariantID: uintPtr(11), Quantity: 1}, // variantă indisponibilă
+		{ProductID: 1, VariantID: uintPtr(10), Quantity: 1}, // variantă a altui produs
+		{ProductID: 3, Quantity: 1},
+		{ProductID: 4, Quantity: 1},
+		{ProductID: 99, Quantity: 1},
+	}
+	cookies := httptest.NewRecorder()
+	saveGuestCart(cookies, guest)
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	for _, c := range cookies.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	mergeGuestCartToUser(httptest.NewRecorder(), r, 7)
+
+	var items []CartItem
+	db.Where("user_id = ?", 7).Order("product_id").Find(&items)
+	if len(items) != 2 {
+		t.Fatalf("%d linii în coș, vrem 2: %+v", len(items), items)
+	}
+	if items[0].ProductID != 1 || items[0].VariantID != nil {
+		t.Errorf("prima linie %+v, vrem masa fără variantă", items[0])
+	}
+	if items[1].ProductID != 2 || !sameVariant(items[1].VariantID, uintPtr(10)) || items[1].Quantity != 2 {
+		t.Errorf("a doua linie %+v, vrem canapeaua gri x2", items[1])
+	}
+}
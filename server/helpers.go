@@ -1,67 +1,254 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"net/http"
-	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limite pentru coșul oaspeților; aceleași limite de cantitate se aplică și în DB
+const (
+	maxGuestCartItems   = 30
+	maxCartLineQuantity = 99
 )
 
 // În backend - funcții pentru guest cart
 type GuestCartItem struct {
-	ProductID uint `json:"productId"`
-	Quantity  int  `json:"quantity"`
+	ProductID  uint  `json:"productId"`
+	VariantID  *uint `json:"variantId,omitempty"`
+	Quantity   int   `json:"quantity"`
+	PriceCents int64 `json:"priceCents,omitempty"`
 }
 
-func getGuestCart(r *http.Request) ([]GuestCartItem, error) {
-	cookie, err := r.Cookie("guestCart")
-	if err != nil {
-		log.Println("ℹ️ No guestCart cookie found")
-		return []GuestCartItem{}, nil
-	}
+// --- Cookie-uri semnate ---
 
-	log.Println("📦 guestCart cookie raw value:", cookie.Value)
+// Cookie-urile oaspeților au forma "<payload base64url>.<HMAC-SHA256 base64url>".
+// Cheia vine din GUEST_COOKIE_SECRET sau e derivată din JWT_SECRET.
+var guestCookieSecret []byte
 
-	// Decode URL encoding
-	decodedValue, err := url.QueryUnescape(cookie.Value)
-	if err != nil {
-		log.Println("❌ Error URL decoding guestCart cookie:", err)
-		return []GuestCartItem{}, err
+// InitGuestCookies pregătește cheia cookie-urilor semnate; fără niciun secret
+// semnăturile ar putea fi calculate de oricine, așa că serverul nu pornește
+func InitGuestCookies() {
+	if secret := os.Getenv("GUEST_COOKIE_SECRET"); secret != "" {
+		guestCookieSecret = []byte(secret)
+		return
+	}
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("Setează GUEST_COOKIE_SECRET sau JWT_SECRET pentru semnarea cookie-urilor oaspeților")
 	}
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("guest-cookie"))
+	guestCookieSecret = mac.Sum(nil)
+}
 
-	log.Println("📦 guestCart cookie decoded value:", decodedValue)
+func signCookieValue(name string, payload []byte) string {
+	mac := hmac.New(sha256.New, guestCookieSecret)
+	mac.Write([]byte(name + "|"))
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
 
-	var items []GuestCartItem
-	if err := json.Unmarshal([]byte(decodedValue), &items); err != nil {
-		log.Println("❌ Error parsing guestCart cookie as JSON:", err)
-		return []GuestCartItem{}, err
+// verifyCookieValue întoarce payload-ul doar dacă semnătura e validă.
+// Numele cookie-ului intră în semnătură, ca valorile să nu poată fi mutate între cookie-uri.
+func verifyCookieValue(name, value string) ([]byte, bool) {
+	encPayload, encSig, found := strings.Cut(value, ".")
+	if !found {
+		return nil, false
 	}
-
-	log.Printf("📦 Parsed %d guest cart items", len(items))
-	for i, item := range items {
-		log.Printf("  Item %d: ProductID=%d, Quantity=%d", i, item.ProductID, item.Quantity)
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, false
 	}
-
-	return items, nil
-}
-func saveGuestCart(w http.ResponseWriter, items []GuestCartItem) {
-	jsonData, err := json.Marshal(items)
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
 	if err != nil {
-		log.Println("❌ Error marshaling guest cart:", err)
-		return
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, guestCookieSecret)
+	mac.Write([]byte(name + "|"))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, false
 	}
+	return payload, true
+}
 
-	// NU mai face URL encoding aici - frontend-ul deja face
-	cookieValue := string(jsonData)
-	log.Println("💾 Saving guest cart (no encoding):", cookieValue)
+func readSignedCookie(r *http.Request, name string) ([]byte, bool) {
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return nil, false
+	}
+	payload, ok := verifyCookieValue(name, cookie.Value)
+	if !ok {
+		log.Printf("Cookie %s cu semnătură invalidă, ignorat", name)
+	}
+	return payload, ok
+}
 
+func setSignedCookie(w http.ResponseWriter, name string, payload []byte, maxAge int) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "guestCart",
-		Value:    cookieValue,
+		Name:     name,
+		Value:    signCookieValue(name, payload),
 		Path:     "/",
-		MaxAge:   30 * 24 * 3600,
+		MaxAge:   maxAge,
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteNoneMode,
 	})
 }
+
+// parseProductRef citește "12" sau "12.5" (produs.variantă)
+func parseProductRef(s string) (uint, *uint, bool) {
+	p, v, hasVariant := strings.Cut(s, ".")
+	productID, err := strconv.ParseUint(p, 10, 32)
+	if err != nil || productID == 0 {
+		return 0, nil, false
+	}
+	if !hasVariant {
+		return uint(productID), nil, true
+	}
+	variantID, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || variantID == 0 {
+		return 0, nil, false
+	}
+	vid := uint(variantID)
+	return uint(productID), &vid, true
+}
+
+func formatProductRef(productID uint, variantID *uint) string {
+	if variantID != nil && *variantID != 0 {
+		return fmt.Sprintf("%d.%d", productID, *variantID)
+	}
+	return strconv.FormatUint(uint64(productID), 10)
+}
+
+// --- Coșul oaspeților: "12:2:450000,15.3:1" (produs[.variantă]:cantitate[:preț unitar]) ---
+
+// clampCartQuantity ține cantitatea unei linii între 1 și maxCartLineQuantity
+func clampCartQuantity(q int) int {
+	if q < 1 {
+		return 1
+	}
+	if q > maxCartLineQuantity {
+		return maxCartLineQuantity
+	}
+	return q
+}
+
+func getGuestCart(r *http.Request) ([]GuestCartItem, error) {
+	payload, ok := readSignedCookie(r, "guestCart")
+	if !ok || len(payload) == 0 {
+		return []GuestCartItem{}, nil
+	}
+
+	items := []GuestCartItem{}
+	for _, entry := range strings.Split(string(payload), ",") {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		productID, variantID, ok := parseProductRef(parts[0])
+		quantity, err := strconv.Atoi(parts[1])
+		if !ok || err != nil || quantity < 1 {
+			continue
+		}
+		var priceCents int64
+		if len(parts) > 2 {
+			priceCents, _ = strconv.ParseInt(parts[2], 10, 64)
+		}
+		items = append(items, GuestCartItem{ProductID: productID, VariantID: variantID, Quantity: clampCartQuantity(quantity), PriceCents: priceCents})
+		if len(items) == maxGuestCartItems {
+			break
+		}
+	}
+	return items, nil
+}
+
+func saveGuestCart(w http.ResponseWriter, items []GuestCartItem) {
+	if len(items) > maxGuestCartItems {
+		items = items[:maxGuestCartItems]
+	}
+	entries := make([]string, 0, len(items))
+	for _, it := range items {
+		if it.ProductID == 0 || it.Quantity < 1 {
+			continue
+		}
+		entry := fmt.Sprintf("%s:%d", formatProductRef(it.ProductID, it.VariantID), clampCartQuantity(it.Quantity))
+		if it.PriceCents > 0 {
+			entry += fmt.Sprintf(":%d", it.PriceCents)
+		}
+		entries = append(entries, entry)
+	}
+	setSignedCookie(w, "guestCart", []byte(strings.Join(entries, ",")), 30*24*3600)
+}
+
+// --- Favoritele oaspeților: "12@1760000000,15.3@1760000100" (produs[.variantă]@unix) ---
+
+// GuestWishlistItem e o intrare din cookie-ul guestWishlist
+type GuestWishlistItem struct {
+	ProductID uint      `json:"productId"`
+	VariantID *uint     `json:"variantId,omitempty"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// maxGuestWishlistItems ține cookie-ul sub limita de ~4KB a browserelor
+const maxGuestWishlistItems = 40
+
+func getGuestWishlist(r *http.Request) ([]GuestWishlistItem, error) {
+	payload, ok := readSignedCookie(r, "guestWishlist")
+	if !ok || len(payload) == 0 {
+		return []GuestWishlistItem{}, nil
+	}
+
+	items := []GuestWishlistItem{}
+	for _, entry := range strings.Split(string(payload), ",") {
+		ref, ts, _ := strings.Cut(entry, "@")
+		productID, variantID, ok := parseProductRef(ref)
+		if !ok {
+			continue
+		}
+		added, _ := strconv.ParseInt(ts, 10, 64)
+		items = append(items, GuestWishlistItem{ProductID: productID, VariantID: variantID, AddedAt: time.Unix(added, 0)})
+		if len(items) == maxGuestWishlistItems {
+			break
+		}
+	}
+	return items, nil
+}
+
+func saveGuestWishlist(w http.ResponseWriter, items []GuestWishlistItem) {
+	if len(items) > maxGuestWishlistItems {
+		items = items[len(items)-maxGuestWishlistItems:]
+	}
+	entries := make([]string, 0, len(items))
+	for _, it := range items {
+		entries = append(entries, fmt.Sprintf("%s@%d", formatProductRef(it.ProductID, it.VariantID), it.AddedAt.Unix()))
+	}
+	setSignedCookie(w, "guestWishlist", []byte(strings.Join(entries, ",")), 90*24*3600)
+}
+
+// --- Identificatorul oaspetelui: 16 octeți aleatori, fără legătură cu coșul sau contul ---
+
+// guestVisitorID întoarce identificatorul opac al vizitatorului din cookie-ul semnat
+// guestId și îl creează la prima cerere care are nevoie de el
+func guestVisitorID(w http.ResponseWriter, r *http.Request) (string, error) {
+	if payload, ok := readSignedCookie(r, "guestId"); ok && len(payload) == 16 {
+		return hex.EncodeToString(payload), nil
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	setSignedCookie(w, "guestId", id, 90*24*3600)
+	return hex.EncodeToString(id), nil
+}
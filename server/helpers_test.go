@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withGuestCookieSecret(t *testing.T) {
+	t.Helper()
+	previous := guestCookieSecret
+	guestCookieSecret = []byte("test-guest-cookie-secret")
+	t.Cleanup(func() { guestCookieSecret = previous })
+}
+
+func TestVerifyCookieValue(t *testing.T) {
+	withGuestCookieSecret(t)
+	valid := signCookieValue("guestCart", []byte("12:1"))
+	payload, sig, _ := strings.Cut(valid, ".")
+	otherPayload := base64.RawURLEncoding.EncodeToString([]byte("12:99"))
+
+	tests := []struct {
+		name   string
+		cookie string
+		value  string
+		ok     bool
+	}{
+		{"semnătură validă", "guestCart", valid, true},
+		{"payload modificat", "guestCart", otherPayload + "." + sig, false},
+		{"semnătură modificată", "guestCart", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("x")), false},
+		{"mutat în alt cookie", "guestWishlist", valid, false},
+		{"fără semnătură", "guestCart", payload, false},
+		{"semnătură goală", "guestCart", payload + ".", false},
+		{"base64 invalid în payload", "guestCart", "!!!." + sig, false},
+		{"base64 invalid în semnătură", "guestCart", payload + ".!!!", false},
+		{"gol", "guestCart", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := verifyCookieValue(tt.cookie, tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, vrem %v", ok, tt.ok)
+			}
+			if ok && string(got) != "12:1" {
+				t.Errorf("payload %q, vrem %q", got, "12:1")
+			}
+		})
+	}
+
+	// o cheie diferită invalidează toate cookie-urile emise
+	guestCookieSecret = []byte("alt-secret")
+	if _, ok := verifyCookieValue("guestCart", valid); ok {
+		t.Error("cookie acceptat cu altă cheie")
+	}
+}
+
+func TestGetGuestCartBounds(t *testing.T) {
+	withGuestCookieSecret(t)
+
+	many := make([]string, maxGuestCartItems+5)
+	for i := range many {
+		many[i] = fmt.Sprintf("%d:1", i+1)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		signed  bool
+		want    []GuestCartItem
+		wantLen int
+	}{
+		{name: "fără cookie", want: []GuestCartItem{}},
+		{name: "nesemnat", payload: "1:2", want: []GuestCartItem{}},
+		{name: "o linie", payload: "1:2", signed: true, want: []GuestCartItem{{ProductID: 1, Quantity: 2}}},
+		{
+			name:    "variantă și preț",
+			payload: "3.7:1:129900",
+			signed:  true,
+			want:    []GuestCartItem{{ProductID: 3, VariantID: uintPtr(7), Quantity: 1, PriceCents: 129900}},
+		},
+		{
+			name:    "cantitate limitată la maxim",
+			payload: "1:100000",
+			signed:  true,
+			want:    []GuestCartItem{{ProductID: 1, Quantity: maxCartLineQuantity}},
+		},
+		{
+			name:    "linii invalide ignorate",
+			payload: "0:1,x:1,1:0,2:-3,3,4:abc,5.0:1,6:1",
+			signed:  true,
+			want:    []GuestCartItem{{ProductID: 6, Quantity: 1}},
+		},
+		{name: "prea multe linii", payload: strings.Join(many, ","), signed: true, wantLen: maxGuestCartItems},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/cart", nil)
+			if tt.payload != "" {
+				value := base64.RawURLEncoding.EncodeToString([]byte(tt.payload)) + ".nesemnat"
+				if tt.signed {
+					value = signCookieValue("guestCart", []byte(tt.payload))
+				}
+				r.AddCookie(&http.Cookie{Name: "guestCart", Value: value})
+			}
+			got, err := getGuestCart(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if len(got) != tt.wantLen {
+					t.Errorf("%d linii, vrem %d", len(got), tt.wantLen)
+				}
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("linii %+v, vrem %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].ProductID != tt.want[i].ProductID || got[i].Quantity != tt.want[i].Quantity ||
+					got[i].PriceCents != tt.want[i].PriceCents || !sameVariant(got[i].VariantID, tt.want[i].VariantID) {
+					t.Errorf("linia %d: %+v, vrem %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSaveGuestCartRoundTrip(t *testing.T) {
+	withGuestCookieSecret(t)
+	items := make([]GuestCartItem, maxGuestCartItems+3)
+	for i := range items {
+		items[i] = GuestCartItem{ProductID: uint(i + 1), Quantity: 500}
+	}
+	items[0].VariantID = uintPtr(4)
+	items[0].PriceCents = 9900
+
+	w := httptest.NewRecorder()
+	saveGuestCart(w, items)
+	r := httptest.NewRequest(http.MethodGet, "/api/cart", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	got, err := getGuestCart(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != maxGuestCartItems {
+		t.Fatalf("%d linii, vrem %d", len(got), maxGuestCartItems)
+	}
+	if got[0].VariantID == nil || *got[0].VariantID != 4 || got[0].PriceCents != 9900 {
+		t.Errorf("prima linie %+v", got[0])
+	}
+	for _, it := range got {
+		if it.Quantity != maxCartLineQuantity {
+			t.Errorf("produsul %d: cantitate %d, vrem %d", it.ProductID, it.Quantity, maxCartLineQuantity)
+		}
+	}
+}
+
+func uintPtr(v uint) *uint { return &v }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// idempotencyKeyTTL: după o zi cheia poate fi refolosită
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyLockTimeout: o cerere rămasă „în lucru” mai mult de atât a fost
+	// întreruptă (ex. repornirea serverului) și poate fi reluată
+	idempotencyLockTimeout = 2 * time.Minute
+	idempotencyMaxKeyLen   = 255
+	idempotencyMaxBody     = maxImageUpload + (1 << 20)
+)
+
+// idempotencyScope separă cheile între clienți și admini, ca o cheie ghicită
+// să nu întoarcă răspunsul altcuiva. Oaspeții primesc fiecare scope-ul lor,
+// după cookie-ul semnat guestId.
+func idempotencyScope(w http.ResponseWriter, r *http.Request) (string, error) {
+	if admin := currentAdmin(r); admin != nil {
+		return fmt.Sprintf("admin:%d", admin.ID), nil
+	}
+	if userID, ok := r.Context().Value(userIDKey).(uint); ok {
+		return fmt.Sprintf("user:%d", userID), nil
+	}
+	visitor, err := guestVisitorID(w, r)
+	if err != nil {
+		return "", err
+	}
+	return "guest:" + visitor, nil
+}
+
+// idempotencyFingerprint leagă cheia de metodă, cale și corpul exact al cererii
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyMiddleware: pentru un POST cu antetul Idempotency-Key, prima cerere
+// rulează normal și răspunsul ei e păstrat; repetările cu aceeași cheie primesc
+// răspunsul original fără să mai creeze nimic. Fără antet, cererea trece neschimbată.
+// Trebuie pus după autentificare, ca scope-ul să cunoască utilizatorul.
+func idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > idempotencyMaxKeyLen {
+			http.Error(w, fmt.Sprintf("Antetul Idempotency-Key poate avea cel mult %d caractere", idempotencyMaxKeyLen), http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, idempotencyMaxBody))
+		if err != nil {
+			http.Error(w, "Cererea este prea mare", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope, err := idempotencyScope(w, r)
+		if err != nil {
+			log.Printf("Eroare la identificarea vizitatorului pentru Idempotency-Key: %v", err)
+			http.Error(w, "Eroare la procesarea cererii", http.StatusInternalServerError)
+			return
+		}
+		record := IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Route:       r.Method + " " + r.URL.Path,
+			Fingerprint: idempotencyFingerprint(r, body),
+		}
+		existing, err := claimIdempotencyKey(&record)
+		if err != nil {
+			log.Printf("Eroare la verificarea cheii Idempotency-Key (%s): %v", record.Route, err)
+			http.Error(w, "Eroare la procesarea cererii", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			replayIdempotentResponse(w, existing, record.Fingerprint)
+			return
+		}
+
+		cw := &captureWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		// la o eroare de server nu păstrăm nimic, ca clientul să poată reîncerca cu aceeași cheie
+		if cw.status >= 500 {
+			if err := DB.Delete(&record).Error; err != nil {
+				log.Printf("Eroare la eliberarea cheii Idempotency-Key %d: %v", record.ID, err)
+			}
+			return
+		}
+		status := cw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if err := DB.Model(&record).Updates(map[string]interface{}{
+			"status_code":  status,
+			"response":     cw.body.Bytes(),
+			"content_type": w.Header().Get("Content-Type"),
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+			log.Printf("Eroare la salvarea răspunsului pentru cheia Idempotency-Key %d: %v", record.ID, err)
+		}
+	})
+}
+
+// claimIdempotencyKey rezervă cheia pentru cererea curentă. Dacă cheia e deja
+// folosită (și nu a expirat), întoarce înregistrarea existentă.
+func claimIdempotencyKey(record *IdempotencyKey) (*IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		res := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing IdempotencyKey
+		err := DB.Where("scope = ? AND key = ?", record.Scope, record.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // ștearsă între timp, încercăm din nou
+		}
+		if err != nil {
+			return nil, err
+		}
+		expired := time.Since(existing.CreatedAt) > idempotencyKeyTTL
+		abandoned := existing.CompletedAt == nil && time.Since(existing.CreatedAt) > idempotencyLockTimeout
+		if !expired && !abandoned {
+			return &existing, nil
+		}
+		if err := DB.Delete(&existing).Error; err != nil {
+			return nil, err
+		}
+		record.ID = 0
+	}
+	return nil, errors.New("cheia Idempotency-Key nu a putut fi rezervată")
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, existing *IdempotencyKey, fingerprint string) {
+	if existing.Fingerprint != fingerprint {
+		http.Error(w, "Cheia Idempotency-Key a fost deja folosită pentru o altă cerere", http.StatusUnprocessableEntity)
+		return
+	}
+	if existing.CompletedAt == nil {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "O cerere cu aceeași cheie Idempotency-Key este încă în curs", http.StatusConflict)
+		return
+	}
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Response)
+}
+
+// purgeIdempotencyKeys șterge cheile expirate
+func purgeIdempotencyKeys() error {
+	res := DB.Where("created_at < ?", time.Now().Add(-idempotencyKeyTTL)).Delete(&IdempotencyKey{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("Șterse %d chei Idempotency-Key expirate", res.RowsAffected)
+	}
+	return nil
+}
+
+func runIdempotencyCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := purgeIdempotencyKeys(); err != nil {
+			log.Println("Eroare la ștergerea cheilor Idempotency-Key expirate:", err)
+		}
+		<-ticker.C
+	}
+}
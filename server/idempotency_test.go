@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func idempotentRequest(key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	return r.WithContext(context.WithValue(r.Context(), userIDKey, uint(7)))
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	const body = `{"items":[1]}`
+	tests := []struct {
+		name     string
+		existing *IdempotencyKey // cheia lăsată de o cerere anterioară
+		body     string
+		status   int // răspunsul handlerului
+		want     int
+		calls    int
+		replayed bool
+	}{
+		{name: "prima cerere", body: body, status: http.StatusCreated, want: http.StatusCreated, calls: 1},
+		{
+			name:     "repetare după răspuns",
+			existing: &IdempotencyKey{StatusCode: http.StatusCreated, Response: []byte(`{"id":1}`), CompletedAt: timePtr(time.Now())},
+			body:     body, want: http.StatusCreated, replayed: true,
+		},
+		{
+			name:     "aceeași cheie pentru altă cerere",
+			existing: &IdempotencyKey{StatusCode: http.StatusCreated, CompletedAt: timePtr(time.Now())},
+			body:     `{"items":[2]}`, want: http.StatusUnprocessableEntity,
+		},
+		{name: "cerere originală încă în lucru", existing: &IdempotencyKey{}, body: body, want: http.StatusConflict},
+		{
+			name:     "cerere originală abandonată",
+			existing: &IdempotencyKey{CreatedAt: time.Now().Add(-2 * idempotencyLockTimeout)},
+			body:     body, status: http.StatusCreated, want: http.StatusCreated, calls: 1,
+		},
+		{
+			name:     "cheie expirată",
+			existing: &IdempotencyKey{StatusCode: http.StatusCreated, CompletedAt: timePtr(time.Now()), CreatedAt: time.Now().Add(-2 * idempotencyKeyTTL)},
+			body:     body, status: http.StatusCreated, want: http.StatusCreated, calls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &IdempotencyKey{})
+			if tt.existing != nil {
+				tt.existing.Scope = "user:7"
+				tt.existing.Key = "cheie-1"
+				tt.existing.Route = "POST /api/orders"
+				tt.existing.Fingerprint = idempotencyFingerprint(idempotentRequest("cheie-1", body), []byte(body))
+				if err := db.Create(tt.existing).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			calls := 0
+			handler := idempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(tt.status)
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, idempotentRequest("cheie-1", tt.body))
+
+			if w.Code != tt.want {
+				t.Errorf("status %d, vrem %d", w.Code, tt.want)
+			}
+			if calls != tt.calls {
+				t.Errorf("handlerul a rulat de %d ori, vrem %d", calls, tt.calls)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.replayed {
+				t.Errorf("răspuns reluat: %v, vrem %v", replayed, tt.replayed)
+			}
+			if tt.replayed && w.Body.String() != string(tt.existing.Response) {
+				t.Errorf("corp %q, vrem %q", w.Body.String(), tt.existing.Response)
+			}
+		})
+	}
+}
+
+// o cerere repetată după prima primește răspunsul păstrat, iar una după o eroare
+// de server rulează din nou
+func TestIdempotencyMiddlewareSequence(t *testing.T) {
+	newTestDB(t, &IdempotencyKey{})
+	statuses := []int{http.StatusInternalServerError, http.StatusCreated}
+	calls := 0
+	handler := idempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statuses[calls])
+		w.Write([]byte(`{"id":42}`))
+		calls++
+	}))
+
+	want := []int{http.StatusInternalServerError, http.StatusCreated, http.StatusCreated}
+	for i, status := range want {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, idempotentRequest("cheie-2", `{}`))
+		if w.Code != status {
+			t.Fatalf("cererea %d: status %d, vrem %d", i+1, w.Code, status)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handlerul a rulat de %d ori, vrem 2", calls)
+	}
+}
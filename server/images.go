@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chai2010/webp"
+	"github.com/gorilla/mux"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+// Dimensiunile maxime (latura lungă) pentru fiecare variantă generată la upload
+var imageRenditions = []struct {
+	Name    string
+	MaxSide int
+}{
+	{"thumb", 320},
+	{"card", 800},
+	{"full", 1600},
+}
+
+const (
+	jpegQuality     = 82
+	webpQuality     = 80 // WebP cu pierderi; varianta fără pierderi ieșea de câteva ori mai mare decât JPEG-ul
+	maxImagePixels  = 40_000_000
+	maxImageUpload  = 10 << 20
+	fullRenditionJP = "/full.jpg"
+)
+
+var (
+	errUnsupportedImage = errors.New("Format de imagine neacceptat (doar JPEG, PNG sau WebP)")
+	errImageTooLarge    = errors.New("Imaginea are o rezoluție prea mare")
+	errImageNotStored   = errors.New("Imaginea nu aparține stocării configurate")
+)
+
+var acceptedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// RenditionURLs conține aceeași variantă în JPEG (fallback) și WebP
+type RenditionURLs struct {
+	JPEG string `json:"jpeg"`
+	WebP string `json:"webp"`
+}
+
+// ProductImage e setul de URL-uri pentru o imagine de produs
+type ProductImage struct {
+	Original string        `json:"original"`
+	Thumb    RenditionURLs `json:"thumb"`
+	Card     RenditionURLs `json:"card"`
+	Full     RenditionURLs `json:"full"`
+}
+
+// imageSetFromURL derivă URL-urile variantelor din URL-ul salvat la produs.
+// Imaginile vechi, neprocesate, folosesc același fișier pentru toate variantele.
+func imageSetFromURL(u string) ProductImage {
+	u = resolveImageURL(u)
+	if !strings.HasSuffix(u, fullRenditionJP) {
+		same := RenditionURLs{JPEG: u, WebP: u}
+		return ProductImage{Original: u, Thumb: same, Card: same, Full: same}
+	}
+
+	base := strings.TrimSuffix(u, fullRenditionJP)
+	rendition := func(name string) RenditionURLs {
+		return RenditionURLs{JPEG: base + "/" + name + ".jpg", WebP: base + "/" + name + ".webp"}
+	}
+	return ProductImage{
+		Original: u,
+		Thumb:    rendition("thumb"),
+		Card:     rendition("card"),
+		Full:     rendition("full"),
+	}
+}
+
+func imageSetsFromURLs(urls []string) []ProductImage {
+	out := make([]ProductImage, 0, len(urls))
+	for _, u := range urls {
+		out = append(out, imageSetFromURL(u))
+	}
+	return out
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func imageBaseName(filename string) string {
+	name := strings.ToLower(strings.TrimSuffix(path.Base(filename), path.Ext(filename)))
+	name = strings.Trim(unsafeNameChars.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "imagine"
+	}
+	return fmt.Sprintf("%d_%s", time.Now().UnixNano(), name)
+}
+
+// decodeUploadedImage verifică tipul real al fișierului după conținut (nu după header-ul
+// trimis de client), decodează imaginea și aplică orientarea EXIF.
+// Re-encodarea ulterioară elimină toate metadatele EXIF (GPS, model cameră etc.).
+func decodeUploadedImage(data []byte) (image.Image, error) {
+	if !acceptedImageTypes[http.DetectContentType(data)] {
+		return nil, errUnsupportedImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+
+	return applyOrientation(img, jpegOrientation(data)), nil
+}
+
+// processAndStoreImage generează variantele thumb/card/full în JPEG și WebP și
+// întoarce URL-ul variantei full.jpg, care se salvează la produs.
+func processAndStoreImage(data []byte, filename string) (ProductImage, error) {
+	img, err := decodeUploadedImage(data)
+	if err != nil {
+		return ProductImage{}, err
+	}
+
+	prefix := "products/" + imageBaseName(filename)
+	for _, rnd := range imageRenditions {
+		resized := resizeToFit(img, rnd.MaxSide)
+
+		var jpg bytes.Buffer
+		if err := jpeg.Encode(&jpg, flattenOnWhite(resized), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return ProductImage{}, err
+		}
+		if err := Store.Put(prefix+"/"+rnd.Name+".jpg", &jpg, "image/jpeg"); err != nil {
+			return ProductImage{}, err
+		}
+
+		var wp bytes.Buffer
+		if err := webp.Encode(&wp, resized, &webp.Options{Quality: webpQuality}); err != nil {
+			return ProductImage{}, err
+		}
+		if err := Store.Put(prefix+"/"+rnd.Name+".webp", &wp, "image/webp"); err != nil {
+			return ProductImage{}, err
+		}
+	}
+
+	return imageSetFromURL(Store.PublicURL(prefix + fullRenditionJP)), nil
+}
+
+// resizeToFit micșorează imaginea astfel încât latura lungă să fie cel mult maxSide
+func resizeToFit(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+		return dst
+	}
+
+	if w >= h {
+		h = h * maxSide / w
+		w = maxSide
+	} else {
+		w = w * maxSide / h
+		h = maxSide
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// flattenOnWhite pune imaginile PNG transparente pe fundal alb pentru JPEG
+func flattenOnWhite(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// jpegOrientation citește tag-ul EXIF Orientation (0x0112) dintr-un JPEG; 1 = normal
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // începutul datelor imaginii
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < entries; n++ {
+		off := ifd + 2 + n*12
+		if off+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[off:off+2]) == 0x0112 {
+			v := int(order.Uint16(tiff[off+8 : off+10]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotește/oglindește imaginea conform valorii EXIF,
+// deoarece la re-encodare tag-ul se pierde.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	// copiem pe pixeli NRGBA (4 octeți), nu prin img.At/Set care alocă la fiecare pixel
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+w*4]
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			i := dy*dst.Stride + dx*4
+			copy(dst.Pix[i:i+4], row[x*4:x*4+4])
+		}
+	}
+	return dst
+}
+
+// fetchImage citește o imagine existentă (pentru reprocesarea pozelor vechi) direct
+// din stocare; sunt acceptate doar imaginile aflate în stocarea configurată
+func fetchImage(u string) ([]byte, error) {
+	key, ok := storageKey(u)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errImageNotStored, u)
+	}
+	return Store.Get(key)
+}
+
+// reprocessImageURLs generează variantele pentru imaginile vechi (încărcate înainte de
+// procesare) și întoarce lista nouă de URL-uri; cele deja procesate rămân neschimbate.
+func reprocessImageURLs(urls []string) ([]string, int, error) {
+	out := make([]string, len(urls))
+	converted := 0
+	for i, u := range urls {
+		resolved := resolveImageURL(u)
+		if strings.HasSuffix(resolved, fullRenditionJP) {
+			out[i] = u
+			continue
+		}
+
+		data, err := fetchImage(resolved)
+		if err != nil {
+			return nil, converted, err
+		}
+		if len(data) > maxImageUpload {
+			return nil, converted, errImageTooLarge
+		}
+		img, err := processAndStoreImage(data, path.Base(resolved))
+		if err != nil {
+			return nil, converted, fmt.Errorf("%s: %w", resolved, err)
+		}
+		out[i] = img.Original
+		converted++
+	}
+	return out, converted, nil
+}
+
+func reprocessImageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errImageNotStored) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Eroare la procesarea imaginilor", http.StatusBadGateway)
+}
+
+// reprocessProductImages: POST /api/admin/products/{id}/images/reprocess
+func reprocessProductImages(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var product Product
+	if err := DB.Preload("Variants").First(&product, id).Error; err != nil {
+		http.Error(w, "Produsul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+
+	urls, converted, err := reprocessImageURLs(product.ImageURLs)
+	if err != nil {
+		log.Printf("Eroare la reprocesarea imaginilor produsului %d: %v", product.ID, err)
+		reprocessImageError(w, err)
+		return
+	}
+	product.ImageURLs = urls
+
+	for i := range product.Variants {
+		vurls, n, err := reprocessImageURLs(product.Variants[i].ImageURLs)
+		if err != nil {
+			log.Printf("Eroare la reprocesarea imaginilor variantei %d: %v", product.Variants[i].ID, err)
+			reprocessImageError(w, err)
+			return
+		}
+		product.Variants[i].ImageURLs = vurls
+		converted += n
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Product{}).Where("id = ?", product.ID).Update("image_urls", product.ImageURLs).Error; err != nil {
+			return err
+		}
+		for _, v := range product.Variants {
+			if err := tx.Model(&ProductVariant{}).Where("id = ?", v.ID).Update("image_urls", v.ImageURLs).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Eroare la salvarea produsului", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Reprocesate %d imagini pentru produsul %d", converted, product.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(productToResponse(product))
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	stockReasonOrder      = "order"
+	stockReasonRelease    = "release"
+	stockReasonAdjustment = "adjustment"
+)
+
+// InsufficientStockError e returnată când comanda depășește stocul disponibil
+type InsufficientStockError struct {
+	ProductName string
+	Available   int
+	Requested   int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("Stoc insuficient pentru %s: disponibil %d, solicitat %d", e.ProductName, e.Available, e.Requested)
+}
+
+var errExpectedStockRequired = errors.New("Modificarea stocului trebuie trimisă împreună cu expected_stock_quantity")
+
+// StockConflictError e returnată când adminul corectează stocul pornind de la o valoare
+// care s-a schimbat între timp, de exemplu pentru că o comandă a rezervat bucăți
+type StockConflictError struct {
+	Label    string
+	Expected int
+	Current  int
+}
+
+func (e *StockConflictError) Error() string {
+	return fmt.Sprintf("Stocul pentru %s s-a schimbat între timp (era %d, acum %d). Reîncarcă pagina și reia modificarea.",
+		e.Label, e.Expected, e.Current)
+}
+
+// checkExpectedStock compară stocul de pe rândul blocat cu cel de la care a pornit adminul
+func checkExpectedStock(label string, current int, expected *int) error {
+	if expected == nil {
+		return errExpectedStockRequired
+	}
+	if *expected != current {
+		return &StockConflictError{Label: label, Expected: *expected, Current: current}
+	}
+	return nil
+}
+
+// reserveStock scade stocul pentru o poziție din comandă, blocând rândul până la commit.
+// Produsele la comandă (TrackStock = false) nu sunt afectate.
+func reserveStock(tx *gorm.DB, orderID uint, item OrderItem) error {
+	if item.VariantID != nil {
+		var variant ProductVariant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, *item.VariantID).Error; err != nil {
+			return err
+		}
+		if !variant.TrackStock {
+			return nil
+		}
+		if variant.StockQty < item.Quantity {
+			var product Product
+			tx.Select("name").First(&product, item.ProductID)
+			return &InsufficientStockError{
+				ProductName: fmt.Sprintf("%s (%s)", product.Name, variantLabel(variant)),
+				Available:   variant.StockQty,
+				Requested:   item.Quantity,
+			}
+		}
+		newQty := variant.StockQty - item.Quantity
+		if err := tx.Model(&variant).Update("stock_qty", newQty).Error; err != nil {
+			return err
+		}
+		return tx.Create(&StockMovement{
+			ProductID:     item.ProductID,
+			VariantID:     item.VariantID,
+			OrderID:       &orderID,
+			Delta:         -item.Quantity,
+			QuantityAfter: newQty,
+			Reason:        stockReasonOrder,
+		}).Error
+	}
+
+	var product Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error; err != nil {
+		return err
+	}
+	if !product.TrackStock {
+		return nil
+	}
+	if product.StockQty < item.Quantity {
+		return &InsufficientStockError{
+			ProductName: product.Name,
+			Available:   product.StockQty,
+			Requested:   item.Quantity,
+		}
+	}
+	newQty := product.StockQty - item.Quantity
+	if err := tx.Model(&product).Update("stock_qty", newQty).Error; err != nil {
+		return err
+	}
+	return tx.Create(&StockMovement{
+		ProductID:     item.ProductID,
+		OrderID:       &orderID,
+		Delta:         -item.Quantity,
+		QuantityAfter: newQty,
+		Reason:        stockReasonOrder,
+	}).Error
+}
+
+// releaseStock readaugă în stoc tot ce a rezervat comanda. Se bazează pe registru,
+// deci un al doilea apel pentru aceeași comandă nu mai modifică nimic.
+func releaseStock(tx *gorm.DB, orderID uint) error {
+	var released int64
+	if err := tx.Model(&StockMovement{}).
+		Where("order_id = ? AND reason = ?", orderID, stockReasonRelease).
+		Count(&released).Error; err != nil {
+		return err
+	}
+	if released > 0 {
+		return nil
+	}
+
+	var reserved []StockMovement
+	if err := tx.Where("order_id = ? AND reason = ?", orderID, stockReasonOrder).
+		Find(&reserved).Error; err != nil {
+		return err
+	}
+
+	for _, m := range reserved {
+		qty := -m.Delta
+		var newQty int
+
+		if m.VariantID != nil {
+			var variant ProductVariant
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, *m.VariantID).Error; err != nil {
+				return err
+			}
+			newQty = variant.StockQty + qty
+			if err := tx.Unscoped().Model(&variant).Update("stock_qty", newQty).Error; err != nil {
+				return err
+			}
+		} else {
+			var product Product
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, m.ProductID).Error; err != nil {
+				return err
+			}
+			newQty = product.StockQty + qty
+			if err := tx.Unscoped().Model(&product).Update("stock_qty", newQty).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&StockMovement{
+			ProductID:     m.ProductID,
+			VariantID:     m.VariantID,
+			OrderID:       &orderID,
+			Delta:         qty,
+			QuantityAfter: newQty,
+			Reason:        stockReasonRelease,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordStockAdjustment notează în registru o corecție manuală făcută din admin
+func recordStockAdjustment(tx *gorm.DB, productID uint, variantID *uint, oldQty, newQty int) error {
+	if oldQty == newQty {
+		return nil
+	}
+	return tx.Create(&StockMovement{
+		ProductID:     productID,
+		VariantID:     variantID,
+		Delta:         newQty - oldQty,
+		QuantityAfter: newQty,
+		Reason:        stockReasonAdjustment,
+		Note:          "Actualizare din panoul de administrare",
+	}).Error
+}
+
+func getStockMovements(w http.ResponseWriter, r *http.Request) {
+	var movements []StockMovement
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	// Filtru opțional {"product_id": 3, "order_id": 7}
+	var filter struct {
+		ProductID uint `json:"product_id"`
+		OrderID   uint `json:"order_id"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+
+	q := DB.Model(&StockMovement{})
+	if filter.ProductID != 0 {
+		q = q.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.OrderID != 0 {
+		q = q.Where("order_id = ?", filter.OrderID)
+	}
+
+	q.Count(&total)
+
+	if err := q.Order("created_at DESC").Offset(start).Limit(pageSize).Find(&movements).Error; err != nil {
+		http.Error(w, "Eroare la preluarea mișcărilor de stoc", http.StatusInternalServerError)
+		return
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("stock-movements %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(movements)
+}
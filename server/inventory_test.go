@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestSyncProductVariantsStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		stock     *int
+		expected  *int
+		wantStock int
+		conflict  bool
+		moves     int64
+	}{
+		// varianta a avut 5 bucăți în formular, între timp o comandă a rezervat una
+		{name: "fără stoc în cerere păstrează rezervarea", wantStock: 4},
+		{name: "stoc pornit de la valoarea curentă", stock: intPtr(10), expected: intPtr(4), wantStock: 10, moves: 1},
+		{name: "stoc pornit de la o valoare veche", stock: intPtr(5), expected: intPtr(5), wantStock: 4, conflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &ProductVariant{}, &StockMovement{})
+			variant := ProductVariant{ProductID: 1, SKU: "CANAPEA-GRI", IsAvailable: true, TrackStock: true, StockQty: 4}
+			if err := db.Create(&variant).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return syncProductVariants(tx, 1, []ProductVariantRequest{{
+					ID: variant.ID, SKU: "CANAPEA-GRI", IsAvailable: true, TrackStock: true,
+					StockQty: tt.stock, ExpectedStockQty: tt.expected,
+				}})
+			})
+			var conflict *StockConflictError
+			if tt.conflict != errors.As(err, &conflict) {
+				t.Fatalf("eroare %v, vrem conflict de stoc: %v", err, tt.conflict)
+			}
+			if err != nil && !tt.conflict {
+				t.Fatal(err)
+			}
+
+			db.First(&variant, variant.ID)
+			if variant.StockQty != tt.wantStock {
+				t.Errorf("stoc %d, vrem %d", variant.StockQty, tt.wantStock)
+			}
+			var moves int64
+			db.Model(&StockMovement{}).Where("reason = ?", stockReasonAdjustment).Count(&moves)
+			if moves != tt.moves {
+				t.Errorf("%d ajustări de stoc, vrem %d", moves, tt.moves)
+			}
+		})
+	}
+}
+
+func TestValidateVariantRequestsExpectedStock(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ProductVariantRequest
+		wantErr bool
+	}{
+		{name: "variantă nouă fără stoc așteptat", req: ProductVariantRequest{SKU: "A", StockQty: intPtr(3)}},
+		{name: "variantă existentă fără stoc", req: ProductVariantRequest{ID: 1, SKU: "A"}},
+		{name: "variantă existentă cu stoc așteptat", req: ProductVariantRequest{ID: 1, SKU: "A", StockQty: intPtr(3), ExpectedStockQty: intPtr(2)}},
+		{name: "variantă existentă fără stoc așteptat", req: ProductVariantRequest{ID: 1, SKU: "A", StockQty: intPtr(3)}, wantErr: true},
+		{name: "stoc negativ", req: ProductVariantRequest{SKU: "A", StockQty: intPtr(-1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVariantRequests([]ProductVariantRequest{tt.req})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("eroare %v, vrem eroare: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// SQLite ignoră FOR UPDATE, așa că testele verifică rezervările una după alta;
+// serializarea între tranzacții o face blocarea rândului în Postgres.
+func TestReserveStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		variant   bool
+		track     bool
+		orders    []int // cantitatea cerută de fiecare comandă, în ordine
+		wantErrAt int   // indexul comenzii refuzate, -1 dacă toate trec
+		wantStock int
+	}{
+		{name: "produs: stocul ajunge exact", track: true, orders: []int{2, 3}, wantErrAt: -1, wantStock: 0},
+		{name: "produs: a doua comandă ar vinde peste stoc", track: true, orders: []int{3, 3}, wantErrAt: 1, wantStock: 2},
+		{name: "variantă: a doua comandă ar vinde peste stoc", variant: true, track: true, orders: []int{4, 2}, wantErrAt: 1, wantStock: 1},
+		{name: "fără urmărirea stocului", track: false, orders: []int{3, 3}, wantErrAt: -1, wantStock: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &Product{}, &ProductVariant{}, &StockMovement{})
+			product := Product{ID: 1, Name: "Canapea", TrackStock: tt.track, StockQty: 5}
+			if tt.variant {
+				product.TrackStock, product.StockQty = false, 0
+			}
+			if err := db.Create(&product).Error; err != nil {
+				t.Fatal(err)
+			}
+			var variantID *uint
+			if tt.variant {
+				variant := ProductVariant{ID: 10, ProductID: 1, SKU: "CANAPEA-GRI", IsAvailable: true, TrackStock: tt.track, StockQty: 5}
+				if err := db.Create(&variant).Error; err != nil {
+					t.Fatal(err)
+				}
+				variantID = &variant.ID
+			}
+
+			for i, qty := range tt.orders {
+				err := db.Transaction(func(tx *gorm.DB) error {
+					return reserveStock(tx, uint(i+1), OrderItem{ProductID: 1, VariantID: variantID, Quantity: qty})
+				})
+				var stockErr *InsufficientStockError
+				if rejected := errors.As(err, &stockErr); rejected != (i == tt.wantErrAt) {
+					t.Fatalf("comanda %d: eroare %v", i+1, err)
+				}
+				if err != nil && !errors.As(err, &stockErr) {
+					t.Fatal(err)
+				}
+			}
+
+			var stock int
+			if tt.variant {
+				var variant ProductVariant
+				db.First(&variant, *variantID)
+				stock = variant.StockQty
+			} else {
+				db.First(&product, 1)
+				stock = product.StockQty
+			}
+			if stock != tt.wantStock {
+				t.Errorf("stoc %d, vrem %d", stock, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestReleaseStockIdempotent(t *testing.T) {
+	db := newTestDB(t, &Product{}, &ProductVariant{}, &StockMovement{})
+	if err := db.Create(&Product{ID: 1, Name: "Canapea", TrackStock: true, StockQty: 5}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&Product{ID: 2, Name: "Fotoliu"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&ProductVariant{ID: 10, ProductID: 2, SKU: "FOTOLIU-GRI", IsAvailable: true, TrackStock: true, StockQty: 3}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := reserveStock(tx, 7, OrderItem{ProductID: 1, Quantity: 2}); err != nil {
+			return err
+		}
+		return reserveStock(tx, 7, OrderItem{ProductID: 2, VariantID: uintPtr(10), Quantity: 3})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// anularea poate fi cerută de mai multe ori (admin, plată eșuată, expirare)
+	for i := 0; i < 2; i++ {
+		if err := db.Transaction(func(tx *gorm.DB) error { return releaseStock(tx, 7) }); err != nil {
+			t.Fatalf("eliberarea %d: %v", i+1, err)
+		}
+	}
+
+	var product Product
+	var variant ProductVariant
+	db.First(&product, 1)
+	db.First(&variant, 10)
+	if product.StockQty != 5 || variant.StockQty != 3 {
+		t.Errorf("stoc produs %d, variantă %d; vrem 5 și 3", product.StockQty, variant.StockQty)
+	}
+	var releases int64
+	db.Model(&StockMovement{}).Where("order_id = ? AND reason = ?", 7, stockReasonRelease).Count(&releases)
+	if releases != 2 {
+		t.Errorf("%d mișcări de eliberare, vrem 2", releases)
+	}
+}
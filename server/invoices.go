@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/gorilla/mux"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tipurile de factură
+const (
+	InvoiceProforma = "proforma"
+	InvoiceFinal    = "invoice"
+)
+
+var errInvoicesDisabled = errors.New("Datele firmei pentru facturare nu sunt configurate")
+
+// companyDetails sunt datele vânzătorului tipărite pe factură
+type companyDetails struct {
+	Name       string
+	FiscalCode string
+	VATCode    string
+	VATPercent int
+	Address    string
+	Bank       string
+	BankCode   string
+	IBAN       string
+	Phone      string
+	Email      string
+}
+
+// loadCompanyDetails citește datele firmei din .env (COMPANY_*). TVA-ul apare
+// pe factură doar dacă firma are cod TVA.
+func loadCompanyDetails() companyDetails {
+	c := companyDetails{
+		Name:       getEnv("COMPANY_NAME", ""),
+		FiscalCode: getEnv("COMPANY_IDNO", ""),
+		VATCode:    getEnv("COMPANY_VAT_CODE", ""),
+		Address:    getEnv("COMPANY_ADDRESS", ""),
+		Bank:       getEnv("COMPANY_BANK", ""),
+		BankCode:   getEnv("COMPANY_BANK_CODE", ""),
+		IBAN:       getEnv("COMPANY_IBAN", ""),
+		Phone:      getEnv("COMPANY_PHONE", ""),
+		Email:      getEnv("COMPANY_EMAIL", ""),
+	}
+	if c.VATCode != "" {
+		c.VATPercent, _ = strconv.Atoi(getEnv("COMPANY_VAT_PERCENT", "20"))
+	}
+	return c
+}
+
+// validFiscalCode verifică IDNO-ul unei firme din Moldova (13 cifre)
+func validFiscalCode(code string) bool {
+	if len(code) != 13 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func invoiceSeries(kind string) string {
+	if kind == InvoiceProforma {
+		return getEnv("PROFORMA_SERIES", "PF")
+	}
+	return getEnv("INVOICE_SERIES", "SL")
+}
+
+func withInvoiceCode(inv *Invoice) {
+	inv.Code = fmt.Sprintf("%s %06d", inv.Series, inv.Number)
+}
+
+// nextInvoiceNumber rezervă următorul număr din serie. Rândul seriei rămâne
+// blocat până la commit, deci emiterile concurente se așteaptă una pe alta;
+// la rollback numărul nu a fost folosit, iar indexul unic (serie, număr)
+// garantează că un număr emis nu se repetă.
+func nextInvoiceNumber(tx *gorm.DB, series string) (int64, error) {
+	var number int64
+	err := tx.Raw(`INSERT INTO invoice_sequences (series, last_number) VALUES (?, 1)
+		ON CONFLICT (series) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`, series).Scan(&number).Error
+	return number, err
+}
+
+// issueInvoice emite factura de tipul dat pentru comandă. Factura fiscală se
+// emite o singură dată; o proformă nouă se emite doar dacă totalul comenzii
+// s-a schimbat de la ultima, altfel e întoarsă cea existentă.
+func issueInvoice(tx *gorm.DB, orderID uint, kind, actor string) (Invoice, error) {
+	company := loadCompanyDetails()
+	if company.Name == "" {
+		return Invoice{}, errInvoicesDisabled
+	}
+
+	var order Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return Invoice{}, err
+	}
+	if releasesStock(order.Status) {
+		return Invoice{}, errOrderClosed
+	}
+
+	var existing Invoice
+	err := tx.Omit("pdf").Where("order_id = ? AND kind = ?", order.ID, kind).Order("id DESC").First(&existing).Error
+	if err == nil && (kind == InvoiceFinal || existing.TotalCents == order.TotalCents) {
+		withInvoiceCode(&existing)
+		return existing, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Invoice{}, err
+	}
+
+	if err := tx.Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("order_id = ?", order.ID).Order("id ASC").Find(&order.Items).Error; err != nil {
+		return Invoice{}, err
+	}
+
+	series := invoiceSeries(kind)
+	number, err := nextInvoiceNumber(tx, series)
+	if err != nil {
+		return Invoice{}, err
+	}
+	inv := Invoice{
+		OrderID:    order.ID,
+		Kind:       kind,
+		Series:     series,
+		Number:     number,
+		TotalCents: order.TotalCents,
+		IssuedAt:   time.Now(),
+		IssuedBy:   actor,
+	}
+	withInvoiceCode(&inv)
+	if inv.PDF, err = renderInvoicePDF(company, inv, order); err != nil {
+		return Invoice{}, err
+	}
+	if err := tx.Create(&inv).Error; err != nil {
+		return Invoice{}, err
+	}
+
+	return inv, tx.Create(&OrderStatusEvent{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   order.Status,
+		Actor:      actor,
+		Note:       fmt.Sprintf("Emisă %s %s", invoiceTitle(kind), inv.Code),
+	}).Error
+}
+
+func invoiceTitle(kind string) string {
+	if kind == InvoiceProforma {
+		return "factura proformă"
+	}
+	return "factura"
+}
+
+func invoiceFilename(inv Invoice) string {
+	prefix := "factura"
+	if inv.Kind == InvoiceProforma {
+		prefix = "proforma"
+	}
+	return fmt.Sprintf("%s-%s-%06d.pdf", prefix, unsafeFileChars.ReplaceAllString(inv.Series, "_"), inv.Number)
+}
+
+// invoiceAttachment încarcă PDF-ul facturii pentru un email din outbox
+func invoiceAttachment(db *gorm.DB, invoiceID uint) (Attachment, error) {
+	var inv Invoice
+	if err := db.First(&inv, invoiceID).Error; err != nil {
+		return Attachment{}, fmt.Errorf("factura #%d: %w", invoiceID, err)
+	}
+	return Attachment{Filename: invoiceFilename(inv), ContentType: "application/pdf", Data: inv.PDF}, nil
+}
+
+// --- PDF ---
+
+const invoiceFont = "go"
+
+// renderInvoicePDF desenează factura pe A4. Fonturile Go sunt încorporate ca
+// UTF-8, deci diacriticele (ă, â, î, ș, ț) apar corect în orice cititor.
+func renderInvoicePDF(company companyDetails, inv Invoice, order Order) ([]byte, error) {
+	title := "FACTURĂ"
+	if inv.Kind == InvoiceProforma {
+		title = "FACTURĂ PROFORMĂ"
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(fmt.Sprintf("%s %s", title, inv.Code), true)
+	pdf.SetAuthor(company.Name, true)
+	pdf.SetCreationDate(inv.IssuedAt)
+	pdf.SetModificationDate(inv.IssuedAt)
+	pdf.AddUTF8FontFromBytes(invoiceFont, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(invoiceFont, "B", gobold.TTF)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+
+	// antet: vânzătorul în stânga, tipul și numărul facturii în dreapta
+	pdf.SetFont(invoiceFont, "B", 13)
+	pdf.CellFormat(110, 7, company.Name, "", 0, "L", false, 0, "")
+	pdf.SetFont(invoiceFont, "B", 14)
+	pdf.CellFormat(0, 7, title, "", 1, "R", false, 0, "")
+
+	seller := nonEmpty(
+		labeled("IDNO", company.FiscalCode),
+		labeled("Cod TVA", company.VATCode),
+		company.Address,
+		labeled("Banca", joinNonEmpty(", ", company.Bank, company.BankCode)),
+		labeled("IBAN", company.IBAN),
+		joinNonEmpty(" · ", company.Phone, company.Email),
+	)
+	meta := []string{
+		"Nr. " + inv.Code,
+		"Data: " + inv.IssuedAt.Format("02.01.2006"),
+		fmt.Sprintf("Comanda %s din %s", order.Reference, order.CreatedAt.Format("02.01.2006")),
+	}
+	pdf.SetFont(invoiceFont, "", 9)
+	for i := 0; i < len(seller) || i < len(meta); i++ {
+		left, right := "", ""
+		if i < len(seller) {
+			left = seller[i]
+		}
+		if i < len(meta) {
+			right = meta[i]
+		}
+		pdf.CellFormat(110, 5, left, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, right, "", 1, "R", false, 0, "")
+	}
+
+	// cumpărătorul
+	pdf.Ln(5)
+	pdf.SetFont(invoiceFont, "B", 10)
+	pdf.CellFormat(0, 6, "Cumpărător", "", 1, "L", false, 0, "")
+	pdf.SetFont(invoiceFont, "", 9)
+	buyer := []string{order.Name}
+	if order.BillingCompany != "" {
+		buyer = []string{order.BillingCompany, labeled("IDNO", order.BillingFiscalCode), labeled("Persoana de contact", order.Name)}
+	}
+	buyer = append(buyer, joinNonEmpty(", ", order.Address, order.City), joinNonEmpty(" · ", order.Phone, order.Email))
+	for _, line := range nonEmpty(buyer...) {
+		pdf.CellFormat(0, 5, line, "", 1, "L", false, 0, "")
+	}
+
+	// produsele
+	pdf.Ln(5)
+	widths := []float64{10, 83, 15, 25, 22, 25}
+	headers := []string{"Nr.", "Denumire", "Cant.", "Preț unitar", "Reducere", "Valoare"}
+	aligns := []string{"C", "L", "C", "R", "R", "R"}
+	pdf.SetFont(invoiceFont, "B", 9)
+	pdf.SetFillColor(244, 241, 236)
+	for i, h := range headers {
+		pdf.CellFormat(widths[i], 7, h, "1", 0, aligns[i], true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(invoiceFont, "", 9)
+	for i, it := range order.Items {
+		name := it.Product.Name
+		if it.VariantLabel != "" {
+			name += " (" + it.VariantLabel + ")"
+		}
+		if it.VariantSKU != "" {
+			name += ", cod " + it.VariantSKU
+		}
+		discount := ""
+		if it.DiscountCents > 0 {
+			discount = "-" + formatMDL(it.DiscountCents)
+		}
+		cells := []string{
+			strconv.Itoa(i + 1),
+			name,
+			strconv.Itoa(it.Quantity),
+			formatMDL(it.PriceCents),
+			discount,
+			formatMDL(it.PriceCents*int64(it.Quantity) - it.DiscountCents),
+		}
+
+		// denumirea se poate întinde pe mai multe rânduri; celelalte celule iau aceeași înălțime
+		lines := pdf.SplitText(name, widths[1]-2)
+		height := 6 * float64(len(lines))
+		if pdf.GetY()+height > 277 {
+			pdf.AddPage()
+		}
+		x, y := pdf.GetXY()
+		for j, text := range cells {
+			if j == 1 {
+				pdf.Rect(x, y, widths[j], height, "D")
+				pdf.MultiCell(widths[j], 6, text, "", "L", false)
+				pdf.SetXY(x+widths[j], y)
+			} else {
+				pdf.CellFormat(widths[j], height, text, "1", 0, aligns[j], false, 0, "")
+			}
+			x += widths[j]
+		}
+		pdf.SetXY(15, y+height)
+	}
+
+	// totaluri
+	pdf.Ln(3)
+	total := func(label, value string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont(invoiceFont, style, 9)
+		pdf.CellFormat(145, 6, label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(0, 6, value, "", 1, "R", false, 0, "")
+	}
+	total("Subtotal:", formatMDL(order.SubtotalCents), false)
+	if order.DiscountCents > 0 {
+		label := "Reducere:"
+		if order.PromoCode != "" {
+			label = fmt.Sprintf("Reducere (%s):", order.PromoCode)
+		}
+		total(label, "-"+formatMDL(order.DiscountCents), false)
+	}
+	if order.DeliveryZoneID != nil {
+		total(fmt.Sprintf("Livrare (%s):", order.DeliveryZoneName), formatMDL(order.DeliveryCents), false)
+	}
+	total("Total de plată:", formatMDL(order.TotalCents), true)
+	if company.VATPercent > 0 {
+		vat := (order.TotalCents*int64(company.VATPercent) + int64(100+company.VATPercent)/2) / int64(100+company.VATPercent)
+		total(fmt.Sprintf("inclusiv TVA %d%%:", company.VATPercent), formatMDL(vat), false)
+	}
+	withPaymentTotals(&order)
+	if order.DepositCents > 0 && inv.Kind == InvoiceProforma {
+		total("Avans înainte de producție:", formatMDL(order.DepositCents), false)
+	}
+	if order.PaidCents > 0 {
+		total("Achitat:", formatMDL(order.PaidCents), false)
+	}
+	if order.PaidCents > 0 || order.DepositCents > 0 {
+		total("Rest de plată:", formatMDL(order.BalanceCents), true)
+	}
+
+	// mențiuni
+	pdf.Ln(8)
+	pdf.SetFont(invoiceFont, "", 8)
+	if inv.Kind == InvoiceProforma {
+		pdf.MultiCell(0, 4, fmt.Sprintf("Factura proformă nu este document fiscal. La plata prin transfer bancar indicați „%s” în destinația plății.", inv.Code), "", "L", false)
+	} else {
+		pdf.Ln(8)
+		pdf.CellFormat(90, 5, "Vânzător: ____________________", "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, "Cumpărător: ____________________", "", 1, "R", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func labeled(label, value string) string {
+	if value == "" {
+		return ""
+	}
+	return label + ": " + value
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	out := ""
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if out != "" {
+			out += sep
+		}
+		out += p
+	}
+	return out
+}
+
+func nonEmpty(lines ...string) []string {
+	out := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// --- HTTP ---
+
+func writeInvoicePDF(w http.ResponseWriter, inv Invoice) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoiceFilename(inv)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(inv.PDF)
+}
+
+func listOrderInvoices(w http.ResponseWriter, orderID interface{}) {
+	var invoices []Invoice
+	if err := DB.Omit("pdf").Where("order_id = ?", orderID).Order("id DESC").Find(&invoices).Error; err != nil {
+		http.Error(w, "Eroare la preluarea facturilor", http.StatusInternalServerError)
+		return
+	}
+	for i := range invoices {
+		withInvoiceCode(&invoices[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invoices)
+}
+
+// issueInvoiceResponse emite factura și trimite răspunsul sau eroarea potrivită
+func issueInvoiceResponse(w http.ResponseWriter, orderID uint, kind, actor string) {
+	var inv Invoice
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		inv, err = issueInvoice(tx, orderID, kind, actor)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Comanda nu a fost gasita", http.StatusNotFound)
+		case errors.Is(err, errInvoicesDisabled):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case errors.Is(err, errOrderClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Eroare la emiterea facturii (%s) pentru comanda %d: %v", kind, orderID, err)
+			http.Error(w, "Eroare la emiterea facturii", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
+
+// getUserOrderInvoices: GET /api/orders/{id}/invoices
+func getUserOrderInvoices(w http.ResponseWriter, r *http.Request) {
+	order, ok := userOrder(w, r)
+	if !ok {
+		return
+	}
+	listOrderInvoices(w, order.ID)
+}
+
+// requestUserProforma: POST /api/orders/{id}/invoices/proforma
+func requestUserProforma(w http.ResponseWriter, r *http.Request) {
+	order, ok := userOrder(w, r)
+	if !ok {
+		return
+	}
+	issueInvoiceResponse(w, order.ID, InvoiceProforma, orderActor(order.UserID))
+}
+
+// downloadUserInvoice: GET /api/orders/{id}/invoices/{invoiceId}/pdf
+func downloadUserInvoice(w http.ResponseWriter, r *http.Request) {
+	order, ok := userOrder(w, r)
+	if !ok {
+		return
+	}
+	var inv Invoice
+	if err := DB.Where("id = ? AND order_id = ?", mux.Vars(r)["invoiceId"], order.ID).First(&inv).Error; err != nil {
+		http.Error(w, "Factura nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	writeInvoicePDF(w, inv)
+}
+
+// userOrder încarcă comanda din URL dacă aparține utilizatorului autentificat
+func userOrder(w http.ResponseWriter, r *http.Request) (Order, bool) {
+	userID, _ := r.Context().Value(userIDKey).(uint)
+	var order Order
+	if err := DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], userID).First(&order).Error; err != nil {
+		http.Error(w, "Comanda nu a fost găsită", http.StatusNotFound)
+		return order, false
+	}
+	return order, true
+}
+
+// --- Admin ---
+
+// getAdminOrderInvoices: GET /api/admin/orders/{id}/invoices
+func getAdminOrderInvoices(w http.ResponseWriter, r *http.Request) {
+	listOrderInvoices(w, mux.Vars(r)["id"])
+}
+
+// issueAdminOrderInvoice: POST /api/admin/orders/{id}/invoices {kind: proforma|invoice}
+func issueAdminOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalid", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != InvoiceProforma && req.Kind != InvoiceFinal {
+		http.Error(w, "Tip de factură invalid (proforma sau invoice)", http.StatusBadRequest)
+		return
+	}
+	issueInvoiceResponse(w, uint(id), req.Kind, adminActor(r))
+}
+
+// downloadAdminInvoice: GET /api/admin/invoices/{id}/pdf
+func downloadAdminInvoice(w http.ResponseWriter, r *http.Request) {
+	var inv Invoice
+	if err := DB.First(&inv, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Factura nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	writeInvoicePDF(w, inv)
+}
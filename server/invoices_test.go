@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestNextInvoiceNumberGapless(t *testing.T) {
+	db := newTestDB(t, &InvoiceSequence{})
+	errRollback := errors.New("rollback")
+
+	issue := func(series string, fail bool) (int64, error) {
+		var number int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if number, err = nextInvoiceNumber(tx, series); err != nil {
+				return err
+			}
+			if fail {
+				return errRollback // ex. PDF-ul nu a putut fi generat
+			}
+			return nil
+		})
+		return number, err
+	}
+
+	steps := []struct {
+		series string
+		fail   bool
+		want   int64
+	}{
+		{series: "SLF", want: 1},
+		{series: "SLF", want: 2},
+		{series: "SLP", want: 1}, // fiecare serie are numerotarea ei
+		{series: "SLF", fail: true},
+		{series: "SLF", want: 3}, // numărul din tranzacția anulată nu lasă gol
+		{series: "SLP", want: 2},
+	}
+	for i, s := range steps {
+		number, err := issue(s.series, s.fail)
+		if s.fail {
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("pasul %d: eroare %v", i+1, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("pasul %d: %v", i+1, err)
+		}
+		if number != s.want {
+			t.Errorf("pasul %d: %s nr. %d, vrem %d", i+1, s.series, number, s.want)
+		}
+	}
+}
+
+// emiterile simultane primesc numere distincte și consecutive; cu o singură
+// conexiune SQLite tranzacțiile se așteaptă una pe alta, ca la blocarea rândului în Postgres
+func TestNextInvoiceNumberConcurrent(t *testing.T) {
+	db := newTestDB(t, &InvoiceSequence{})
+	const workers = 20
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	numbers := []int64{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.Transaction(func(tx *gorm.DB) error {
+				number, err := nextInvoiceNumber(tx, "SLF")
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				numbers = append(numbers, number)
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	if len(numbers) != workers {
+		t.Fatalf("%d numere emise, vrem %d", len(numbers), workers)
+	}
+	for i, n := range numbers {
+		if n != int64(i+1) {
+			t.Fatalf("numerele emise %v, vrem 1..%d fără goluri sau repetări", numbers, workers)
+		}
+	}
+}
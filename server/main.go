@@ -21,9 +21,9 @@ func enableCORS(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 		}
 
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Range, Content-Range, X-Total-Count, Sort, Filter")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Range, Content-Range, X-Total-Count, Sort, Filter, X-Request-ID, Idempotency-Key")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Range, X-Total-Count")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Range, X-Total-Count, X-Request-ID, Idempotent-Replayed, Retry-After")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -36,11 +36,31 @@ func enableCORS(next http.Handler) http.Handler {
 
 func main() {
 	godotenv.Load()
-	InitSupabase()
+	InitGuestCookies()
+	InitTrustedProxies()
+	InitStorage()
+	InitMailer()
+	InitPayments()
 
 	// Conectare DB
 	ConnectDB()
-	DB.AutoMigrate(&Product{}, &Order{}, &OrderItem{}, &User{}, &CartItem{})
+	DB.AutoMigrate(&Category{}, &Product{}, &ProductVariant{}, &Order{}, &OrderItem{}, &OrderStatusEvent{}, &User{}, &Session{}, &PasswordResetToken{}, &PasswordResetAttempt{}, &CartItem{}, &StockMovement{}, &AdminUser{}, &AuditLog{}, &Promotion{}, &PromotionRedemption{}, &OrderDiscount{}, &PriceHistory{}, &ScheduledPriceChange{}, &OutboxEmail{}, &WishlistItem{}, &DeliveryZone{}, &Payment{}, &Invoice{}, &InvoiceSequence{}, &IdempotencyKey{}, &OrderTrackAttempt{})
+	migrateVariantSKUIndex()
+	dropPasswordChangedAt()
+	migrateLegacyOrderStatuses()
+	migrateOrderPaidAmounts()
+	migrateOrderReferences()
+	backfillProductDimensions()
+	backfillOrderSubtotals()
+	if err := ensureSearchIndex(); err != nil {
+		log.Println("Eroare la configurarea căutării full-text:", err)
+	}
+	ensureBootstrapAdmin()
+	go runPriceScheduler()
+	go runOutboxWorker()
+	go runIdempotencyCleanup()
+	go runOrderTrackCleanup()
+	go runPasswordResetWorker()
 
 	// Router
 	r := mux.NewRouter()
@@ -52,7 +72,15 @@ func main() {
 	r.HandleFunc("/api/register", handleRegister).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/login", handleLogin).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/logout", handleLogout).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/password/forgot", handleForgotPassword).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/password/reset", handleResetPassword).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/token/refresh", handleRefresh).Methods("POST", "OPTIONS")
 	r.Handle("/api/me", authMiddleware(http.HandlerFunc(handleMe))).Methods("GET", "OPTIONS")
+	r.Handle("/api/password/change", authMiddleware(requireAuth(http.HandlerFunc(handleChangePassword)))).Methods("POST", "OPTIONS")
+
+	// Sessions
+	r.Handle("/api/sessions", authMiddleware(requireAuth(http.HandlerFunc(handleListSessions)))).Methods("GET", "OPTIONS")
+	r.Handle("/api/sessions/{id}", authMiddleware(requireAuth(http.HandlerFunc(handleRevokeSession)))).Methods("DELETE", "OPTIONS")
 
 	// Google Auth
 	r.HandleFunc("/api/auth/google/login", handleGoogleLogin).Methods("GET", "OPTIONS")
@@ -68,15 +96,34 @@ func main() {
 	// --- Authenticated User Routes ---
 	// Orders
 	r.Handle("/api/orders", authMiddleware(requireAuth(http.HandlerFunc(getUserOrders)))).Methods("GET", "OPTIONS")
-	r.Handle("/api/orders", authMiddleware(http.HandlerFunc(createOrder))).Methods("POST", "OPTIONS")
+	r.Handle("/api/orders", authMiddleware(idempotencyMiddleware(http.HandlerFunc(createOrder)))).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/orders/track", trackOrder).Methods("GET", "OPTIONS")
+	r.Handle("/api/orders/{id}/pay", authMiddleware(requireAuth(idempotencyMiddleware(http.HandlerFunc(payOrder))))).Methods("POST", "OPTIONS")
+	r.Handle("/api/orders/{id}/invoices", authMiddleware(requireAuth(http.HandlerFunc(getUserOrderInvoices)))).Methods("GET", "OPTIONS")
+	r.Handle("/api/orders/{id}/invoices/proforma", authMiddleware(requireAuth(http.HandlerFunc(requestUserProforma)))).Methods("POST", "OPTIONS")
+	r.Handle("/api/orders/{id}/invoices/{invoiceId}/pdf", authMiddleware(requireAuth(http.HandlerFunc(downloadUserInvoice)))).Methods("GET", "OPTIONS")
+
+	// Plăți: notificările procesatorului și pagina procesatorului de test
+	r.HandleFunc("/api/payments/webhook/{provider}", handlePaymentWebhook).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/payments/fake/{ref}", fakePaymentCheckout).Methods("GET", "OPTIONS")
 
 	// Cart
 	r.Handle("/api/cart", authMiddleware(http.HandlerFunc(getCart))).Methods("GET", "OPTIONS")
-	r.Handle("/api/cart", authMiddleware(http.HandlerFunc(addToCart))).Methods("POST", "OPTIONS")
+	r.Handle("/api/cart", authMiddleware(idempotencyMiddleware(http.HandlerFunc(addToCart)))).Methods("POST", "OPTIONS")
 	r.Handle("/api/cart", authMiddleware(http.HandlerFunc(clearCart))).Methods("DELETE", "OPTIONS")
 	r.Handle("/api/cart/item/{id}", authMiddleware(http.HandlerFunc(updateCartItem))).Methods("PUT", "OPTIONS")
 	r.Handle("/api/cart/item/{id}", authMiddleware(http.HandlerFunc(removeCartItem))).Methods("DELETE", "OPTIONS")
+	r.HandleFunc("/api/delivery/zones", getDeliveryZones).Methods("GET", "OPTIONS")
+	r.Handle("/api/delivery/quote", authMiddleware(http.HandlerFunc(getDeliveryQuote))).Methods("GET", "OPTIONS")
+	r.Handle("/api/cart/refresh", authMiddleware(http.HandlerFunc(refreshCart))).Methods("POST", "OPTIONS")
 	r.Handle("/api/cart/sync", authMiddleware(http.HandlerFunc(syncCart))).Methods("POST", "OPTIONS")
+	r.Handle("/api/cart/apply-promo", authMiddleware(http.HandlerFunc(applyPromoPreview))).Methods("POST", "OPTIONS")
+
+	// Favorite (utilizatori și oaspeți)
+	r.Handle("/api/wishlist", authMiddleware(http.HandlerFunc(getWishlist))).Methods("GET", "OPTIONS")
+	r.Handle("/api/wishlist", authMiddleware(http.HandlerFunc(addToWishlist))).Methods("POST", "OPTIONS")
+	r.Handle("/api/wishlist/{productId}", authMiddleware(http.HandlerFunc(removeFromWishlist))).Methods("DELETE", "OPTIONS")
+	r.Handle("/api/wishlist/{productId}/move-to-cart", authMiddleware(http.HandlerFunc(moveWishlistToCart))).Methods("POST", "OPTIONS")
 
 	// --- ADMIN ROUTES ---
 	adminRouter := r.PathPrefix("/api/admin").Subrouter()
@@ -85,10 +132,14 @@ func main() {
 	adminRouter.HandleFunc("/login", adminLogin).Methods("POST", "OPTIONS")
 	adminRouter.HandleFunc("/logout", adminLogout).Methods("POST", "OPTIONS")
 	adminRouter.HandleFunc("/me", adminMe).Methods("GET", "OPTIONS")
+	adminRouter.HandleFunc("/invite/accept", acceptAdminInvite).Methods("POST", "OPTIONS")
 
 	// Admin Protected Routes
 	protectedAdmin := adminRouter.PathPrefix("").Subrouter()
 	protectedAdmin.Use(adminAuth)
+	// înaintea auditului, ca o cerere repetată să nu fie jurnalizată de două ori
+	protectedAdmin.Use(idempotencyMiddleware)
+	protectedAdmin.Use(auditMiddleware)
 
 	// Admin Products
 	protectedAdmin.HandleFunc("/upload", adminUpload).Methods("POST", "OPTIONS")
@@ -97,21 +148,69 @@ func main() {
 	protectedAdmin.HandleFunc("/products/{id}", getAdminProduct).Methods("GET", "OPTIONS")
 	protectedAdmin.HandleFunc("/products/{id}", updateProduct).Methods("PUT", "OPTIONS")
 	protectedAdmin.HandleFunc("/products/{id}", deleteAdminProduct).Methods("DELETE", "OPTIONS")
+	protectedAdmin.HandleFunc("/products/{id}/images/reprocess", reprocessProductImages).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/products/{id}/price-history", getProductPriceHistory).Methods("GET", "OPTIONS")
+
+	// Admin Price Changes
+	protectedAdmin.HandleFunc("/price-changes", getScheduledPriceChanges).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/price-changes", createScheduledPriceChange).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/price-changes/{id}", getScheduledPriceChange).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/price-changes/{id}", cancelScheduledPriceChange).Methods("DELETE", "OPTIONS")
 
 	// Admin Categories
 	protectedAdmin.HandleFunc("/categories", getCategories).Methods("GET", "OPTIONS")
 	protectedAdmin.HandleFunc("/categories", createCategory).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/categories/{id}", updateCategory).Methods("PUT", "OPTIONS")
 
 	// Admin Orders
 	protectedAdmin.HandleFunc("/orders", getAllOrders).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/orders/{id}", getAdminOrder).Methods("GET", "OPTIONS")
 	protectedAdmin.HandleFunc("/orders/{id}", updateOrderStatus).Methods("PUT", "OPTIONS")
 	protectedAdmin.HandleFunc("/orders/{id}", deleteAdminOrder).Methods("DELETE", "OPTIONS")
+	protectedAdmin.HandleFunc("/orders/{id}/payments", getAdminOrderPayments).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/orders/{id}/payments", recordAdminOrderPayment).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/payments/{id}/refund", refundAdminPayment).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/orders/{id}/invoices", getAdminOrderInvoices).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/orders/{id}/invoices", issueAdminOrderInvoice).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/invoices/{id}/pdf", downloadAdminInvoice).Methods("GET", "OPTIONS")
+
+	// Admin Promotions
+	protectedAdmin.HandleFunc("/promotions", getAdminPromotions).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/promotions", createAdminPromotion).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/promotions/{id}", getAdminPromotion).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/promotions/{id}", updateAdminPromotion).Methods("PUT", "OPTIONS")
+	protectedAdmin.HandleFunc("/promotions/{id}", deleteAdminPromotion).Methods("DELETE", "OPTIONS")
+	protectedAdmin.HandleFunc("/delivery-zones", getAdminDeliveryZones).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/delivery-zones", createAdminDeliveryZone).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/delivery-zones/{id}", getAdminDeliveryZone).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/delivery-zones/{id}", updateAdminDeliveryZone).Methods("PUT", "OPTIONS")
+	protectedAdmin.HandleFunc("/delivery-zones/{id}", deleteAdminDeliveryZone).Methods("DELETE", "OPTIONS")
+
+	// Emailuri (outbox)
+	protectedAdmin.HandleFunc("/emails", getAdminEmails).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/emails/{id}", getAdminEmail).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/emails/{id}/resend", resendAdminEmail).Methods("POST", "OPTIONS")
+
+	// Admin Stock
+	protectedAdmin.HandleFunc("/stock-movements", getStockMovements).Methods("GET", "OPTIONS")
 
 	// Admin users
 	protectedAdmin.HandleFunc("/users", getAdminUsers).Methods("GET", "OPTIONS")
 
+	// Admin staff
+	protectedAdmin.HandleFunc("/staff", getAdminStaff).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/staff", inviteAdminStaff).Methods("POST", "OPTIONS")
+	protectedAdmin.HandleFunc("/staff/{id}", getAdminStaffMember).Methods("GET", "OPTIONS")
+	protectedAdmin.HandleFunc("/staff/{id}", updateAdminStaff).Methods("PUT", "OPTIONS")
+	protectedAdmin.HandleFunc("/staff/{id}/reinvite", reinviteAdminStaff).Methods("POST", "OPTIONS")
+
+	// Admin audit
+	protectedAdmin.HandleFunc("/audit", getAuditLogs).Methods("GET", "OPTIONS")
+
 	// --- Static Files ---
-	// r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads/"))))
+	if local, ok := Store.(*localStorage); ok {
+		r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", http.FileServer(http.Dir(local.dir))))
+	}
 
 	// --- Test Route ---
 	r.HandleFunc("/api/test", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// auth_google.go oprește procesul în init() fără configurarea OAuth, iar
+// variabilele de pachet se inițializează înaintea oricărui init()
+var _ = setTestEnv()
+
+func setTestEnv() bool {
+	for key, value := range map[string]string{
+		"GOOGLE_CLIENT_ID":     "test-client",
+		"GOOGLE_CLIENT_SECRET": "test-secret",
+		"GOOGLE_REDIRECT_URL":  "http://localhost/callback",
+		"JWT_SECRET":           "test-jwt-secret",
+	} {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	return true
+}
+
+// newTestDB deschide o bază SQLite în memorie cu tabelele date și o pune în DB
+// pe durata testului. Interogările folosite de funcțiile testate sunt SQL portabil.
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // fiecare conexiune nouă ar primi o bază goală
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+		sqlDB.Close()
+	})
+	return db
+}
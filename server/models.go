@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/lib/pq"
@@ -8,93 +9,255 @@ import (
 )
 
 type Category struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"unique;not null" json:"name"`
-	Products  []Product `json:"products,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Name           string    `gorm:"unique;not null" json:"name"`
+	DepositPercent int       `gorm:"not null;default:0" json:"deposit_percent"` // avansul cerut la comandă, 0 = fără avans
+	Products       []Product `json:"products,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type Product struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Name         string         `gorm:"not null" json:"name"`
-	Description  string         `json:"description"`
-	PriceCents   int64          `gorm:"not null" json:"price_cents"`
-	CategoryID   uint           `json:"category_id"`
-	Category     Category       `json:"category" gorm:"foreignKey:CategoryID"`
-	Dimensions   string         `json:"dimensions"`
-	ImageURLs    pq.StringArray `gorm:"type:text[]" json:"image_urls"`
-	IsAvailable  bool           `gorm:"default:true" json:"is_available"`
-	DeliveryTime string         `gorm:"default:'2-3 saptamani'" json:"delivery_time"`
-	IsActive     bool           `gorm:"default:true" json:"is_active"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+	PriceCents  int64  `gorm:"not null" json:"price_cents"`
+	// CompareAtCents e prețul vechi afișat tăiat; SalePriceCents se aplică doar în fereastra Sale*
+	CompareAtCents      *int64           `json:"compare_at_cents"`
+	SalePriceCents      *int64           `json:"sale_price_cents"`
+	SaleStartsAt        *time.Time       `json:"sale_starts_at"`
+	SaleEndsAt          *time.Time       `json:"sale_ends_at"`
+	EffectivePriceCents int64            `gorm:"-" json:"effective_price_cents"`
+	OriginalPriceCents  *int64           `gorm:"-" json:"original_price_cents"`
+	OnSale              bool             `gorm:"-" json:"on_sale"`
+	CategoryID          uint             `json:"category_id"`
+	Category            Category         `json:"category" gorm:"foreignKey:CategoryID"`
+	Dimensions          string           `json:"dimensions"`
+	WidthCm             *int             `gorm:"index" json:"width_cm"`
+	DepthCm             *int             `json:"depth_cm"`
+	HeightCm            *int             `json:"height_cm"`
+	ImageURLs           pq.StringArray   `gorm:"type:text[]" json:"image_urls"`
+	Images              []ProductImage   `gorm:"-" json:"images,omitempty"`
+	IsAvailable         bool             `gorm:"default:true" json:"is_available"`
+	TrackStock          bool             `gorm:"default:false" json:"track_stock"`
+	StockQty            int              `gorm:"not null;default:0" json:"stock_quantity"`
+	DeliveryTime        string           `gorm:"default:'2-3 saptamani'" json:"delivery_time"`
+	DepositPercent      *int             `json:"deposit_percent"` // nil = avansul categoriei
+	IsActive            bool             `gorm:"default:true" json:"is_active"`
+	Variants            []ProductVariant `json:"variants,omitempty" gorm:"foreignKey:ProductID"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt   `gorm:"index" json:"-"`
 }
 
-type ProductCreateRequest struct {
-	Name        string   `json:"name" validate:"required"`
-	Description string   `json:"description"`
-	Price       float64  `json:"price" validate:"required,gt=0"`
-	CategoryID  uint     `json:"category_id" validate:"required"`
-	ImageURLs   []string `json:"image_urls"`
+// ProductVariant e o versiune concretă a unui produs (stofă, culoare, nr. locuri)
+type ProductVariant struct {
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	ProductID           uint           `gorm:"index;not null" json:"product_id"`
+	SKU                 string         `gorm:"not null;uniqueIndex:idx_product_variants_sku_active,where:deleted_at IS NULL" json:"sku"` // unic doar între variantele neșterse
+	Fabric              string         `json:"fabric"`
+	Color               string         `json:"color"`
+	Seats               int            `json:"seats"`
+	Dimensions          string         `json:"dimensions"`
+	PriceDeltaCents     int64          `gorm:"not null;default:0" json:"price_delta_cents"`
+	EffectivePriceCents int64          `gorm:"-" json:"effective_price_cents,omitempty"`
+	ImageURLs           pq.StringArray `gorm:"type:text[]" json:"image_urls"`
+	Images              []ProductImage `gorm:"-" json:"images,omitempty"`
+	IsAvailable         bool           `gorm:"default:true" json:"is_available"`
+	TrackStock          bool           `gorm:"default:false" json:"track_stock"`
+	StockQty            int            `gorm:"not null;default:0" json:"stock_quantity"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type ProductVariantRequest struct {
+	ID          uint     `json:"id"`
+	SKU         string   `json:"sku"`
+	Fabric      string   `json:"fabric"`
+	Color       string   `json:"color"`
+	Seats       int      `json:"seats"`
 	Dimensions  string   `json:"dimensions"`
-	IsActive    bool     `json:"is_active"`
+	PriceDelta  float64  `json:"price_delta"`
+	ImageURLs   []string `json:"image_urls"`
 	IsAvailable bool     `json:"is_available"`
+	TrackStock  bool     `json:"track_stock"`
+	// la o variantă existentă stocul se schimbă doar împreună cu valoarea de la care
+	// pornește corecția; lipsa lui păstrează stocul curent
+	StockQty         *int `json:"stock_quantity"`
+	ExpectedStockQty *int `json:"expected_stock_quantity"`
+}
+
+type ProductVariantResponse struct {
+	ID                  uint           `json:"id"`
+	SKU                 string         `json:"sku"`
+	Fabric              string         `json:"fabric"`
+	Color               string         `json:"color"`
+	Seats               int            `json:"seats"`
+	Dimensions          string         `json:"dimensions"`
+	PriceDelta          float64        `json:"price_delta"`
+	Price               float64        `json:"price"`
+	PriceCents          int64          `json:"price_cents"`
+	EffectivePrice      float64        `json:"effective_price"`
+	EffectivePriceCents int64          `json:"effective_price_cents"`
+	ImageURLs           []string       `json:"image_urls"`
+	Images              []ProductImage `json:"images"`
+	IsAvailable         bool           `json:"is_available"`
+	TrackStock          bool           `json:"track_stock"`
+	StockQty            int            `json:"stock_quantity"`
+}
+
+type ProductCreateRequest struct {
+	Name           string                  `json:"name" validate:"required"`
+	Description    string                  `json:"description"`
+	Price          float64                 `json:"price" validate:"required,gt=0"`
+	CategoryID     uint                    `json:"category_id" validate:"required"`
+	ImageURLs      []string                `json:"image_urls"`
+	Dimensions     string                  `json:"dimensions"`
+	IsActive       bool                    `json:"is_active"`
+	IsAvailable    bool                    `json:"is_available"`
+	TrackStock     bool                    `json:"track_stock"`
+	StockQty       int                     `json:"stock_quantity"`
+	Variants       []ProductVariantRequest `json:"variants"`
+	CompareAtPrice *float64                `json:"compare_at_price"`
+	SalePrice      *float64                `json:"sale_price"`
+	SaleStartsAt   *time.Time              `json:"sale_starts_at"`
+	SaleEndsAt     *time.Time              `json:"sale_ends_at"`
+	DepositPercent *int                    `json:"deposit_percent"`
 }
 
 type ProductUpdateRequest struct {
-	Name        *string   `json:"name"`
-	Description *string   `json:"description"`
-	Price       *float64  `json:"price"`
-	CategoryID  *uint     `json:"category_id"`
-	ImageURLs   *[]string `json:"image_urls"`
-	Dimensions  *string   `json:"dimensions"`
-	IsActive    *bool     `json:"is_active"`
-	IsAvailable *bool     `json:"is_available"`
+	Name        *string                  `json:"name"`
+	Description *string                  `json:"description"`
+	Price       *float64                 `json:"price"`
+	CategoryID  *uint                    `json:"category_id"`
+	ImageURLs   *[]string                `json:"image_urls"`
+	Dimensions  *string                  `json:"dimensions"`
+	IsActive    *bool                    `json:"is_active"`
+	IsAvailable *bool                    `json:"is_available"`
+	TrackStock  *bool                    `json:"track_stock"`
+	StockQty    *int                     `json:"stock_quantity"`
+	Variants    *[]ProductVariantRequest `json:"variants"`
+	// stocul văzut de admin în formular; dacă între timp s-a schimbat, corecția e refuzată
+	ExpectedStockQty *int `json:"expected_stock_quantity"`
+	// 0 șterge prețul vechi / reducerea; sale_starts_at și sale_ends_at se citesc împreună cu sale_price
+	CompareAtPrice *float64   `json:"compare_at_price"`
+	SalePrice      *float64   `json:"sale_price"`
+	SaleStartsAt   *time.Time `json:"sale_starts_at"`
+	SaleEndsAt     *time.Time `json:"sale_ends_at"`
+	// -1 revine la avansul categoriei
+	DepositPercent *int `json:"deposit_percent"`
 }
 
 type ProductResponse struct {
-	ID          uint     `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Price       float64  `json:"price"`
-	PriceCents  int64    `json:"price_cents"`
-	CategoryID  uint     `json:"category_id"`
-	ImageURLs   []string `json:"image_urls"`
-	Dimensions  string   `json:"dimensions"`
-	IsActive    bool     `json:"is_active"`
-	IsAvailable bool     `json:"is_available"`
+	ID                  uint                     `json:"id"`
+	Name                string                   `json:"name"`
+	Description         string                   `json:"description"`
+	Price               float64                  `json:"price"`
+	PriceCents          int64                    `json:"price_cents"`
+	EffectivePrice      float64                  `json:"effective_price"`
+	EffectivePriceCents int64                    `json:"effective_price_cents"`
+	OriginalPrice       *float64                 `json:"original_price"`
+	OriginalPriceCents  *int64                   `json:"original_price_cents"`
+	OnSale              bool                     `json:"on_sale"`
+	CompareAtPrice      *float64                 `json:"compare_at_price"`
+	SalePrice           *float64                 `json:"sale_price"`
+	SaleStartsAt        *time.Time               `json:"sale_starts_at"`
+	SaleEndsAt          *time.Time               `json:"sale_ends_at"`
+	CategoryID          uint                     `json:"category_id"`
+	ImageURLs           []string                 `json:"image_urls"`
+	Images              []ProductImage           `json:"images"`
+	Dimensions          string                   `json:"dimensions"`
+	WidthCm             *int                     `json:"width_cm"`
+	DepthCm             *int                     `json:"depth_cm"`
+	HeightCm            *int                     `json:"height_cm"`
+	IsActive            bool                     `json:"is_active"`
+	IsAvailable         bool                     `json:"is_available"`
+	TrackStock          bool                     `json:"track_stock"`
+	StockQty            int                      `json:"stock_quantity"`
+	DepositPercent      *int                     `json:"deposit_percent"`
+	Variants            []ProductVariantResponse `json:"variants"`
 }
 
 type Order struct {
-	ID         uint           `gorm:"primaryKey" json:"id"`
-	UserID     *uint          `json:"userId"`
-	User       User           `json:"user,omitempty"`
-	Name       string         `gorm:"not null" json:"name"`
-	Phone      string         `gorm:"not null" json:"phone"`
-	Email      string         `json:"email"`
-	Address    string         `gorm:"not null" json:"address"`
-	City       string         `gorm:"not null" json:"city"`
-	Notes      string         `json:"notes"`
-	Status     string         `gorm:"default:'pending'" json:"status"`
-	Total      float64        `gorm:"-" json:"total"`
-	TotalCents int64          `gorm:"not null" json:"total_cents"`
-	Items      []OrderItem    `json:"items" gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID         uint    `gorm:"primaryKey" json:"id"`
+	Reference  string  `gorm:"size:20;not null;default:'';uniqueIndex:idx_orders_reference,where:reference <> ''" json:"reference"` // număr public, ex. SL-2026-7K3QF
+	UserID     *uint   `json:"userId"`
+	User       User    `json:"user,omitempty"`
+	Name       string  `gorm:"not null" json:"name"`
+	Phone      string  `gorm:"not null" json:"phone"`
+	Email      string  `json:"email"`
+	Address    string  `gorm:"not null" json:"address"`
+	City       string  `gorm:"not null" json:"city"`
+	Notes      string  `json:"notes"`
+	Status     string  `gorm:"default:'pending'" json:"status"`
+	Locale     string  `gorm:"size:5;not null;default:'ro'" json:"locale"`
+	Total      float64 `gorm:"-" json:"total"`
+	TotalCents int64   `gorm:"not null" json:"total_cents"`
+	// datele firmei pentru factură, completate doar de persoanele juridice
+	BillingCompany    string `json:"billing_company"`
+	BillingFiscalCode string `gorm:"size:13" json:"billing_fiscal_code"`
+	// SubtotalCents e suma liniilor înainte de reduceri; TotalCents = Subtotal - Discount + Delivery
+	SubtotalCents int64  `gorm:"not null;default:0" json:"subtotal_cents"`
+	DiscountCents int64  `gorm:"not null;default:0" json:"discount_cents"`
+	PromotionID   *uint  `gorm:"index" json:"promotion_id"`
+	PromoCode     string `json:"promo_code"`
+	FreeDelivery  bool   `gorm:"not null;default:false" json:"free_delivery"`
+	// Livrarea e calculată la plasare; zona și termenul sunt copiate ca să nu depindă de tarifele de azi
+	DeliveryZoneID   *uint  `gorm:"index" json:"delivery_zone_id"`
+	DeliveryZoneName string `json:"delivery_zone_name"`
+	DeliveryCents    int64  `gorm:"not null;default:0" json:"delivery_cents"`
+	DeliveryLeadDays int    `gorm:"not null;default:0" json:"delivery_lead_days"`
+	// intervalul de livrare anunțat clientului; gol până îl stabilește atelierul
+	DeliveryFrom *time.Time `gorm:"type:date" json:"delivery_from"`
+	DeliveryTo   *time.Time `gorm:"type:date" json:"delivery_to"`
+	// DepositCents e avansul cerut înainte de producție; restul se achită la livrare.
+	// BalanceCents = Total - Paid, DepositDueCents = cât mai lipsește din avans.
+	DepositCents    int64 `gorm:"not null;default:0" json:"deposit_cents"`
+	PaidCents       int64 `gorm:"not null;default:0" json:"paid_cents"`
+	BalanceCents    int64 `gorm:"-" json:"balance_cents"`
+	DepositDueCents int64 `gorm:"-" json:"deposit_due_cents"`
+	// PaymentMethod e ales de client; PaymentStatus se schimbă doar prin webhook sau din admin
+	PaymentMethod string             `gorm:"size:20;not null;default:'cash_on_delivery'" json:"payment_method"`
+	PaymentStatus string             `gorm:"size:20;not null;default:'unpaid'" json:"payment_status"`
+	PaidAt        *time.Time         `json:"paid_at"`
+	PaymentURL    string             `gorm:"-" json:"payment_url,omitempty"`
+	PaymentError  string             `gorm:"-" json:"payment_error,omitempty"`
+	Discounts     []OrderDiscount    `json:"discounts" gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	Items         []OrderItem        `json:"items" gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	History       []OrderStatusEvent `json:"history" gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt     `gorm:"index" json:"-"`
 }
 
 type OrderItem struct {
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	OrderID      uint    `json:"orderId"`
+	ProductID    uint    `json:"productId"`
+	VariantID    *uint   `gorm:"index" json:"variantId"`
+	VariantSKU   string  `json:"variant_sku"`
+	VariantLabel string  `json:"variant_label"`
+	Quantity     int     `gorm:"not null" json:"quantity"`
+	Price        float64 `gorm:"-" json:"price"`
+	PriceCents   int64   `gorm:"not null" json:"price_cents"`
+	// DiscountCents e reducerea totală pe linie (nu pe bucată)
+	DiscountCents int64           `gorm:"not null;default:0" json:"discount_cents"`
+	DepositCents  int64           `gorm:"not null;default:0" json:"deposit_cents"` // partea liniei din avansul comenzii
+	CreatedAt     time.Time       `json:"created_at"`
+	Product       Product         `json:"product" gorm:"foreignKey:ProductID"`
+	Variant       *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+}
+
+// OrderStatusEvent înregistrează cine a schimbat statusul unei comenzi și când
+type OrderStatusEvent struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
-	OrderID    uint      `json:"orderId"`
-	ProductID  uint      `json:"productId"`
-	Quantity   int       `gorm:"not null" json:"quantity"`
-	Price      float64   `gorm:"-" json:"price"`
-	PriceCents int64     `gorm:"not null" json:"price_cents"`
+	OrderID    uint      `gorm:"index;not null" json:"orderId"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	Actor      string    `gorm:"not null" json:"actor"`
+	Note       string    `json:"note"`
 	CreatedAt  time.Time `json:"created_at"`
-	Product    Product   `json:"product" gorm:"foreignKey:ProductID"`
 }
 
 type User struct {
@@ -114,12 +277,302 @@ type User struct {
 	CartItems             []CartItem `gorm:"foreignKey:UserID"`
 }
 
+// Session e o sesiune de autentificare a unui dispozitiv. Refresh tokenul se rotește
+// la fiecare folosire; păstrăm hash-ul curent și pe cel anterior pentru detectarea reutilizării.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"index;not null" json:"-"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`
+	RotatedAt         time.Time  `json:"-"`
+	Device            string     `json:"device"`
+	IP                string     `json:"ip"`
+	UserAgent         string     `json:"user_agent"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"-"`
+}
+
+// PasswordResetToken păstrează doar hash-ul SHA-256 al tokenului trimis pe email
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetAttempt e o cerere de resetare a parolei, folosită ca să limităm
+// cererile de pe același IP și pentru aceeași adresă
+type PasswordResetAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	IP        string    `gorm:"size:64;not null;index"`
+	Email     string    `gorm:"size:254;not null;index"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
 type CartItem struct {
+	ID        uint  `gorm:"primaryKey" json:"id"`
+	UserID    uint  `gorm:"index" json:"userId"`
+	ProductID uint  `gorm:"index" json:"productId"`
+	VariantID *uint `gorm:"index" json:"variantId"`
+	Quantity  int   `gorm:"not null;default:1" json:"quantity"`
+	// PriceCents e prețul unitar văzut de client la adăugare; 0 pentru liniile vechi
+	PriceCents int64           `gorm:"not null;default:0" json:"price_cents"`
+	Product    Product         `json:"product"`
+	Variant    *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// StockMovement e o intrare în registrul de stoc; Delta negativ = ieșire
+type StockMovement struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProductID     uint      `gorm:"index;not null" json:"product_id"`
+	VariantID     *uint     `gorm:"index" json:"variant_id"`
+	OrderID       *uint     `gorm:"index" json:"order_id"`
+	Delta         int       `gorm:"not null" json:"delta"`
+	QuantityAfter int       `gorm:"not null" json:"quantity_after"`
+	Reason        string    `gorm:"not null" json:"reason"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AdminUser e un cont de personal pentru panoul de administrare
+type AdminUser struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Username        string     `gorm:"uniqueIndex;not null" json:"username"`
+	Name            string     `json:"name"`
+	PasswordHash    string     `json:"-"`
+	Role            string     `gorm:"not null;default:'workshop'" json:"role"`
+	IsActive        bool       `gorm:"default:false" json:"is_active"`
+	InviteTokenHash string     `gorm:"index" json:"-"`
+	InviteExpiresAt *time.Time `json:"invite_expires_at"`
+	InvitedByID     *uint      `json:"invited_by_id"`
+	DisabledAt      *time.Time `json:"disabled_at"` // dezactivat de un owner; invitația nu-l mai poate activa
+	LastLoginAt     *time.Time `json:"last_login_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AuditLog înregistrează o acțiune a personalului din panoul de administrare
+type AuditLog struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	AdminID       uint            `gorm:"index" json:"admin_id"`
+	AdminUsername string          `json:"admin_username"`
+	Action        string          `gorm:"not null" json:"action"`
+	Entity        string          `gorm:"index:idx_audit_entity;not null" json:"entity"`
+	EntityID      string          `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Before        json.RawMessage `gorm:"type:jsonb" json:"before"`
+	After         json.RawMessage `gorm:"type:jsonb" json:"after"`
+	Diff          json.RawMessage `gorm:"type:jsonb" json:"diff"`
+	RequestID     string          `gorm:"index" json:"request_id"`
+	IP            string          `json:"ip"`
+	CreatedAt     time.Time       `gorm:"index" json:"created_at"`
+}
+
+// Promotion e un cod promoțional: procent, sumă fixă sau livrare gratuită.
+// Fără ProductIDs/CategoryIDs se aplică întregului coș; limitele 0 = nelimitat.
+type Promotion struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Code           string         `gorm:"not null;uniqueIndex:idx_promotions_code,where:deleted_at IS NULL" json:"code"`
+	Description    string         `json:"description"`
+	Type           string         `gorm:"not null" json:"type"`
+	PercentOff     int            `gorm:"not null;default:0" json:"percent_off"`
+	AmountOffCents int64          `gorm:"not null;default:0" json:"amount_off_cents"`
+	AmountOff      float64        `gorm:"-" json:"amount_off"`
+	MinOrderCents  int64          `gorm:"not null;default:0" json:"min_order_cents"`
+	MinOrder       float64        `gorm:"-" json:"min_order"`
+	CategoryIDs    pq.Int64Array  `gorm:"type:bigint[]" json:"category_ids"`
+	ProductIDs     pq.Int64Array  `gorm:"type:bigint[]" json:"product_ids"`
+	UsageLimit     int            `gorm:"not null;default:0" json:"usage_limit"`
+	PerUserLimit   int            `gorm:"not null;default:0" json:"per_user_limit"`
+	UsedCount      int            `gorm:"not null;default:0" json:"used_count"`
+	StartsAt       *time.Time     `json:"starts_at"`
+	EndsAt         *time.Time     `json:"ends_at"`
+	IsActive       bool           `gorm:"not null" json:"is_active"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Payment e o încercare de plată online sau o încasare înregistrată manual
+// (Provider "manual"). O comandă poate avea mai multe plăți: avansul, restul
+// la livrare sau un card refuzat și apoi reluat.
+type Payment struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	OrderID       uint       `gorm:"index;not null" json:"order_id"`
+	Provider      string     `gorm:"size:20;not null" json:"provider"`
+	ProviderRef   string     `gorm:"index" json:"provider_ref"`
+	Status        string     `gorm:"size:20;not null;index" json:"status"`
+	AmountCents   int64      `gorm:"not null" json:"amount_cents"`
+	Currency      string     `gorm:"size:3;not null;default:'MDL'" json:"currency"`
+	RefundedCents int64      `gorm:"not null;default:0" json:"refunded_cents"`
+	RedirectURL   string     `json:"-"`
+	FailureReason string     `json:"failure_reason"`
+	PaidAt        *time.Time `json:"paid_at"`
+	Method        string     `gorm:"size:20" json:"method,omitempty"` // doar la plățile manuale
+	Note          string     `json:"note,omitempty"`                  // doar la plățile manuale
+	RecordedBy    string     `json:"recorded_by,omitempty"`           // adminul care a înregistrat plata manuală
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DeliveryZone e o zonă tarifară (ex. Chișinău, suburbii, alte raioane).
+// Taxa = BaseFee + PerItemFee pentru fiecare bucată după prima; de la
+// FreeThreshold (0 = fără prag) livrarea e gratuită. Zona IsDefault acoperă
+// localitățile care nu apar în nicio altă zonă.
+type DeliveryZone struct {
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Name               string         `gorm:"not null" json:"name"`
+	Cities             pq.StringArray `gorm:"type:text[]" json:"cities"`
+	IsDefault          bool           `gorm:"not null;default:false" json:"is_default"`
+	BaseFeeCents       int64          `gorm:"not null;default:0" json:"base_fee_cents"`
+	BaseFee            float64        `gorm:"-" json:"base_fee"`
+	PerItemFeeCents    int64          `gorm:"not null;default:0" json:"per_item_fee_cents"`
+	PerItemFee         float64        `gorm:"-" json:"per_item_fee"`
+	FreeThresholdCents int64          `gorm:"not null;default:0" json:"free_threshold_cents"`
+	FreeThreshold      float64        `gorm:"-" json:"free_threshold"`
+	LeadTimeDays       int            `gorm:"not null;default:0" json:"lead_time_days"`
+	IsActive           bool           `gorm:"not null" json:"is_active"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// PromotionRedemption: o utilizare a unui cod, pentru limitele per cod și per client
+type PromotionRedemption struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	PromotionID   uint      `gorm:"index;not null" json:"promotion_id"`
+	OrderID       uint      `gorm:"uniqueIndex;not null" json:"order_id"`
+	UserID        *uint     `gorm:"index" json:"user_id"`
+	Phone         string    `gorm:"index" json:"phone"`
+	DiscountCents int64     `gorm:"not null" json:"discount_cents"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// OrderDiscount e o linie de reducere pe comandă, păstrată pentru audit
+type OrderDiscount struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OrderID     uint      `gorm:"index;not null" json:"order_id"`
+	PromotionID *uint     `json:"promotion_id"`
+	Code        string    `json:"code"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	AmountCents int64     `gorm:"not null" json:"amount_cents"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PriceHistory păstrează fiecare schimbare de preț a unui produs
+type PriceHistory struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ProductID uint       `gorm:"index;not null" json:"product_id"`
+	Field     string     `gorm:"not null" json:"field"` // price, compare_at, sale_price
+	OldCents  *int64     `json:"old_cents"`
+	NewCents  *int64     `json:"new_cents"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Source    string     `json:"source"` // admin:<email> sau schedule:<id>
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ScheduledPriceChange e o schimbare de preț programată (ex. campanii sezoniere)
+type ScheduledPriceChange struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ProductID      uint       `gorm:"index;not null" json:"product_id"`
+	EffectiveAt    time.Time  `gorm:"index;not null" json:"effective_at"`
+	PriceCents     int64      `gorm:"not null" json:"price_cents"`
+	Price          float64    `gorm:"-" json:"price"`
+	CompareAtCents *int64     `json:"compare_at_cents"`
+	CompareAtPrice *float64   `gorm:"-" json:"compare_at_price"`
+	ClearCompareAt bool       `gorm:"not null;default:false" json:"clear_compare_at"`
+	Note           string     `json:"note"`
+	CreatedByID    *uint      `json:"created_by_id"`
+	AppliedAt      *time.Time `json:"applied_at"`
+	CancelledAt    *time.Time `json:"cancelled_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// OutboxEmail e un email scris în aceeași tranzacție cu datele care l-au generat
+// și trimis ulterior de workerul din outbox.go
+type OutboxEmail struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Template      string     `gorm:"index;not null" json:"template"`
+	ToEmail       string     `gorm:"not null" json:"to_email"`
+	ToName        string     `json:"to_name"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	HTML          string     `gorm:"type:text" json:"html,omitempty"`
+	Text          string     `gorm:"type:text" json:"text,omitempty"`
+	Status        string     `gorm:"not null;default:'pending';index:idx_outbox_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_due,priority:2" json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	OrderID       *uint      `gorm:"index" json:"order_id"`
+	InvoiceID     *uint      `json:"invoice_id"` // PDF-ul facturii e atașat la trimitere
+	UserID        *uint      `gorm:"index" json:"user_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Invoice e o factură proformă sau fiscală emisă pentru o comandă. Numărul
+// vine din InvoiceSequence și nu se refolosește; PDF-ul e generat la emitere
+// și păstrat neschimbat, chiar dacă comanda se modifică ulterior.
+type Invoice struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	OrderID    uint      `gorm:"index;not null" json:"order_id"`
+	Kind       string    `gorm:"size:10;not null" json:"kind"`
+	Series     string    `gorm:"size:10;not null;uniqueIndex:idx_invoice_number,priority:1" json:"series"`
+	Number     int64     `gorm:"not null;uniqueIndex:idx_invoice_number,priority:2" json:"number"`
+	Code       string    `gorm:"-" json:"code"` // seria și numărul, ex. "SL 000042"
+	TotalCents int64     `gorm:"not null" json:"total_cents"`
+	IssuedAt   time.Time `gorm:"not null" json:"issued_at"`
+	IssuedBy   string    `json:"issued_by"`
+	PDF        []byte    `gorm:"type:bytea;not null" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// InvoiceSequence ține ultimul număr emis pentru fiecare serie
+type InvoiceSequence struct {
+	Series     string `gorm:"primaryKey;size:10"`
+	LastNumber int64  `gorm:"not null;default:0"`
+}
+
+// WishlistItem e un produs salvat la favorite de un client autentificat;
+// oaspeții își păstrează lista în cookie-ul guestWishlist.
+type WishlistItem struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"index;not null" json:"userId"`
+	ProductID uint            `gorm:"index;not null" json:"productId"`
+	VariantID *uint           `gorm:"index" json:"variantId"`
+	Product   Product         `json:"product"`
+	Variant   *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// IdempotencyKey păstrează răspunsul unei cereri trimise cu antetul Idempotency-Key,
+// ca repetarea ei (dublu click, reconectare) să nu creeze a doua oară aceleași date
+type IdempotencyKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Scope       string     `gorm:"size:40;not null;uniqueIndex:idx_idempotency_key,priority:1" json:"scope"` // user:12, admin:3 sau guest:<id din cookie>
+	Key         string     `gorm:"size:255;not null;uniqueIndex:idx_idempotency_key,priority:2" json:"key"`
+	Route       string     `gorm:"not null" json:"route"`
+	Fingerprint string     `gorm:"size:64;not null" json:"fingerprint"` // sha256 peste metodă, cale și corp
+	StatusCode  int        `gorm:"not null;default:0" json:"status_code"`
+	Response    []byte     `gorm:"type:bytea" json:"-"`
+	ContentType string     `json:"content_type"`
+	CompletedAt *time.Time `json:"completed_at"` // nil cât timp cererea originală e în lucru
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
+
+// OrderTrackAttempt e o căutare eșuată pe pagina publică de urmărire a comenzii,
+// folosită ca să limităm încercările de ghicire a referințelor de pe același IP
+type OrderTrackAttempt struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"index" json:"userId"`
-	ProductID uint      `gorm:"index" json:"productId"`
-	Quantity  int       `gorm:"not null;default:1" json:"quantity"`
-	Product   Product   `json:"product"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	IP        string    `gorm:"size:64;not null;index:idx_track_attempt_ip,priority:1" json:"ip"`
+	Reference string    `gorm:"size:40" json:"reference"`
+	CreatedAt time.Time `gorm:"index:idx_track_attempt_ip,priority:2;index" json:"created_at"`
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrderPending      = "pending"
+	OrderConfirmed    = "confirmed"
+	OrderInProduction = "in_production"
+	OrderReady        = "ready"
+	OrderDelivering   = "delivering"
+	OrderDelivered    = "delivered"
+	OrderCancelled    = "cancelled"
+	OrderReturned     = "returned"
+)
+
+// orderTransitions descrie ciclul de viață al unei comenzi: status curent -> statusuri permise
+var orderTransitions = map[string][]string{
+	OrderPending:      {OrderConfirmed, OrderCancelled},
+	OrderConfirmed:    {OrderInProduction, OrderCancelled},
+	OrderInProduction: {OrderReady, OrderCancelled},
+	OrderReady:        {OrderDelivering, OrderCancelled},
+	OrderDelivering:   {OrderDelivered, OrderReady, OrderCancelled},
+	OrderDelivered:    {OrderReturned},
+	OrderCancelled:    {},
+	OrderReturned:     {},
+}
+
+var errUnknownOrderStatus = errors.New("Status invalid")
+
+// InvalidTransitionError e returnată pentru o tranziție nepermisă de status
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("Tranziție nepermisă: %s -> %s", e.From, e.To)
+}
+
+func canTransition(from, to string) bool {
+	for _, s := range orderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// releasesStock spune dacă intrarea în status readuce produsele în stoc
+func releasesStock(status string) bool {
+	return status == OrderCancelled || status == OrderReturned
+}
+
+// transitionOrder schimbă statusul comenzii în tranzacția dată și scrie evenimentul în istoric
+func transitionOrder(tx *gorm.DB, order *Order, to, actor, note string) error {
+	if _, ok := orderTransitions[to]; !ok {
+		return errUnknownOrderStatus
+	}
+	if !canTransition(order.Status, to) {
+		return &InvalidTransitionError{From: order.Status, To: to}
+	}
+	if err := checkDepositPaid(order, to); err != nil {
+		return err
+	}
+
+	from := order.Status
+	if err := tx.Model(order).Update("status", to).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&OrderStatusEvent{
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		Note:       note,
+	}).Error; err != nil {
+		return err
+	}
+
+	if releasesStock(to) {
+		if err := releasePromotion(tx, order.ID); err != nil {
+			return err
+		}
+		return releaseStock(tx, order.ID)
+	}
+	return nil
+}
+
+// legacyCompletedNote marchează comenzile care trebuie verificate manual după migrare
+const legacyCompletedNote = "Migrată din statusul vechi 'completed', setat automat la 5 secunde după plasare. De verificat manual."
+
+const legacyProcessingNote = "Migrată din statusul vechi 'processing'."
+
+// migrateLegacyOrderStatuses convertește statusurile vechi (processing/completed).
+// „processing” era pus de admin, deci comanda e în producție. „completed” era pus
+// automat oricărei comenzi, fără să însemne livrare, așa că o readucem în „pending”
+// cu o notă în istoric, ca atelierul să o confirme sau să o anuleze.
+func migrateLegacyOrderStatuses() {
+	if n, err := migrateLegacyStatus(DB, "processing", OrderInProduction, legacyProcessingNote); err != nil {
+		log.Printf("Eroare la migrarea statusului processing: %v", err)
+	} else if n > 0 {
+		log.Printf("Migrat %d comenzi din 'processing' în '%s'", n, OrderInProduction)
+	}
+
+	if n, err := migrateLegacyStatus(DB, "completed", OrderPending, legacyCompletedNote); err != nil {
+		log.Printf("Eroare la migrarea statusului completed: %v", err)
+	} else if n > 0 {
+		log.Printf("Migrat %d comenzi din 'completed' în '%s', marcate pentru verificare manuală", n, OrderPending)
+	}
+}
+
+// migrateLegacyStatus mută comenzile una câte una, fiecare în tranzacția ei și cu
+// evenimentul din istoric, ca o oprire la jumătate să nu lase comenzi fără istoric
+func migrateLegacyStatus(db *gorm.DB, from, to, note string) (int, error) {
+	var ids []uint
+	if err := db.Model(&Order{}).Where("status = ?", from).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, id := range ids {
+		changed := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Model(&Order{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			changed = true
+			return tx.Create(&OrderStatusEvent{
+				OrderID:    id,
+				FromStatus: from,
+				ToStatus:   to,
+				Actor:      "system:migration",
+				Note:       note,
+			}).Error
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("comanda %d: %w", id, err)
+		}
+		if changed {
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+// orderHistoryScope ordonează cronologic istoricul la preload
+func orderHistoryScope(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC, id ASC")
+}
+
+func orderActor(userID *uint) string {
+	if userID == nil {
+		return "guest"
+	}
+	return fmt.Sprintf("user:%d", *userID)
+}
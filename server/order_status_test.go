@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{OrderPending, OrderConfirmed, true},
+		{OrderPending, OrderCancelled, true},
+		{OrderPending, OrderInProduction, false},
+		{OrderConfirmed, OrderInProduction, true},
+		{OrderConfirmed, OrderDelivered, false},
+		{OrderInProduction, OrderReady, true},
+		{OrderInProduction, OrderConfirmed, false},
+		{OrderReady, OrderDelivering, true},
+		{OrderDelivering, OrderDelivered, true},
+		{OrderDelivering, OrderReady, true}, // livrare eșuată, înapoi în depozit
+		{OrderDelivered, OrderReturned, true},
+		{OrderDelivered, OrderCancelled, false},
+		{OrderCancelled, OrderPending, false},
+		{OrderReturned, OrderDelivered, false},
+		{OrderPending, OrderPending, false},
+		{"completed", OrderDelivered, false},
+		{OrderPending, "shipped", false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%q, %q) = %v, vrem %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestOrderTransitionsTable(t *testing.T) {
+	for from, targets := range orderTransitions {
+		for _, to := range targets {
+			if _, ok := orderTransitions[to]; !ok {
+				t.Errorf("%s -> %s: statusul țintă lipsește din tabel", from, to)
+			}
+			if to == from {
+				t.Errorf("%s -> %s: tranziție în același status", from, to)
+			}
+		}
+	}
+	// statusurile finale nu mai pot fi părăsite
+	for _, final := range []string{OrderCancelled, OrderReturned} {
+		if n := len(orderTransitions[final]); n != 0 {
+			t.Errorf("%s are %d tranziții, vrem 0", final, n)
+		}
+	}
+	// orice comandă încă nelivrată poate fi anulată
+	for _, s := range []string{OrderPending, OrderConfirmed, OrderInProduction, OrderReady, OrderDelivering} {
+		if !canTransition(s, OrderCancelled) {
+			t.Errorf("%s nu poate fi anulată", s)
+		}
+	}
+}
+
+func TestTransitionOrderRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		order Order
+		to    string
+		check func(error) bool
+	}{
+		{
+			name:  "status necunoscut",
+			order: Order{Status: OrderPending},
+			to:    "shipped",
+			check: func(err error) bool { return errors.Is(err, errUnknownOrderStatus) },
+		},
+		{
+			name:  "tranziție nepermisă",
+			order: Order{Status: OrderPending},
+			to:    OrderDelivered,
+			check: func(err error) bool {
+				var e *InvalidTransitionError
+				return errors.As(err, &e) && e.From == OrderPending && e.To == OrderDelivered
+			},
+		},
+		{
+			name:  "producție fără avans",
+			order: Order{Status: OrderConfirmed, DepositCents: 5000, PaidCents: 1000},
+			to:    OrderInProduction,
+			check: func(err error) bool {
+				var e *DepositDueError
+				return errors.As(err, &e) && e.DueCents == 4000
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// verificările au loc înainte de orice scriere, deci tranzacția nu e folosită
+			err := transitionOrder(nil, &tt.order, tt.to, "test", "")
+			if !tt.check(err) {
+				t.Errorf("eroare neașteptată: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyOrderStatuses(t *testing.T) {
+	db := newTestDB(t, &Order{}, &OrderStatusEvent{})
+	orders := []Order{{Status: "processing"}, {Status: "completed"}, {Status: OrderDelivered}}
+	if err := db.Create(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrateLegacyOrderStatuses()
+
+	want := map[uint]struct{ status, note string }{
+		orders[0].ID: {OrderInProduction, legacyProcessingNote},
+		orders[1].ID: {OrderPending, legacyCompletedNote},
+	}
+	for _, o := range orders {
+		var got Order
+		db.First(&got, o.ID)
+		var events []OrderStatusEvent
+		db.Where("order_id = ?", o.ID).Find(&events)
+
+		w, migrated := want[o.ID]
+		if !migrated {
+			if got.Status != o.Status || len(events) != 0 {
+				t.Errorf("comanda %s a fost atinsă: %s, %d evenimente", o.Status, got.Status, len(events))
+			}
+			continue
+		}
+		if got.Status != w.status {
+			t.Errorf("comanda %s: status %s, vrem %s", o.Status, got.Status, w.status)
+		}
+		if len(events) != 1 || events[0].FromStatus != o.Status || events[0].ToStatus != w.status ||
+			events[0].Actor != "system:migration" || events[0].Note != w.note {
+			t.Errorf("comanda %s: evenimente %+v", o.Status, events)
+		}
+	}
+
+	// a doua rulare, ca la fiecare pornire, nu mai scrie nimic
+	migrateLegacyOrderStatuses()
+	var count int64
+	db.Model(&OrderStatusEvent{}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d evenimente după a doua rulare, vrem 2", count)
+	}
+}
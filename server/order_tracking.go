@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const orderReferencePrefix = "SL"
+
+// orderReferenceAlphabet nu conține 0/O, 1/I/L, ca referința să poată fi dictată la telefon
+const orderReferenceAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+const orderReferenceCodeLen = 5
+
+// Pe pagina publică de urmărire, un IP are cel mult orderTrackMaxFailures căutări
+// eșuate în orderTrackWindow; cu 31^5 coduri pe an ghicirea devine impracticabilă.
+const (
+	orderTrackMaxFailures = 10
+	orderTrackWindow      = 15 * time.Minute
+)
+
+var errOrderReferenceExhausted = errors.New("nu s-a putut genera o referință unică")
+
+// randomOrderReference întoarce o referință de forma SL-2026-7K3QF
+func randomOrderReference(at time.Time) (string, error) {
+	code := make([]byte, orderReferenceCodeLen)
+	size := big.NewInt(int64(len(orderReferenceAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		code[i] = orderReferenceAlphabet[n.Int64()]
+	}
+	return fmt.Sprintf("%s-%d-%s", orderReferencePrefix, at.Year(), code), nil
+}
+
+// newOrderReference generează o referință publică nefolosită. Indexul unic
+// de pe orders.reference rămâne plasa de siguranță la inserări concurente.
+func newOrderReference(tx *gorm.DB, at time.Time) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		ref, err := randomOrderReference(at)
+		if err != nil {
+			return "", err
+		}
+		var n int64
+		if err := tx.Unscoped().Model(&Order{}).Where("reference = ?", ref).Count(&n).Error; err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return ref, nil
+		}
+	}
+	return "", errOrderReferenceExhausted
+}
+
+// migrateOrderReferences atribuie referințe comenzilor plasate înainte de introducerea lor
+func migrateOrderReferences() {
+	var orders []Order
+	if err := DB.Unscoped().Select("id", "created_at").Where("reference = ''").Find(&orders).Error; err != nil {
+		log.Println("Eroare la migrarea referințelor comenzilor:", err)
+		return
+	}
+	for _, o := range orders {
+		ref, err := newOrderReference(DB, o.CreatedAt)
+		if err == nil {
+			err = DB.Unscoped().Model(&Order{}).Where("id = ?", o.ID).Update("reference", ref).Error
+		}
+		if err != nil {
+			log.Printf("Eroare la atribuirea referinței pentru comanda %d: %v", o.ID, err)
+			return
+		}
+	}
+	if len(orders) > 0 {
+		log.Printf("Atribuite referințe publice pentru %d comenzi", len(orders))
+	}
+}
+
+// orderTrackURL e linkul din emailuri către pagina de urmărire a comenzii
+func orderTrackURL(reference string) string {
+	return fmt.Sprintf("%s/track?ref=%s",
+		strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:5173"), "/"), url.QueryEscape(reference))
+}
+
+// normalizeOrderReference acceptă referința scrisă cu litere mici sau spații
+func normalizeOrderReference(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+func phoneDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// samePhone compară ultimele 8 cifre, ca +373 69 123 456 și 069123456 să fie același număr
+func samePhone(a, b string) bool {
+	const significant = 8
+	a, b = phoneDigits(a), phoneDigits(b)
+	if len(a) < significant || len(b) < significant {
+		return false
+	}
+	return a[len(a)-significant:] == b[len(b)-significant:]
+}
+
+// orderContactMatches verifică datele de contact cerute la urmărirea comenzii
+func orderContactMatches(order Order, phone, email string) bool {
+	if phone != "" && samePhone(order.Phone, phone) {
+		return true
+	}
+	email = strings.TrimSpace(email)
+	return email != "" && order.Email != "" && strings.EqualFold(strings.TrimSpace(order.Email), email)
+}
+
+// deliveryWindow e intervalul stabilit de atelier sau, după ce comanda e gata,
+// ziua în care a devenit gata plus termenul zonei de livrare
+func deliveryWindow(order Order) (from, to *time.Time) {
+	if order.DeliveryFrom != nil || order.DeliveryTo != nil {
+		return order.DeliveryFrom, order.DeliveryTo
+	}
+	if order.Status != OrderReady && order.Status != OrderDelivering {
+		return nil, nil
+	}
+	var readyAt time.Time
+	for _, ev := range order.History {
+		if ev.ToStatus == OrderReady {
+			readyAt = ev.CreatedAt
+		}
+	}
+	if readyAt.IsZero() {
+		return nil, nil
+	}
+	days := order.DeliveryLeadDays
+	if days < 1 {
+		days = 1
+	}
+	start := time.Date(readyAt.Year(), readyAt.Month(), readyAt.Day(), 0, 0, 0, 0, readyAt.Location())
+	end := start.AddDate(0, 0, days)
+	return &start, &end
+}
+
+type trackedOrderItem struct {
+	Name           string `json:"name"`
+	Variant        string `json:"variant"`
+	Quantity       int    `json:"quantity"`
+	LineTotalCents int64  `json:"line_total_cents"`
+}
+
+type trackedOrderEvent struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// trackedOrder e ce vede un vizitator la urmărirea comenzii: fără adresă,
+// note interne sau autorul schimbărilor
+type trackedOrder struct {
+	Reference       string              `json:"reference"`
+	Status          string              `json:"status"`
+	CreatedAt       time.Time           `json:"created_at"`
+	City            string              `json:"city"`
+	DeliveryZone    string              `json:"delivery_zone"`
+	DeliveryFrom    *time.Time          `json:"delivery_from"`
+	DeliveryTo      *time.Time          `json:"delivery_to"`
+	DeliveredAt     *time.Time          `json:"delivered_at"`
+	PaymentMethod   string              `json:"payment_method"`
+	PaymentStatus   string              `json:"payment_status"`
+	TotalCents      int64               `json:"total_cents"`
+	PaidCents       int64               `json:"paid_cents"`
+	DepositDueCents int64               `json:"deposit_due_cents"`
+	BalanceCents    int64               `json:"balance_cents"`
+	Items           []trackedOrderItem  `json:"items"`
+	History         []trackedOrderEvent `json:"history"`
+}
+
+func newTrackedOrder(order Order) trackedOrder {
+	withPaymentTotals(&order)
+	t := trackedOrder{
+		Reference:       order.Reference,
+		Status:          order.Status,
+		CreatedAt:       order.CreatedAt,
+		City:            order.City,
+		DeliveryZone:    order.DeliveryZoneName,
+		PaymentMethod:   order.PaymentMethod,
+		PaymentStatus:   order.PaymentStatus,
+		TotalCents:      order.TotalCents,
+		PaidCents:       order.PaidCents,
+		DepositDueCents: order.DepositDueCents,
+		BalanceCents:    order.BalanceCents,
+		Items:           []trackedOrderItem{},
+		History:         []trackedOrderEvent{},
+	}
+	t.DeliveryFrom, t.DeliveryTo = deliveryWindow(order)
+	for _, it := range order.Items {
+		t.Items = append(t.Items, trackedOrderItem{
+			Name:           it.Product.Name,
+			Variant:        it.VariantLabel,
+			Quantity:       it.Quantity,
+			LineTotalCents: it.PriceCents*int64(it.Quantity) - it.DiscountCents,
+		})
+	}
+	// în istoric rămân doar schimbările de status, nu și plățile sau notele
+	for _, ev := range order.History {
+		if ev.FromStatus == ev.ToStatus {
+			continue
+		}
+		t.History = append(t.History, trackedOrderEvent{Status: ev.ToStatus, At: ev.CreatedAt})
+		if ev.ToStatus == OrderDelivered {
+			at := ev.CreatedAt
+			t.DeliveredAt = &at
+		}
+	}
+	return t
+}
+
+// trackOrder: GET /api/orders/track?reference=SL-2026-7K3QF&phone=... (sau &email=...)
+// Public: comanda e arătată doar dacă telefonul sau emailul se potrivesc.
+func trackOrder(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	reference := normalizeOrderReference(query.Get("reference"))
+	phone := strings.TrimSpace(query.Get("phone"))
+	email := strings.TrimSpace(query.Get("email"))
+	if reference == "" || (phone == "" && email == "") {
+		http.Error(w, "Introdu numărul comenzii și telefonul sau emailul folosit la comandă", http.StatusBadRequest)
+		return
+	}
+
+	ip := clientIP(r)
+	var failures int64
+	if err := DB.Model(&OrderTrackAttempt{}).
+		Where("ip = ? AND created_at > ?", ip, time.Now().Add(-orderTrackWindow)).
+		Count(&failures).Error; err != nil {
+		log.Printf("Eroare la verificarea încercărilor de urmărire pentru %s: %v", ip, err)
+		http.Error(w, "Eroare la căutarea comenzii", http.StatusInternalServerError)
+		return
+	}
+	if failures >= orderTrackMaxFailures {
+		w.Header().Set("Retry-After", strconv.Itoa(int(orderTrackWindow.Seconds())))
+		http.Error(w, "Prea multe încercări. Încearcă din nou peste câteva minute.", http.StatusTooManyRequests)
+		return
+	}
+
+	// același răspuns pentru referință inexistentă și date greșite, ca să nu
+	// poată fi ghicite referințele valide
+	notFound := "Comanda nu a fost găsită. Verifică numărul comenzii și datele de contact."
+	var order Order
+	err := DB.Preload("Items.Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("History", orderHistoryScope).
+		Where("reference = ?", reference).First(&order).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Eroare la urmărirea comenzii %s: %v", reference, err)
+			http.Error(w, "Eroare la căutarea comenzii", http.StatusInternalServerError)
+			return
+		}
+		recordOrderTrackFailure(ip, reference)
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	if !orderContactMatches(order, phone, email) {
+		recordOrderTrackFailure(ip, reference)
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-store")
+	json.NewEncoder(w).Encode(newTrackedOrder(order))
+}
+
+// recordOrderTrackFailure păstrează căutarea eșuată pentru limitarea pe IP
+func recordOrderTrackFailure(ip, reference string) {
+	if r := []rune(reference); len(r) > 40 {
+		reference = string(r[:40])
+	}
+	log.Printf("Urmărire eșuată a comenzii %q de pe %s", reference, ip)
+	if err := DB.Create(&OrderTrackAttempt{IP: ip, Reference: reference}).Error; err != nil {
+		log.Println("Eroare la salvarea încercării de urmărire:", err)
+	}
+}
+
+// purgeOrderTrackAttempts șterge încercările mai vechi de o zi; limita folosește doar ultimele minute
+func purgeOrderTrackAttempts() error {
+	return DB.Where("created_at < ?", time.Now().Add(-24*time.Hour)).Delete(&OrderTrackAttempt{}).Error
+}
+
+func runOrderTrackCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := purgeOrderTrackAttempts(); err != nil {
+			log.Println("Eroare la ștergerea încercărilor de urmărire vechi:", err)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // a epuizat încercările; se retrimite doar manual
+)
+
+const (
+	outboxPollInterval = 15 * time.Second
+	outboxBatchSize    = 20
+	outboxMaxAttempts  = 8
+	outboxBaseDelay    = 30 * time.Second
+	outboxMaxDelay     = 6 * time.Hour
+)
+
+// Șabloanele care conțin linkuri cu tokenuri: conținutul nu e afișat în admin
+// și e șters după trimitere, ca tokenul să nu rămână în clar în baza de date.
+var sensitiveEmailTemplates = map[string]bool{
+	"verify_email":   true,
+	"password_reset": true,
+	"admin_invite":   true,
+}
+
+// outboxWake trezește workerul după commit, ca emailurile să nu aștepte următorul tick
+var outboxWake = make(chan struct{}, 1)
+
+func wakeOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueEmail randează șablonul și scrie emailul în outbox folosind tranzacția
+// apelantului. Câmpurile OrderID/UserID din email leagă mesajul de sursă.
+// După commit, apelantul poate chema wakeOutbox() pentru trimitere imediată.
+func enqueueEmail(tx *gorm.DB, email OutboxEmail, lang string, data interface{}) error {
+	msg, err := renderEmail(lang, email.Template, data)
+	if err != nil {
+		return err
+	}
+	email.Subject = msg.Subject
+	email.HTML = msg.HTML
+	email.Text = msg.Text
+	email.Status = OutboxPending
+	email.NextAttemptAt = time.Now()
+	return tx.Create(&email).Error
+}
+
+// enqueueOrderEmail pune în coadă emailul către client pentru evenimentul dat;
+// comanda trebuie să aibă Items.Product încărcate.
+func enqueueOrderEmail(tx *gorm.DB, order Order, kind, note string) error {
+	return enqueueOrderEmailWithInvoice(tx, order, kind, note, nil)
+}
+
+// enqueueOrderEmailWithInvoice e enqueueOrderEmail cu factura atașată, dacă există
+func enqueueOrderEmailWithInvoice(tx *gorm.DB, order Order, kind, note string, invoice *Invoice) error {
+	if order.Email == "" || !validEmail(order.Email) {
+		return nil
+	}
+	email := OutboxEmail{
+		Template: "order_status",
+		ToEmail:  order.Email,
+		ToName:   order.Name,
+		OrderID:  &order.ID,
+		UserID:   order.UserID,
+	}
+	data := newOrderEmailData(order, kind, note)
+	if invoice != nil {
+		email.InvoiceID = &invoice.ID
+		data.Invoice = invoice.Code
+		data.InvoiceProforma = invoice.Kind == InvoiceProforma
+	}
+	return enqueueEmail(tx, email, order.Locale, data)
+}
+
+// enqueueAdminOrderEmail anunță administratorul (EMAIL_TO) despre o comandă nouă
+func enqueueAdminOrderEmail(tx *gorm.DB, order Order) error {
+	to := getEnv("EMAIL_TO", "")
+	if to == "" {
+		return nil
+	}
+	return enqueueEmail(tx, OutboxEmail{
+		Template: "admin_new_order",
+		ToEmail:  to,
+		ToName:   "Administrator",
+		OrderID:  &order.ID,
+	}, defaultLocale, newOrderEmailData(order, "", ""))
+}
+
+// outboxBackoff: 30s, 1m, 2m, 4m... plafonat la 6 ore
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBaseDelay
+	for i := 1; i < attempts && d < outboxMaxDelay; i++ {
+		d *= 2
+	}
+	if d > outboxMaxDelay {
+		d = outboxMaxDelay
+	}
+	return d
+}
+
+// processOutbox trimite emailurile scadente. Fiecare mesaj e blocat cu
+// SKIP LOCKED pe durata trimiterii, deci mai multe instanțe nu îl dublează.
+func processOutbox() error {
+	for i := 0; i < outboxBatchSize; i++ {
+		found := false
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var email OutboxEmail
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("status = ? AND next_attempt_at <= ?", OutboxPending, time.Now()).
+				Order("next_attempt_at ASC, id ASC").
+				First(&email).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			found = true
+
+			msg := Message{
+				To:      email.ToEmail,
+				ToName:  email.ToName,
+				Subject: email.Subject,
+				HTML:    email.HTML,
+				Text:    email.Text,
+			}
+			var sendErr error
+			if email.InvoiceID != nil {
+				var attachment Attachment
+				if attachment, sendErr = invoiceAttachment(tx, *email.InvoiceID); sendErr == nil {
+					msg.Attachments = []Attachment{attachment}
+				}
+			}
+			if sendErr == nil {
+				sendErr = Mail.Send(msg)
+			}
+
+			updates := map[string]interface{}{"attempts": email.Attempts + 1}
+			switch {
+			case sendErr == nil:
+				updates["status"] = OutboxSent
+				updates["sent_at"] = time.Now()
+				updates["last_error"] = ""
+				if sensitiveEmailTemplates[email.Template] {
+					updates["html"] = ""
+					updates["text"] = ""
+				}
+			case email.Attempts+1 >= outboxMaxAttempts:
+				updates["status"] = OutboxDead
+				updates["last_error"] = sendErr.Error()
+				log.Printf("Emailul #%d (%s) a eșuat definitiv: %v", email.ID, email.Template, sendErr)
+			default:
+				updates["last_error"] = sendErr.Error()
+				updates["next_attempt_at"] = time.Now().Add(outboxBackoff(email.Attempts + 1))
+				log.Printf("Emailul #%d (%s) a eșuat, reîncercare: %v", email.ID, email.Template, sendErr)
+			}
+			return tx.Model(&email).Updates(updates).Error
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+	}
+	return nil
+}
+
+// runOutboxWorker trimite periodic emailurile din outbox
+func runOutboxWorker() {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := processOutbox(); err != nil {
+			log.Println("Eroare la procesarea outbox-ului de emailuri:", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-outboxWake:
+		}
+	}
+}
+
+// --- Admin ---
+
+// hideSensitiveEmail ascunde conținutul emailurilor cu tokenuri
+func hideSensitiveEmail(e *OutboxEmail) {
+	if sensitiveEmailTemplates[e.Template] {
+		e.HTML = ""
+		e.Text = ""
+	}
+}
+
+// getAdminEmails: GET /api/admin/emails (fără conținut, doar metadate)
+func getAdminEmails(w http.ResponseWriter, r *http.Request) {
+	var emails []OutboxEmail
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	// Filtru opțional {"status": "dead", "template": "order_status", "order_id": 3, "q": "ion@"}
+	var filter struct {
+		Status   string `json:"status"`
+		Template string `json:"template"`
+		OrderID  uint   `json:"order_id"`
+		Q        string `json:"q"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+
+	q := DB.Model(&OutboxEmail{})
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.Template != "" {
+		q = q.Where("template = ?", filter.Template)
+	}
+	if filter.OrderID != 0 {
+		q = q.Where("order_id = ?", filter.OrderID)
+	}
+	if filter.Q != "" {
+		q = q.Where("(to_email ILIKE ? OR subject ILIKE ?)", "%"+filter.Q+"%", "%"+filter.Q+"%")
+	}
+
+	q.Count(&total)
+
+	if err := q.Omit("html", "text").Order("created_at DESC, id DESC").Offset(start).Limit(pageSize).Find(&emails).Error; err != nil {
+		http.Error(w, "Eroare la preluarea emailurilor", http.StatusInternalServerError)
+		return
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("emails %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(emails)
+}
+
+// getAdminEmail: GET /api/admin/emails/{id}
+func getAdminEmail(w http.ResponseWriter, r *http.Request) {
+	var email OutboxEmail
+	if err := DB.First(&email, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Emailul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+	hideSensitiveEmail(&email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(email)
+}
+
+// resendAdminEmail: POST /api/admin/emails/{id}/resend pune mesajul din nou în coadă
+func resendAdminEmail(w http.ResponseWriter, r *http.Request) {
+	var email OutboxEmail
+	if err := DB.First(&email, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Emailul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+	if email.Status == OutboxPending {
+		http.Error(w, "Emailul este deja în coada de trimitere", http.StatusConflict)
+		return
+	}
+	if email.Text == "" && email.HTML == "" {
+		http.Error(w, "Conținutul emailului a fost șters după trimitere; generează un link nou", http.StatusConflict)
+		return
+	}
+
+	if err := DB.Model(&email).Updates(map[string]interface{}{
+		"status":          OutboxPending,
+		"attempts":        0,
+		"last_error":      "",
+		"next_attempt_at": time.Now(),
+	}).Error; err != nil {
+		http.Error(w, "Eroare la retrimiterea emailului", http.StatusInternalServerError)
+		return
+	}
+	email.Status = OutboxPending
+	email.Attempts = 0
+	email.LastError = ""
+	wakeOutbox()
+	hideSensitiveEmail(&email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(email)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const passwordResetTTL = time.Hour
+
+// Cererile de resetare sunt limitate pe IP și pe adresă, ca formularul să nu poată
+// fi folosit pentru a inunda o căsuță cu emailuri sau pentru a enumera conturi.
+const (
+	passwordResetMaxPerIP    = 10
+	passwordResetMaxPerEmail = 3
+	passwordResetWindow      = time.Hour
+)
+
+// passwordResetQueue primește adresele pentru care s-a cerut resetarea;
+// runPasswordResetWorker le procesează după ce clientul a primit răspunsul
+var passwordResetQueue = make(chan string, 100)
+
+type forgotPasswordReq struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// handleForgotPassword răspunde la fel indiferent dacă emailul există,
+// ca să nu poată fi folosit pentru a afla conturile înregistrate. Cererea face
+// aceeași muncă pentru orice adresă (limita și înregistrarea ei); căutarea contului
+// și emiterea tokenului se fac după răspuns, ca timpul să nu trădeze contul.
+func handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "JSON invalid")
+		return
+	}
+
+	resp := map[string]string{
+		"message": "Dacă adresa există în sistem, vei primi un email cu instrucțiuni de resetare.",
+	}
+
+	email := strings.TrimSpace(strings.ToLower(req.Email))
+	if !validEmail(email) {
+		okJSON(w, resp)
+		return
+	}
+
+	ip := clientIP(r)
+	since := time.Now().Add(-passwordResetWindow)
+	var byIP, byEmail int64
+	err := DB.Model(&PasswordResetAttempt{}).Where("ip = ? AND created_at > ?", ip, since).Count(&byIP).Error
+	if err == nil {
+		err = DB.Model(&PasswordResetAttempt{}).Where("email = ? AND created_at > ?", email, since).Count(&byEmail).Error
+	}
+	if err != nil {
+		log.Printf("Eroare la verificarea cererilor de resetare pentru %s: %v", ip, err)
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+	if byIP >= passwordResetMaxPerIP || byEmail >= passwordResetMaxPerEmail {
+		w.Header().Set("Retry-After", strconv.Itoa(int(passwordResetWindow.Seconds())))
+		httpError(w, http.StatusTooManyRequests, "Prea multe cereri de resetare. Încearcă din nou mai târziu.")
+		return
+	}
+	if err := DB.Create(&PasswordResetAttempt{IP: ip, Email: email}).Error; err != nil {
+		log.Println("Eroare la salvarea cererii de resetare:", err)
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	select {
+	case passwordResetQueue <- email:
+	default:
+		// coada e plină doar sub un val de cereri; nu vrem să le pierdem
+		go issuePasswordReset(email)
+	}
+	okJSON(w, resp)
+}
+
+// issuePasswordReset creează tokenul și pune emailul în outbox, dacă adresa are cont
+func issuePasswordReset(email string) {
+	var user User
+	if err := DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Eroare la căutarea utilizatorului pentru resetare:", err)
+		}
+		return
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		log.Println("Eroare la generarea tokenului de resetare:", err)
+		return
+	}
+
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s", getEnv("FRONTEND_URL", "http://localhost:5173"), token)
+	data := map[string]string{"Name": user.Name, "URL": resetURL}
+
+	now := time.Now()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// un singur token activ per utilizator
+		if err := tx.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(passwordResetTTL),
+		}).Error; err != nil {
+			return err
+		}
+		return enqueueEmail(tx, OutboxEmail{
+			Template: "password_reset",
+			ToEmail:  user.Email,
+			ToName:   user.Name,
+			UserID:   &user.ID,
+		}, defaultLocale, data)
+	})
+	if err != nil {
+		log.Println("Eroare la salvarea tokenului de resetare:", err)
+		return
+	}
+	wakeOutbox()
+}
+
+// purgePasswordResetAttempts șterge cererile mai vechi de o zi; limita folosește doar ultima oră
+func purgePasswordResetAttempts() error {
+	return DB.Where("created_at < ?", time.Now().Add(-24*time.Hour)).Delete(&PasswordResetAttempt{}).Error
+}
+
+func runPasswordResetWorker() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	purge := func() {
+		if err := purgePasswordResetAttempts(); err != nil {
+			log.Println("Eroare la ștergerea cererilor de resetare vechi:", err)
+		}
+	}
+	purge()
+	for {
+		select {
+		case email := <-passwordResetQueue:
+			issuePasswordReset(email)
+		case <-ticker.C:
+			purge()
+		}
+	}
+}
+
+func handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "JSON invalid")
+		return
+	}
+
+	if req.Token == "" {
+		httpError(w, http.StatusBadRequest, "Token lipsă")
+		return
+	}
+	if len(req.Password) < 6 {
+		httpError(w, http.StatusBadRequest, "Parola este prea scurtă")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	errInvalidToken := errors.New("invalid token")
+	now := time.Now()
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var resetToken PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(req.Token), now).
+			First(&resetToken).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidToken
+			}
+			return err
+		}
+
+		// marcarea condiționată împiedică folosirea aceluiași token în paralel
+		res := tx.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidToken
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", resetToken.UserID).Updates(map[string]interface{}{
+			"password_hash": string(hash),
+			"is_verified":   true,
+		}).Error; err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, resetToken.UserID)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidToken) {
+			httpError(w, http.StatusBadRequest, "Token invalid sau expirat")
+			return
+		}
+		log.Println("Eroare la resetarea parolei:", err)
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	clearAuthCookie(w)
+	okJSON(w, map[string]string{"message": "Parola a fost schimbată. Te poți autentifica cu noua parolă."})
+}
+
+// dropPasswordChangedAt șterge coloana folosită înainte de sesiuni pentru a invalida
+// tokenurile vechi; acum schimbarea parolei revocă direct sesiunile
+func dropPasswordChangedAt() {
+	if !DB.Migrator().HasColumn(&User{}, "password_changed_at") {
+		return
+	}
+	if err := DB.Migrator().DropColumn(&User{}, "password_changed_at"); err != nil {
+		log.Println("Eroare la ștergerea coloanei password_changed_at:", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func forgotPasswordRequest(ip, email string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/password/forgot", strings.NewReader(fmt.Sprintf(`{"email":%q}`, email)))
+	r.RemoteAddr = ip + ":40000"
+	w := httptest.NewRecorder()
+	handleForgotPassword(w, r)
+	return w
+}
+
+// drainPasswordResetQueue golește coada, ca testele să nu se influențeze între ele
+func drainPasswordResetQueue() []string {
+	var emails []string
+	for {
+		select {
+		case email := <-passwordResetQueue:
+			emails = append(emails, email)
+		default:
+			return emails
+		}
+	}
+}
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      func(i int) string
+		email   func(i int) string
+		allowed int
+	}{
+		{
+			name:    "aceeași adresă de pe IP-uri diferite",
+			ip:      func(i int) string { return fmt.Sprintf("10.0.0.%d", i+1) },
+			email:   func(int) string { return "ion@example.com" },
+			allowed: passwordResetMaxPerEmail,
+		},
+		{
+			name:    "adrese diferite de pe același IP",
+			ip:      func(int) string { return "10.0.1.1" },
+			email:   func(i int) string { return fmt.Sprintf("ion%d@example.com", i) },
+			allowed: passwordResetMaxPerIP,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t, &PasswordResetAttempt{})
+			drainPasswordResetQueue()
+			t.Cleanup(func() { drainPasswordResetQueue() })
+
+			for i := 0; i < tt.allowed; i++ {
+				if w := forgotPasswordRequest(tt.ip(i), tt.email(i)); w.Code != http.StatusOK {
+					t.Fatalf("cererea %d: status %d, vrem 200", i+1, w.Code)
+				}
+			}
+			w := forgotPasswordRequest(tt.ip(tt.allowed), tt.email(tt.allowed))
+			if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+				t.Fatalf("peste limită: status %d, vrem 429 cu Retry-After", w.Code)
+			}
+			if queued := drainPasswordResetQueue(); len(queued) != tt.allowed {
+				t.Errorf("%d resetări în coadă, vrem %d", len(queued), tt.allowed)
+			}
+		})
+	}
+}
+
+func TestIssuePasswordReset(t *testing.T) {
+	if err := loadEmailTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t, &User{}, &PasswordResetToken{}, &OutboxEmail{})
+	if err := db.Create(&User{ID: 1, Email: "ion@example.com", Name: "Ion"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	issuePasswordReset("necunoscut@example.com")
+	issuePasswordReset("ion@example.com")
+	issuePasswordReset("ion@example.com")
+
+	var active, emails int64
+	db.Model(&PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", 1).Count(&active)
+	db.Model(&OutboxEmail{}).Where("template = ?", "password_reset").Count(&emails)
+	if active != 1 {
+		t.Errorf("%d tokenuri active, vrem 1", active)
+	}
+	if emails != 2 {
+		t.Errorf("%d emailuri de resetare, vrem 2 (doar pentru contul existent)", emails)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PaymentIntent e răspunsul procesatorului la inițierea unei plăți
+type PaymentIntent struct {
+	ProviderRef string
+	RedirectURL string
+}
+
+// PaymentEvent e o notificare de la procesator, cu semnătura deja verificată
+type PaymentEvent struct {
+	ProviderRef string
+	Succeeded   bool
+	AmountCents int64
+	Reason      string
+}
+
+// PaymentGateway abstractizează procesatorul de plăți cu cardul. Clientul e
+// trimis la RedirectURL, iar rezultatul vine prin webhook.
+type PaymentGateway interface {
+	Name() string
+	CreateIntent(payment Payment, order Order, clientIP string) (PaymentIntent, error)
+	VerifyWebhook(r *http.Request) (PaymentEvent, error)
+	Refund(payment Payment, amountCents int64) error
+}
+
+// Payments e nil când plata cu cardul nu e configurată
+var Payments PaymentGateway
+
+var errInvalidWebhookSignature = errors.New("semnătură webhook invalidă")
+
+// InitPayments alege procesatorul după PAYMENT_DRIVER (maib sau fake).
+// Fără PAYMENT_DRIVER rămân disponibile doar numerarul și transferul bancar.
+func InitPayments() {
+	driver := getEnv("PAYMENT_DRIVER", "")
+	switch driver {
+	case "":
+		log.Println("Plăți cu cardul: dezactivate")
+		return
+	case "maib":
+		gw := &maibGateway{
+			apiURL:        strings.TrimRight(getEnv("MAIB_API_URL", "https://api.maibmerchants.md/v1"), "/"),
+			projectID:     os.Getenv("MAIB_PROJECT_ID"),
+			projectSecret: os.Getenv("MAIB_PROJECT_SECRET"),
+			signatureKey:  os.Getenv("MAIB_SIGNATURE_KEY"),
+			client:        &http.Client{Timeout: 15 * time.Second},
+		}
+		// fără cheia de semnătură webhook-urile ar putea fi falsificate
+		if gw.projectID == "" || gw.projectSecret == "" || gw.signatureKey == "" {
+			log.Fatal("PAYMENT_DRIVER=maib necesită MAIB_PROJECT_ID, MAIB_PROJECT_SECRET și MAIB_SIGNATURE_KEY")
+		}
+		Payments = gw
+	case "fake":
+		secret := fakePaymentSecret()
+		if secret == nil {
+			log.Fatal("PAYMENT_DRIVER=fake necesită PAYMENT_WEBHOOK_SECRET sau JWT_SECRET")
+		}
+		Payments = &fakeGateway{secret: secret}
+	default:
+		log.Fatalf("PAYMENT_DRIVER necunoscut: %s", driver)
+	}
+
+	log.Printf("Plăți cu cardul: %s", driver)
+}
+
+// paymentReturnURL e pagina din frontend la care procesatorul întoarce clientul
+func paymentReturnURL(orderID uint, result string) string {
+	return fmt.Sprintf("%s/checkout?order=%d&payment=%s",
+		strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:5173"), "/"), orderID, result)
+}
+
+func paymentWebhookURL(provider string) string {
+	return strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:8080"), "/") + "/api/payments/webhook/" + provider
+}
+
+func centsToAmount(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+func amountToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// --- maib e-commerce (redirect către pagina securizată a băncii) ---
+
+type maibGateway struct {
+	apiURL        string
+	projectID     string
+	projectSecret string
+	signatureKey  string
+	client        *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func (g *maibGateway) Name() string { return "maib" }
+
+// call trimite o cerere JSON și decodează câmpul "result" al răspunsului
+func (g *maibGateway) call(path, token string, body, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", g.apiURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK     bool            `json:"ok"`
+		Result json.RawMessage `json:"result"`
+		Errors []struct {
+			ErrorCode    string `json:"errorCode"`
+			ErrorMessage string `json:"errorMessage"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("maib %s: răspuns invalid (status %d): %v", path, resp.StatusCode, err)
+	}
+	if !envelope.OK {
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("maib %s: %s %s", path, envelope.Errors[0].ErrorCode, envelope.Errors[0].ErrorMessage)
+		}
+		return fmt.Errorf("maib %s: status %d", path, resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// accessToken întoarce tokenul curent, reînnoit cu un minut înainte de expirare
+func (g *maibGateway) accessToken() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.token != "" && time.Now().Before(g.tokenExpiry) {
+		return g.token, nil
+	}
+
+	var res struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresIn   int    `json:"expiresIn"`
+	}
+	if err := g.call("/generate-token", "", map[string]string{
+		"projectId":     g.projectID,
+		"projectSecret": g.projectSecret,
+	}, &res); err != nil {
+		return "", err
+	}
+	g.token = res.AccessToken
+	g.tokenExpiry = time.Now().Add(time.Duration(res.ExpiresIn)*time.Second - time.Minute)
+	return g.token, nil
+}
+
+func (g *maibGateway) CreateIntent(payment Payment, order Order, clientIP string) (PaymentIntent, error) {
+	token, err := g.accessToken()
+	if err != nil {
+		return PaymentIntent{}, err
+	}
+
+	// livrarea se trimite separat doar când se achită toată comanda odată
+	description, delivery := "Comanda "+order.Reference, order.DeliveryCents
+	if payment.AmountCents < order.TotalCents {
+		delivery = 0
+		if order.PaidCents == 0 {
+			description = "Avans comanda " + order.Reference
+		} else {
+			description = "Rest de plată comanda " + order.Reference
+		}
+	}
+
+	var res struct {
+		PayID  string `json:"payId"`
+		PayURL string `json:"payUrl"`
+	}
+	err = g.call("/pay", token, map[string]interface{}{
+		"amount":      centsToAmount(payment.AmountCents),
+		"currency":    payment.Currency,
+		"clientIp":    clientIP,
+		"language":    order.Locale,
+		"description": description,
+		"orderId":     fmt.Sprintf("%d-%d", order.ID, payment.ID),
+		"clientName":  order.Name,
+		"email":       order.Email,
+		"phone":       order.Phone,
+		"delivery":    centsToAmount(delivery),
+		"callbackUrl": paymentWebhookURL(g.Name()),
+		"okUrl":       paymentReturnURL(order.ID, "ok"),
+		"failUrl":     paymentReturnURL(order.ID, "failed"),
+	}, &res)
+	if err != nil {
+		return PaymentIntent{}, err
+	}
+	return PaymentIntent{ProviderRef: res.PayID, RedirectURL: res.PayURL}, nil
+}
+
+// VerifyWebhook: semnătura e base64(sha256(valorile din "result" sortate după
+// cheie, unite prin ":", plus ":" și cheia de semnătură)).
+func (g *maibGateway) VerifyWebhook(r *http.Request) (PaymentEvent, error) {
+	var body struct {
+		Result    map[string]interface{} `json:"result"`
+		Signature string                 `json:"signature"`
+	}
+	dec := json.NewDecoder(io.LimitReader(r.Body, 64<<10))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return PaymentEvent{}, err
+	}
+
+	keys := make([]string, 0, len(body.Result))
+	for k := range body.Result {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		values = append(values, fmt.Sprint(body.Result[k]))
+	}
+	values = append(values, g.signatureKey)
+	sum := sha256.Sum256([]byte(strings.Join(values, ":")))
+	expected := base64.StdEncoding.EncodeToString(sum[:])
+	if g.signatureKey == "" || !hmac.Equal([]byte(expected), []byte(body.Signature)) {
+		return PaymentEvent{}, errInvalidWebhookSignature
+	}
+
+	ev := PaymentEvent{
+		ProviderRef: fmt.Sprint(body.Result["payId"]),
+		Succeeded:   fmt.Sprint(body.Result["status"]) == "OK",
+	}
+	if n, ok := body.Result["amount"].(json.Number); ok {
+		amount, _ := n.Float64()
+		ev.AmountCents = amountToCents(amount)
+	}
+	if !ev.Succeeded {
+		ev.Reason = fmt.Sprintf("%v %v", body.Result["statusCode"], body.Result["statusMessage"])
+	}
+	return ev, nil
+}
+
+func (g *maibGateway) Refund(payment Payment, amountCents int64) error {
+	token, err := g.accessToken()
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Status string `json:"status"`
+	}
+	if err := g.call("/refund", token, map[string]interface{}{
+		"payId":        payment.ProviderRef,
+		"refundAmount": centsToAmount(amountCents),
+	}, &res); err != nil {
+		return err
+	}
+	if res.Status != "" && res.Status != "OK" {
+		return fmt.Errorf("maib refund: status %s", res.Status)
+	}
+	return nil
+}
+
+// --- Procesator fals, pentru dezvoltare locală și teste ---
+
+// fakeGateway trimite clientul la o pagină locală care simulează banca.
+// Webhook-ul are corpul {"ref", "status": "ok"|"failed", "amount_cents", "reason"}
+// și antetul X-Fake-Signature = hex(HMAC-SHA256(corp)).
+type fakeGateway struct {
+	secret []byte
+}
+
+// fakePaymentSecret întoarce nil dacă nu e setat nici PAYMENT_WEBHOOK_SECRET, nici JWT_SECRET
+func fakePaymentSecret() []byte {
+	if secret := os.Getenv("PAYMENT_WEBHOOK_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("fake-payments"))
+	return mac.Sum(nil)
+}
+
+func (g *fakeGateway) Name() string { return "fake" }
+
+func (g *fakeGateway) sign(body []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (g *fakeGateway) CreateIntent(payment Payment, order Order, clientIP string) (PaymentIntent, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return PaymentIntent{}, err
+	}
+	ref := "fake_" + hex.EncodeToString(buf)
+	url := strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:8080"), "/") + "/api/payments/fake/" + ref
+	return PaymentIntent{ProviderRef: ref, RedirectURL: url}, nil
+}
+
+func (g *fakeGateway) VerifyWebhook(r *http.Request) (PaymentEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		return PaymentEvent{}, err
+	}
+	if !hmac.Equal([]byte(g.sign(body)), []byte(r.Header.Get("X-Fake-Signature"))) {
+		return PaymentEvent{}, errInvalidWebhookSignature
+	}
+	var msg struct {
+		Ref         string `json:"ref"`
+		Status      string `json:"status"`
+		AmountCents int64  `json:"amount_cents"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return PaymentEvent{}, err
+	}
+	return PaymentEvent{
+		ProviderRef: msg.Ref,
+		Succeeded:   msg.Status == "ok",
+		AmountCents: msg.AmountCents,
+		Reason:      msg.Reason,
+	}, nil
+}
+
+func (g *fakeGateway) Refund(payment Payment, amountCents int64) error {
+	return nil
+}
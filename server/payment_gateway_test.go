@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// maibSignature calculează semnătura la fel ca maib: valorile din result sortate
+// după cheie, unite prin ":", urmate de cheia de semnătură
+func maibSignature(values []string, key string) string {
+	sum := sha256.Sum256([]byte(strings.Join(append(values, key), ":")))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func maibWebhookRequest(t *testing.T, result, signature string) *http.Request {
+	t.Helper()
+	body := `{"result":` + result + `,"signature":` + mustJSON(t, signature) + `}`
+	return httptest.NewRequest(http.MethodPost, "/api/payments/webhook/maib", strings.NewReader(body))
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestMaibVerifyWebhook(t *testing.T) {
+	const key = "test-signature-key"
+	g := &maibGateway{signatureKey: key}
+
+	// cheile sortate: amount, currency, orderId, payId, status, statusCode, statusMessage
+	okResult := `{"payId":"pay-1","orderId":"12-3","status":"OK","statusCode":"000","statusMessage":"Approved","amount":1299.9,"currency":"MDL"}`
+	okValues := []string{"1299.9", "MDL", "12-3", "pay-1", "OK", "000", "Approved"}
+	failResult := `{"payId":"pay-2","status":"FAIL","statusCode":"116","statusMessage":"Insufficient funds","amount":50}`
+	failValues := []string{"50", "pay-2", "FAIL", "116", "Insufficient funds"}
+
+	tests := []struct {
+		name      string
+		gateway   *maibGateway
+		result    string
+		signature string
+		wantErr   bool
+		want      PaymentEvent
+	}{
+		{
+			name:      "plată reușită",
+			gateway:   g,
+			result:    okResult,
+			signature: maibSignature(okValues, key),
+			want:      PaymentEvent{ProviderRef: "pay-1", Succeeded: true, AmountCents: 129990},
+		},
+		{
+			name:      "plată refuzată",
+			gateway:   g,
+			result:    failResult,
+			signature: maibSignature(failValues, key),
+			want:      PaymentEvent{ProviderRef: "pay-2", AmountCents: 5000, Reason: "116 Insufficient funds"},
+		},
+		{
+			name:      "sumă modificată după semnare",
+			gateway:   g,
+			result:    strings.Replace(okResult, "1299.9", "1.0", 1),
+			signature: maibSignature(okValues, key),
+			wantErr:   true,
+		},
+		{
+			name:      "status modificat după semnare",
+			gateway:   g,
+			result:    failResult,
+			signature: maibSignature([]string{"50", "pay-2", "OK", "116", "Insufficient funds"}, key),
+			wantErr:   true,
+		},
+		{
+			name:      "semnat cu altă cheie",
+			gateway:   g,
+			result:    okResult,
+			signature: maibSignature(okValues, "alta"),
+			wantErr:   true,
+		},
+		{
+			name:    "fără semnătură",
+			gateway: g,
+			result:  okResult,
+			wantErr: true,
+		},
+		{
+			name:      "cheie de semnătură nesetată",
+			gateway:   &maibGateway{},
+			result:    okResult,
+			signature: maibSignature(okValues, ""),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := tt.gateway.VerifyWebhook(maibWebhookRequest(t, tt.result, tt.signature))
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidWebhookSignature) {
+					t.Fatalf("eroare %v, vrem semnătură invalidă", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ev != tt.want {
+				t.Errorf("eveniment %+v, vrem %+v", ev, tt.want)
+			}
+		})
+	}
+
+	if _, err := g.VerifyWebhook(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("nu e json"))); err == nil {
+		t.Error("corp invalid acceptat")
+	}
+}
+
+func TestApplyPaymentEventAmountCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       PaymentEvent
+		wantStatus  string
+		wantPaid    int64
+		wantPayment string
+	}{
+		{
+			name:        "suma cerută",
+			event:       PaymentEvent{ProviderRef: "pay-1", Succeeded: true, AmountCents: 30000},
+			wantStatus:  PaymentSucceeded,
+			wantPaid:    30000,
+			wantPayment: OrderPartiallyPaid,
+		},
+		{
+			name:        "sumă mai mică decât cea cerută",
+			event:       PaymentEvent{ProviderRef: "pay-1", Succeeded: true, AmountCents: 100},
+			wantStatus:  PaymentFailed,
+			wantPayment: OrderUnpaid,
+		},
+		{
+			name:        "sumă mai mare decât cea cerută",
+			event:       PaymentEvent{ProviderRef: "pay-1", Succeeded: true, AmountCents: 30001},
+			wantStatus:  PaymentFailed,
+			wantPayment: OrderUnpaid,
+		},
+		{
+			name:        "plată refuzată",
+			event:       PaymentEvent{ProviderRef: "pay-1", AmountCents: 30000, Reason: "116"},
+			wantStatus:  PaymentFailed,
+			wantPayment: OrderUnpaid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &Order{}, &OrderStatusEvent{}, &Payment{})
+			order := Order{ID: 1, Reference: "SL-2026-TEST1", Name: "Ion", Phone: "069000000", Address: "str. 1", City: "Chișinău",
+				Status: OrderConfirmed, TotalCents: 100000, DepositCents: 30000, PaymentStatus: OrderUnpaid}
+			if err := db.Create(&order).Error; err != nil {
+				t.Fatal(err)
+			}
+			payment := Payment{OrderID: 1, Provider: "maib", ProviderRef: "pay-1", Status: PaymentPending, AmountCents: 30000}
+			if err := db.Create(&payment).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := applyPaymentEvent("maib", tt.event); err != nil {
+				t.Fatal(err)
+			}
+			// notificarea repetată nu mai schimbă nimic
+			if err := applyPaymentEvent("maib", tt.event); err != nil {
+				t.Fatal(err)
+			}
+
+			db.First(&payment, payment.ID)
+			db.First(&order, order.ID)
+			if payment.Status != tt.wantStatus {
+				t.Errorf("plata: status %s, vrem %s", payment.Status, tt.wantStatus)
+			}
+			if order.PaidCents != tt.wantPaid || order.PaymentStatus != tt.wantPayment {
+				t.Errorf("comanda: achitat %d (%s), vrem %d (%s)", order.PaidCents, order.PaymentStatus, tt.wantPaid, tt.wantPayment)
+			}
+		})
+	}
+
+	newTestDB(t, &Payment{})
+	if err := applyPaymentEvent("maib", PaymentEvent{ProviderRef: "necunoscut"}); !errors.Is(err, errUnknownPayment) {
+		t.Errorf("eroare %v, vrem plată necunoscută", err)
+	}
+}
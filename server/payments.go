@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metodele de plată pe care clientul le poate alege la comandă
+const (
+	PaymentCashOnDelivery = "cash_on_delivery"
+	PaymentBankTransfer   = "bank_transfer"
+	PaymentCard           = "card"
+)
+
+// Starea plății la nivel de comandă
+const (
+	OrderUnpaid        = "unpaid"
+	OrderPartiallyPaid = "partially_paid" // avans sau o parte din rest
+	OrderPaid          = "paid"
+	OrderRefunded      = "refunded"
+)
+
+// Starea unei încercări de plată online
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentRefunded  = "refunded"
+)
+
+var paymentMethods = map[string]bool{
+	PaymentCashOnDelivery: true,
+	PaymentBankTransfer:   true,
+	PaymentCard:           true,
+}
+
+var errUnknownPayment = errors.New("plată necunoscută")
+
+// validatePaymentMethod întoarce metoda normalizată sau motivul refuzului
+func validatePaymentMethod(method string) (string, error) {
+	if method == "" {
+		return PaymentCashOnDelivery, nil
+	}
+	if !paymentMethods[method] {
+		return "", errors.New("Metodă de plată invalidă")
+	}
+	if method == PaymentCard && Payments == nil {
+		return "", errors.New("Plata cu cardul nu este disponibilă momentan")
+	}
+	return method, nil
+}
+
+// startPayment creează o încercare de plată pentru suma datorată acum (avansul
+// sau, după avans, restul) și întoarce URL-ul la care trebuie trimis clientul.
+func startPayment(order Order, clientIP string) (string, error) {
+	amountCents := amountDueNow(order)
+	if amountCents <= 0 {
+		return "", errors.New("comanda nu are nimic de plată")
+	}
+	payment := Payment{
+		OrderID:     order.ID,
+		Provider:    Payments.Name(),
+		Status:      PaymentPending,
+		AmountCents: amountCents,
+		Currency:    "MDL",
+	}
+	if err := DB.Create(&payment).Error; err != nil {
+		return "", err
+	}
+
+	intent, err := Payments.CreateIntent(payment, order, clientIP)
+	if err != nil {
+		DB.Model(&payment).Updates(map[string]interface{}{
+			"status":         PaymentFailed,
+			"failure_reason": err.Error(),
+		})
+		return "", err
+	}
+	if err := DB.Model(&payment).Updates(map[string]interface{}{
+		"provider_ref": intent.ProviderRef,
+		"redirect_url": intent.RedirectURL,
+	}).Error; err != nil {
+		return "", err
+	}
+	return intent.RedirectURL, nil
+}
+
+// applyPaymentEvent aplică rezultatul primit de la procesator. Notificările
+// repetate pentru aceeași plată sunt ignorate.
+func applyPaymentEvent(provider string, ev PaymentEvent) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var payment Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_ref = ?", provider, ev.ProviderRef).
+			First(&payment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errUnknownPayment
+			}
+			return err
+		}
+		if payment.Status != PaymentPending {
+			return nil
+		}
+
+		if !ev.Succeeded {
+			return tx.Model(&payment).Updates(map[string]interface{}{
+				"status":         PaymentFailed,
+				"failure_reason": ev.Reason,
+			}).Error
+		}
+		if ev.AmountCents != payment.AmountCents {
+			log.Printf("Plata #%d: suma confirmată %d diferă de %d", payment.ID, ev.AmountCents, payment.AmountCents)
+			return tx.Model(&payment).Updates(map[string]interface{}{
+				"status":         PaymentFailed,
+				"failure_reason": fmt.Sprintf("Suma confirmată (%s) diferă de cea cerută", formatMDL(ev.AmountCents)),
+			}).Error
+		}
+
+		now := time.Now()
+		if err := tx.Model(&payment).Updates(map[string]interface{}{
+			"status":  PaymentSucceeded,
+			"paid_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return recordOrderPayment(tx, payment.OrderID, payment.AmountCents, now, "payment:"+provider,
+			fmt.Sprintf("Plată cu cardul confirmată: %s (%s)", formatMDL(payment.AmountCents), payment.ProviderRef))
+	})
+}
+
+// handlePaymentWebhook: POST /api/payments/webhook/{provider}
+func handlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	if Payments == nil || provider != Payments.Name() {
+		http.Error(w, "Procesator necunoscut", http.StatusNotFound)
+		return
+	}
+
+	ev, err := Payments.VerifyWebhook(r)
+	if err != nil {
+		log.Printf("Webhook %s respins: %v", provider, err)
+		http.Error(w, "Notificare invalidă", http.StatusBadRequest)
+		return
+	}
+	if err := applyPaymentEvent(provider, ev); err != nil {
+		if errors.Is(err, errUnknownPayment) {
+			http.Error(w, "Plata nu a fost găsită", http.StatusNotFound)
+			return
+		}
+		log.Printf("Eroare la procesarea webhook-ului %s pentru %s: %v", provider, ev.ProviderRef, err)
+		http.Error(w, "Eroare la procesarea plății", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+var fakePaymentPage = template.Must(template.New("fake").Parse(`<!DOCTYPE html>
+<html lang="ro"><head><meta charset="utf-8"><title>Plată de test</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 60px auto">
+<h2>Plată de test</h2>
+<p>Comanda #{{.OrderID}} &mdash; {{.Amount}}</p>
+<p><a href="?result=ok">Plătește</a> &middot; <a href="?result=failed">Refuză</a></p>
+</body></html>`))
+
+// fakePaymentCheckout: GET /api/payments/fake/{ref}[?result=ok|failed]
+// Simulează pagina băncii pentru procesatorul fals.
+func fakePaymentCheckout(w http.ResponseWriter, r *http.Request) {
+	if _, ok := Payments.(*fakeGateway); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var payment Payment
+	if err := DB.Where("provider = ? AND provider_ref = ?", "fake", mux.Vars(r)["ref"]).First(&payment).Error; err != nil {
+		http.Error(w, "Plata nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	result := r.URL.Query().Get("result")
+	if result == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fakePaymentPage.Execute(w, map[string]interface{}{
+			"OrderID": payment.OrderID,
+			"Amount":  formatMDL(payment.AmountCents),
+		})
+		return
+	}
+
+	ev := PaymentEvent{ProviderRef: payment.ProviderRef, Succeeded: result == "ok", AmountCents: payment.AmountCents}
+	if !ev.Succeeded {
+		ev.Reason = "Refuzată în pagina de test"
+	}
+	if err := applyPaymentEvent("fake", ev); err != nil {
+		http.Error(w, "Eroare la procesarea plății", http.StatusInternalServerError)
+		return
+	}
+	if ev.Succeeded {
+		http.Redirect(w, r, paymentReturnURL(payment.OrderID, "ok"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, paymentReturnURL(payment.OrderID, "failed"), http.StatusSeeOther)
+}
+
+// payOrder: POST /api/orders/{id}/pay — reia plata cu cardul pentru o comandă a utilizatorului
+func payOrder(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(uint)
+
+	var order Order
+	if err := DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], userID).First(&order).Error; err != nil {
+		http.Error(w, "Comanda nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	if Payments == nil || order.PaymentMethod != PaymentCard {
+		http.Error(w, "Comanda nu se plătește cu cardul", http.StatusBadRequest)
+		return
+	}
+	if amountDueNow(order) <= 0 || releasesStock(order.Status) {
+		http.Error(w, "Comanda nu mai poate fi plătită", http.StatusConflict)
+		return
+	}
+
+	url, err := startPayment(order, clientIP(r))
+	if err != nil {
+		log.Printf("Eroare la inițierea plății pentru comanda %d: %v", order.ID, err)
+		http.Error(w, "Plata nu a putut fi inițiată, încearcă din nou", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"payment_url": url})
+}
+
+// --- Admin ---
+
+// getAdminOrderPayments: GET /api/admin/orders/{id}/payments
+func getAdminOrderPayments(w http.ResponseWriter, r *http.Request) {
+	var payments []Payment
+	if err := DB.Where("order_id = ?", mux.Vars(r)["id"]).Order("created_at DESC, id DESC").Find(&payments).Error; err != nil {
+		http.Error(w, "Eroare la preluarea plăților", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+// refundAdminPayment: POST /api/admin/payments/{id}/refund {amount}
+// Fără sumă se rambursează tot ce a rămas din plată. Plățile manuale sunt doar
+// trecute în evidență; banii se returnează de atelier.
+func refundAdminPayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+
+	var payment Payment
+	if err := DB.First(&payment, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Plata nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	if payment.Status != PaymentSucceeded {
+		http.Error(w, "Doar plățile reușite pot fi rambursate", http.StatusConflict)
+		return
+	}
+	manual := payment.Provider == PaymentProviderManual
+	if !manual && (Payments == nil || Payments.Name() != payment.Provider) {
+		http.Error(w, "Procesatorul acestei plăți nu mai este configurat", http.StatusConflict)
+		return
+	}
+
+	remaining := payment.AmountCents - payment.RefundedCents
+	amountCents := int64(math.Round(req.Amount * 100))
+	if amountCents == 0 {
+		amountCents = remaining
+	}
+	if amountCents < 0 || amountCents > remaining {
+		http.Error(w, fmt.Sprintf("Suma rambursată trebuie să fie între 0 și %s", formatMDL(remaining)), http.StatusBadRequest)
+		return
+	}
+
+	// suma e rezervată înainte de apelul către procesator: dintre două rambursări
+	// simultane pornite de la aceeași stare, doar una trece de condiția pe refunded_cents
+	claim := DB.Model(&Payment{}).
+		Where("id = ? AND status = ? AND refunded_cents = ?", payment.ID, PaymentSucceeded, payment.RefundedCents).
+		Update("refunded_cents", gorm.Expr("refunded_cents + ?", amountCents))
+	if claim.Error != nil {
+		log.Printf("Eroare la rezervarea rambursării plății #%d: %v", payment.ID, claim.Error)
+		http.Error(w, "Eroare la rambursare", http.StatusInternalServerError)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		http.Error(w, "Plata a fost modificată între timp, reîncarcă pagina și încearcă din nou", http.StatusConflict)
+		return
+	}
+	payment.RefundedCents += amountCents
+
+	if !manual {
+		if err := Payments.Refund(payment, amountCents); err != nil {
+			log.Printf("Eroare la rambursarea plății #%d: %v", payment.ID, err)
+			if err := DB.Model(&Payment{}).Where("id = ?", payment.ID).
+				Update("refunded_cents", gorm.Expr("refunded_cents - ?", amountCents)).Error; err != nil {
+				log.Printf("Rezervarea rambursării plății #%d (%d) nu a putut fi anulată: %v", payment.ID, amountCents, err)
+			}
+			http.Error(w, "Procesatorul a refuzat rambursarea", http.StatusBadGateway)
+			return
+		}
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if payment.RefundedCents == payment.AmountCents {
+			payment.Status = PaymentRefunded
+			if err := tx.Model(&payment).Update("status", PaymentRefunded).Error; err != nil {
+				return err
+			}
+		}
+
+		var order Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+		paidCents := order.PaidCents - amountCents
+		if paidCents < 0 {
+			paidCents = 0
+		}
+		status := orderPaymentStatus(paidCents, order.TotalCents)
+		if paidCents == 0 {
+			status = OrderRefunded
+		}
+		orderUpdates := map[string]interface{}{"paid_cents": paidCents, "payment_status": status}
+		if status != OrderPaid {
+			orderUpdates["paid_at"] = nil
+		}
+		if err := tx.Model(&order).Updates(orderUpdates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrderStatusEvent{
+			OrderID:    order.ID,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			Actor:      adminActor(r),
+			Note:       fmt.Sprintf("Rambursare %s", formatMDL(amountCents)),
+		}).Error
+	})
+	if err != nil {
+		// banii au fost deja returnați; doar evidența a eșuat
+		log.Printf("Rambursarea plății #%d (%d) nu a fost salvată: %v", payment.ID, amountCents, err)
+		http.Error(w, "Rambursarea a fost făcută, dar nu a putut fi salvată", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
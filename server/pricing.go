@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const priceSchedulerInterval = time.Minute
+
+// saleActive: reducerea se aplică doar dacă e mai mică decât prețul de bază
+// și suntem în intervalul [SaleStartsAt, SaleEndsAt)
+func saleActive(p Product, now time.Time) bool {
+	if p.SalePriceCents == nil || *p.SalePriceCents <= 0 || *p.SalePriceCents >= p.PriceCents {
+		return false
+	}
+	if p.SaleStartsAt != nil && now.Before(*p.SaleStartsAt) {
+		return false
+	}
+	if p.SaleEndsAt != nil && !now.Before(*p.SaleEndsAt) {
+		return false
+	}
+	return true
+}
+
+// effectivePriceSQL e regula din saleActive/effectivePrice scrisă în SQL, ca sortarea,
+// filtrele de preț și fațetele să folosească prețul pe care îl vede clientul.
+// table e aliasul tabelei products din interogare ("products", "p").
+func effectivePriceSQL(table string) string {
+	return fmt.Sprintf("(CASE WHEN %[1]s.sale_price_cents > 0 AND %[1]s.sale_price_cents < %[1]s.price_cents"+
+		" AND (%[1]s.sale_starts_at IS NULL OR %[1]s.sale_starts_at <= NOW())"+
+		" AND (%[1]s.sale_ends_at IS NULL OR %[1]s.sale_ends_at > NOW())"+
+		" THEN %[1]s.sale_price_cents ELSE %[1]s.price_cents END)", table)
+}
+
+// effectivePrice întoarce prețul de vânzare curent și prețul original de afișat tăiat
+// (nil dacă nu există o reducere sau un preț vechi mai mare).
+func effectivePrice(p Product, now time.Time) (int64, *int64) {
+	if saleActive(p, now) {
+		original := p.PriceCents
+		return *p.SalePriceCents, &original
+	}
+	if p.CompareAtCents != nil && *p.CompareAtCents > p.PriceCents {
+		original := *p.CompareAtCents
+		return p.PriceCents, &original
+	}
+	return p.PriceCents, nil
+}
+
+// withEffectivePrice completează câmpurile calculate pentru rutele publice
+func withEffectivePrice(p *Product) {
+	p.EffectivePriceCents, p.OriginalPriceCents = effectivePrice(*p, time.Now())
+	p.OnSale = p.OriginalPriceCents != nil
+	for i := range p.Variants {
+		p.Variants[i].EffectivePriceCents = p.EffectivePriceCents + p.Variants[i].PriceDeltaCents
+	}
+}
+
+func centsPtr(v *float64) *int64 {
+	if v == nil || *v <= 0 {
+		return nil
+	}
+	c := int64(math.Round(*v * 100))
+	return &c
+}
+
+func centsToFloatPtr(c *int64) *float64 {
+	if c == nil {
+		return nil
+	}
+	f := float64(*c) / 100
+	return &f
+}
+
+func sameCents(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// validateSale verifică prețul redus față de prețul de bază și intervalul
+func validateSale(priceCents int64, sale *int64, startsAt, endsAt *time.Time) error {
+	if sale == nil {
+		return nil
+	}
+	if *sale >= priceCents {
+		return errors.New("Prețul redus trebuie să fie mai mic decât prețul produsului")
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errors.New("Sfârșitul reducerii trebuie să fie după început")
+	}
+	return nil
+}
+
+// recordPriceChanges scrie în istoric câmpurile de preț care diferă între două stări
+func recordPriceChanges(tx *gorm.DB, before, after Product, source string) error {
+	price, newPrice := before.PriceCents, after.PriceCents
+	changes := []PriceHistory{}
+	if price != newPrice {
+		changes = append(changes, PriceHistory{Field: "price", OldCents: &price, NewCents: &newPrice})
+	}
+	if !sameCents(before.CompareAtCents, after.CompareAtCents) {
+		changes = append(changes, PriceHistory{Field: "compare_at", OldCents: before.CompareAtCents, NewCents: after.CompareAtCents})
+	}
+	saleChanged := !sameCents(before.SalePriceCents, after.SalePriceCents) ||
+		!sameTime(before.SaleStartsAt, after.SaleStartsAt) || !sameTime(before.SaleEndsAt, after.SaleEndsAt)
+	if saleChanged {
+		changes = append(changes, PriceHistory{
+			Field:    "sale_price",
+			OldCents: before.SalePriceCents,
+			NewCents: after.SalePriceCents,
+			StartsAt: after.SaleStartsAt,
+			EndsAt:   after.SaleEndsAt,
+		})
+	}
+
+	for i := range changes {
+		changes[i].ProductID = after.ID
+		changes[i].Source = source
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// applyDuePriceChanges aplică modificările programate ajunse la termen.
+// SKIP LOCKED permite rularea sigură pe mai multe instanțe ale serverului.
+func applyDuePriceChanges() error {
+	var due []ScheduledPriceChange
+	if err := DB.Where("applied_at IS NULL AND cancelled_at IS NULL AND effective_at <= ?", time.Now()).
+		Order("effective_at ASC, id ASC").Limit(100).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, change := range due {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var locked ScheduledPriceChange
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("id = ? AND applied_at IS NULL AND cancelled_at IS NULL", change.ID).
+				First(&locked).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil // aplicată deja de altă instanță
+				}
+				return err
+			}
+
+			var product Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, locked.ProductID).Error; err != nil {
+				return err
+			}
+			before := product
+
+			product.PriceCents = locked.PriceCents
+			if locked.ClearCompareAt {
+				product.CompareAtCents = nil
+			} else if locked.CompareAtCents != nil {
+				product.CompareAtCents = locked.CompareAtCents
+			}
+			if err := tx.Model(&product).Updates(map[string]interface{}{
+				"price_cents":      product.PriceCents,
+				"compare_at_cents": product.CompareAtCents,
+			}).Error; err != nil {
+				return err
+			}
+			if err := recordPriceChanges(tx, before, product, fmt.Sprintf("schedule:%d", locked.ID)); err != nil {
+				return err
+			}
+			return tx.Model(&locked).Update("applied_at", time.Now()).Error
+		})
+		if err != nil {
+			log.Printf("Eroare la aplicarea modificării de preț #%d: %v", change.ID, err)
+			continue
+		}
+		log.Printf("Aplicată modificarea de preț #%d pentru produsul %d", change.ID, change.ProductID)
+	}
+	return nil
+}
+
+// runPriceScheduler verifică periodic modificările de preț programate
+func runPriceScheduler() {
+	ticker := time.NewTicker(priceSchedulerInterval)
+	defer ticker.Stop()
+	for {
+		if err := applyDuePriceChanges(); err != nil {
+			log.Println("Eroare la verificarea modificărilor de preț programate:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// --- Admin ---
+
+func withScheduledPriceAmounts(c *ScheduledPriceChange) {
+	c.Price = float64(c.PriceCents) / 100
+	c.CompareAtPrice = centsToFloatPtr(c.CompareAtCents)
+}
+
+// getProductPriceHistory: GET /api/admin/products/{id}/price-history
+func getProductPriceHistory(w http.ResponseWriter, r *http.Request) {
+	var history []PriceHistory
+	if err := DB.Where("product_id = ?", mux.Vars(r)["id"]).
+		Order("created_at DESC, id DESC").Find(&history).Error; err != nil {
+		http.Error(w, "Eroare la preluarea istoricului de preț", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func getScheduledPriceChanges(w http.ResponseWriter, r *http.Request) {
+	var changes []ScheduledPriceChange
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	// Filtru opțional {"product_id": 3, "status": "pending"}
+	var filter struct {
+		ProductID uint   `json:"product_id"`
+		Status    string `json:"status"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+
+	q := DB.Model(&ScheduledPriceChange{})
+	if filter.ProductID != 0 {
+		q = q.Where("product_id = ?", filter.ProductID)
+	}
+	switch filter.Status {
+	case "pending":
+		q = q.Where("applied_at IS NULL AND cancelled_at IS NULL")
+	case "applied":
+		q = q.Where("applied_at IS NOT NULL")
+	case "cancelled":
+		q = q.Where("cancelled_at IS NOT NULL")
+	}
+
+	q.Count(&total)
+
+	if err := q.Order("effective_at DESC, id DESC").Offset(start).Limit(pageSize).Find(&changes).Error; err != nil {
+		http.Error(w, "Eroare la preluarea modificărilor de preț", http.StatusInternalServerError)
+		return
+	}
+	for i := range changes {
+		withScheduledPriceAmounts(&changes[i])
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("price-changes %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(changes)
+}
+
+func createScheduledPriceChange(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProductID      uint      `json:"product_id"`
+		EffectiveAt    time.Time `json:"effective_at"`
+		Price          float64   `json:"price"`
+		CompareAtPrice *float64  `json:"compare_at_price"`
+		ClearCompareAt bool      `json:"clear_compare_at"`
+		Note           string    `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+
+	if req.Price <= 0 {
+		http.Error(w, "Pretul trebuie sa fie mai mare decat 0", http.StatusBadRequest)
+		return
+	}
+	if !req.EffectiveAt.After(time.Now()) {
+		http.Error(w, "Data aplicării trebuie să fie în viitor", http.StatusBadRequest)
+		return
+	}
+	var product Product
+	if err := DB.First(&product, req.ProductID).Error; err != nil {
+		http.Error(w, "Produsul nu a fost găsit", http.StatusBadRequest)
+		return
+	}
+
+	change := ScheduledPriceChange{
+		ProductID:      product.ID,
+		EffectiveAt:    req.EffectiveAt,
+		PriceCents:     int64(math.Round(req.Price * 100)),
+		CompareAtCents: centsPtr(req.CompareAtPrice),
+		ClearCompareAt: req.ClearCompareAt,
+		Note:           req.Note,
+	}
+	if admin := currentAdmin(r); admin != nil {
+		change.CreatedByID = &admin.ID
+	}
+
+	if err := DB.Create(&change).Error; err != nil {
+		log.Println("Eroare la programarea modificării de preț:", err)
+		http.Error(w, "Eroare la salvare", http.StatusInternalServerError)
+		return
+	}
+	withScheduledPriceAmounts(&change)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+func getScheduledPriceChange(w http.ResponseWriter, r *http.Request) {
+	var change ScheduledPriceChange
+	if err := DB.First(&change, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Modificarea de preț nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	withScheduledPriceAmounts(&change)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+// cancelScheduledPriceChange: DELETE anulează doar modificările încă neaplicate
+func cancelScheduledPriceChange(w http.ResponseWriter, r *http.Request) {
+	var change ScheduledPriceChange
+	if err := DB.First(&change, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Modificarea de preț nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	res := DB.Model(&change).Where("applied_at IS NULL AND cancelled_at IS NULL").Update("cancelled_at", time.Now())
+	if res.Error != nil {
+		http.Error(w, "Eroare la anulare", http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.Error(w, "Modificarea a fost deja aplicată sau anulată", http.StatusConflict)
+		return
+	}
+	withScheduledPriceAmounts(&change)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
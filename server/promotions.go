@@ -0,0 +1,597 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PromoPercentage   = "percentage"
+	PromoFixed        = "fixed"
+	PromoFreeDelivery = "free_delivery"
+)
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// PromoError e motivul pentru care un cod nu poate fi aplicat, arătat clientului
+type PromoError struct {
+	Message string
+}
+
+func (e *PromoError) Error() string { return e.Message }
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromoResult e rezultatul aplicării unui cod pe liniile unei comenzi
+type PromoResult struct {
+	SubtotalCents int64   `json:"subtotal_cents"`
+	DiscountCents int64   `json:"discount_cents"`
+	TotalCents    int64   `json:"total_cents"`
+	FreeDelivery  bool    `json:"free_delivery"`
+	LineDiscounts []int64 `json:"line_discounts"`
+}
+
+// inScope: fără produse/categorii setate, promoția se aplică întregului coș
+func (p *Promotion) inScope(productID, categoryID uint) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if uint(id) == productID {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIDs {
+		if uint(id) == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePromotion verifică valabilitatea codului și calculează reducerea pe fiecare
+// linie. Limita per client se verifică după user_id sau, pentru vizitatori, după telefon.
+func evaluatePromotion(db *gorm.DB, p *Promotion, items []OrderItem, userID *uint, phone string, now time.Time) (*PromoResult, error) {
+	if !p.IsActive {
+		return nil, &PromoError{"Codul promoțional nu este activ"}
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return nil, &PromoError{"Codul promoțional nu este încă valabil"}
+	}
+	if p.EndsAt != nil && now.After(*p.EndsAt) {
+		return nil, &PromoError{"Codul promoțional a expirat"}
+	}
+	if p.UsageLimit > 0 && p.UsedCount >= p.UsageLimit {
+		return nil, &PromoError{"Codul promoțional a fost folosit de numărul maxim de ori"}
+	}
+
+	if p.PerUserLimit > 0 && (userID != nil || phone != "") {
+		q := db.Model(&PromotionRedemption{}).Where("promotion_id = ?", p.ID)
+		if userID != nil {
+			q = q.Where("user_id = ? OR phone = ?", *userID, phone)
+		} else {
+			q = q.Where("phone = ?", phone)
+		}
+		var used int64
+		if err := q.Count(&used).Error; err != nil {
+			return nil, err
+		}
+		if used >= int64(p.PerUserLimit) {
+			return nil, &PromoError{"Ai folosit deja acest cod promoțional"}
+		}
+	}
+
+	productIDs := make([]uint, 0, len(items))
+	for _, it := range items {
+		productIDs = append(productIDs, it.ProductID)
+	}
+	var products []Product
+	if err := db.Unscoped().Select("id", "category_id").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	categoryOf := make(map[uint]uint, len(products))
+	for _, pr := range products {
+		categoryOf[pr.ID] = pr.CategoryID
+	}
+
+	res := &PromoResult{LineDiscounts: make([]int64, len(items))}
+	var eligibleCents int64
+	eligible := make([]bool, len(items))
+	for i, it := range items {
+		line := it.PriceCents * int64(it.Quantity)
+		res.SubtotalCents += line
+		if p.inScope(it.ProductID, categoryOf[it.ProductID]) {
+			eligible[i] = true
+			eligibleCents += line
+		}
+	}
+
+	if res.SubtotalCents < p.MinOrderCents {
+		return nil, &PromoError{fmt.Sprintf("Comanda minimă pentru acest cod este %.2f MDL", float64(p.MinOrderCents)/100)}
+	}
+	if eligibleCents == 0 {
+		return nil, &PromoError{"Codul promoțional nu se aplică produselor din coș"}
+	}
+
+	switch p.Type {
+	case PromoPercentage:
+		for i, it := range items {
+			if eligible[i] {
+				res.LineDiscounts[i] = it.PriceCents * int64(it.Quantity) * int64(p.PercentOff) / 100
+			}
+		}
+	case PromoFixed:
+		// suma fixă se împarte proporțional pe liniile eligibile; restul din rotunjire
+		// merge pe ultima linie ca totalul să fie exact
+		amount := p.AmountOffCents
+		if amount > eligibleCents {
+			amount = eligibleCents
+		}
+		remaining, last := amount, -1
+		for i, it := range items {
+			if !eligible[i] {
+				continue
+			}
+			share := amount * it.PriceCents * int64(it.Quantity) / eligibleCents
+			res.LineDiscounts[i] = share
+			remaining -= share
+			last = i
+		}
+		if last >= 0 {
+			res.LineDiscounts[last] += remaining
+		}
+	case PromoFreeDelivery:
+		res.FreeDelivery = true
+	}
+
+	for _, d := range res.LineDiscounts {
+		res.DiscountCents += d
+	}
+	res.TotalCents = res.SubtotalCents - res.DiscountCents
+	return res, nil
+}
+
+// applyPromotion blochează rândul promoției (pentru limitele de utilizare) și
+// scrie reducerea pe comandă și pe linii. Se apelează în tranzacția comenzii.
+func applyPromotion(tx *gorm.DB, code string, order *Order) (*Promotion, error) {
+	var promo Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", normalizePromoCode(code)).First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &PromoError{"Cod promoțional invalid"}
+		}
+		return nil, err
+	}
+
+	res, err := evaluatePromotion(tx, &promo, order.Items, order.UserID, order.Phone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range order.Items {
+		order.Items[i].DiscountCents = res.LineDiscounts[i]
+	}
+	order.PromoCode = promo.Code
+	order.PromotionID = &promo.ID
+	order.SubtotalCents = res.SubtotalCents
+	order.DiscountCents = res.DiscountCents
+	order.TotalCents = res.TotalCents
+	order.FreeDelivery = res.FreeDelivery
+	order.Discounts = []OrderDiscount{{
+		PromotionID: &promo.ID,
+		Code:        promo.Code,
+		Type:        promo.Type,
+		Description: promo.Description,
+		AmountCents: res.DiscountCents,
+	}}
+	return &promo, nil
+}
+
+func redeemPromotion(tx *gorm.DB, promo *Promotion, order *Order) error {
+	if err := tx.Create(&PromotionRedemption{
+		PromotionID:   promo.ID,
+		OrderID:       order.ID,
+		UserID:        order.UserID,
+		Phone:         order.Phone,
+		DiscountCents: order.DiscountCents,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Promotion{}).Where("id = ?", promo.ID).
+		Update("used_count", gorm.Expr("used_count + 1")).Error
+}
+
+// releasePromotion eliberează utilizarea codului când comanda e anulată/returnată
+func releasePromotion(tx *gorm.DB, orderID uint) error {
+	var redemption PromotionRedemption
+	if err := tx.Where("order_id = ?", orderID).First(&redemption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Promotion{}).Where("id = ? AND used_count > 0", redemption.PromotionID).
+		Update("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+// currentCartLines întoarce coșul curent (din DB pentru utilizatori, din cookie pentru vizitatori)
+func currentCartLines(r *http.Request) ([]CartLineRequest, error) {
+	cart, err := loadCartLines(r)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]CartLineRequest, 0, len(cart))
+	for _, it := range cart {
+		lines = append(lines, CartLineRequest{ProductID: it.ProductID, VariantID: it.VariantID, Quantity: it.Quantity})
+	}
+	return lines, nil
+}
+
+// applyPromoPreview: POST /api/cart/apply-promo
+// Arată reducerea pentru coșul curent (sau pentru "items" trimise explicit) fără a folosi codul.
+func applyPromoPreview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code  string            `json:"code"`
+		Phone string            `json:"phone"`
+		Items []CartLineRequest `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "Date invalide")
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		httpError(w, http.StatusBadRequest, "Introdu un cod promoțional")
+		return
+	}
+
+	lines := req.Items
+	if len(lines) == 0 {
+		var err error
+		if lines, err = currentCartLines(r); err != nil {
+			httpError(w, http.StatusInternalServerError, "Eroare la citirea coșului")
+			return
+		}
+	}
+	if len(lines) == 0 {
+		httpError(w, http.StatusBadRequest, "Coșul este gol")
+		return
+	}
+
+	items, err := priceOrderItems(DB, lines)
+	if err != nil {
+		var itemErr *OrderItemError
+		if errors.As(err, &itemErr) {
+			httpError(w, itemErr.Status, itemErr.Message)
+			return
+		}
+		httpError(w, http.StatusInternalServerError, "Eroare la verificarea produselor")
+		return
+	}
+
+	var promo Promotion
+	if err := DB.Where("code = ?", normalizePromoCode(req.Code)).First(&promo).Error; err != nil {
+		httpError(w, http.StatusBadRequest, "Cod promoțional invalid")
+		return
+	}
+
+	var userID *uint
+	if id, ok := r.Context().Value(userIDKey).(uint); ok {
+		userID = &id
+	}
+	res, err := evaluatePromotion(DB, &promo, items, userID, strings.TrimSpace(req.Phone), time.Now())
+	if err != nil {
+		var promoErr *PromoError
+		if errors.As(err, &promoErr) {
+			httpError(w, http.StatusBadRequest, promoErr.Message)
+			return
+		}
+		log.Println("Eroare la evaluarea codului promoțional:", err)
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	type previewLine struct {
+		ProductID     uint  `json:"productId"`
+		VariantID     *uint `json:"variantId"`
+		Quantity      int   `json:"quantity"`
+		PriceCents    int64 `json:"price_cents"`
+		DiscountCents int64 `json:"discount_cents"`
+	}
+	preview := make([]previewLine, 0, len(items))
+	for i, it := range items {
+		preview = append(preview, previewLine{
+			ProductID:     it.ProductID,
+			VariantID:     it.VariantID,
+			Quantity:      it.Quantity,
+			PriceCents:    it.PriceCents,
+			DiscountCents: res.LineDiscounts[i],
+		})
+	}
+
+	okJSON(w, map[string]interface{}{
+		"code":           promo.Code,
+		"type":           promo.Type,
+		"description":    promo.Description,
+		"subtotal_cents": res.SubtotalCents,
+		"discount_cents": res.DiscountCents,
+		"total_cents":    res.TotalCents,
+		"subtotal":       float64(res.SubtotalCents) / 100,
+		"discount":       float64(res.DiscountCents) / 100,
+		"total":          float64(res.TotalCents) / 100,
+		"free_delivery":  res.FreeDelivery,
+		"items":          preview,
+	})
+}
+
+// --- Admin ---
+
+type PromotionRequest struct {
+	Code         string     `json:"code"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type"`
+	PercentOff   int        `json:"percent_off"`
+	AmountOff    float64    `json:"amount_off"`
+	MinOrder     float64    `json:"min_order"`
+	CategoryIDs  []int64    `json:"category_ids"`
+	ProductIDs   []int64    `json:"product_ids"`
+	UsageLimit   int        `json:"usage_limit"`
+	PerUserLimit int        `json:"per_user_limit"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	IsActive     *bool      `json:"is_active"`
+}
+
+func (req *PromotionRequest) validate() error {
+	req.Code = normalizePromoCode(req.Code)
+	if !promoCodePattern.MatchString(req.Code) {
+		return errors.New("Codul trebuie să aibă 3-32 caractere: litere, cifre, - sau _")
+	}
+	switch req.Type {
+	case PromoPercentage:
+		if req.PercentOff < 1 || req.PercentOff > 100 {
+			return errors.New("Procentul trebuie să fie între 1 și 100")
+		}
+	case PromoFixed:
+		if req.AmountOff <= 0 {
+			return errors.New("Suma reducerii trebuie să fie mai mare decât 0")
+		}
+	case PromoFreeDelivery:
+	default:
+		return errors.New("Tip de promoție invalid")
+	}
+	if req.MinOrder < 0 || req.UsageLimit < 0 || req.PerUserLimit < 0 {
+		return errors.New("Valorile nu pot fi negative")
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("Data de sfârșit trebuie să fie după data de început")
+	}
+	return nil
+}
+
+func (req *PromotionRequest) apply(p *Promotion) {
+	p.Code = req.Code
+	p.Description = req.Description
+	p.Type = req.Type
+	p.PercentOff = 0
+	p.AmountOffCents = 0
+	switch req.Type {
+	case PromoPercentage:
+		p.PercentOff = req.PercentOff
+	case PromoFixed:
+		p.AmountOffCents = int64(math.Round(req.AmountOff * 100))
+	}
+	p.MinOrderCents = int64(math.Round(req.MinOrder * 100))
+	p.CategoryIDs = pq.Int64Array(req.CategoryIDs)
+	p.ProductIDs = pq.Int64Array(req.ProductIDs)
+	p.UsageLimit = req.UsageLimit
+	p.PerUserLimit = req.PerUserLimit
+	p.StartsAt = req.StartsAt
+	p.EndsAt = req.EndsAt
+	if req.IsActive != nil {
+		p.IsActive = *req.IsActive
+	}
+}
+
+func withPromotionAmounts(p *Promotion) {
+	p.AmountOff = float64(p.AmountOffCents) / 100
+	p.MinOrder = float64(p.MinOrderCents) / 100
+}
+
+var promotionSortFields = map[string]string{
+	"id":         "id",
+	"code":       "code",
+	"type":       "type",
+	"used_count": "used_count",
+	"starts_at":  "starts_at",
+	"ends_at":    "ends_at",
+	"created_at": "created_at",
+	"is_active":  "is_active",
+}
+
+func getAdminPromotions(w http.ResponseWriter, r *http.Request) {
+	var promotions []Promotion
+	var total int64
+
+	query := r.URL.Query()
+
+	// Parse range [start, end]
+	rangeHeader := query.Get("range")
+	var start, end int
+	if rangeHeader != "" {
+		var rangeArr []int
+		if err := json.Unmarshal([]byte(rangeHeader), &rangeArr); err == nil && len(rangeArr) == 2 {
+			start, end = rangeArr[0], rangeArr[1]
+		}
+	}
+
+	// Default pagination
+	if end == 0 {
+		start, end = 0, 9
+	}
+	pageSize := end - start + 1
+
+	// Apply sorting
+	sortField := "id"
+	sortOrder := "DESC"
+	if sortHeader := query.Get("sort"); sortHeader != "" {
+		var sortArr []string
+		if err := json.Unmarshal([]byte(sortHeader), &sortArr); err == nil && len(sortArr) == 2 {
+			if f, ok := promotionSortFields[sortArr[0]]; ok {
+				sortField = f
+			}
+			if strings.EqualFold(sortArr[1], "ASC") {
+				sortOrder = "ASC"
+			}
+		}
+	}
+
+	// Filtru opțional {"q": "VARA", "is_active": true}
+	var filter struct {
+		Q        string `json:"q"`
+		IsActive *bool  `json:"is_active"`
+	}
+	if f := query.Get("filter"); f != "" {
+		json.Unmarshal([]byte(f), &filter)
+	}
+
+	q := DB.Model(&Promotion{})
+	if filter.Q != "" {
+		q = q.Where("code ILIKE ?", "%"+filter.Q+"%")
+	}
+	if filter.IsActive != nil {
+		q = q.Where("is_active = ?", *filter.IsActive)
+	}
+
+	q.Count(&total)
+
+	if err := q.Order(sortField + " " + sortOrder).Offset(start).Limit(pageSize).Find(&promotions).Error; err != nil {
+		http.Error(w, "Eroare la preluarea promoțiilor", http.StatusInternalServerError)
+		return
+	}
+	for i := range promotions {
+		withPromotionAmounts(&promotions[i])
+	}
+
+	// Set headers for React Admin
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range")
+	w.Header().Set("Content-Range", fmt.Sprintf("promotions %d-%d/%d", start, end, total))
+
+	json.NewEncoder(w).Encode(promotions)
+}
+
+func getAdminPromotion(w http.ResponseWriter, r *http.Request) {
+	var promo Promotion
+	if err := DB.First(&promo, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Promoția nu a fost găsită", http.StatusNotFound)
+		return
+	}
+	withPromotionAmounts(&promo)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promo)
+}
+
+func savePromotion(w http.ResponseWriter, promo *Promotion, create bool) {
+	var existing int64
+	DB.Model(&Promotion{}).Where("code = ? AND id <> ?", promo.Code, promo.ID).Count(&existing)
+	if existing > 0 {
+		http.Error(w, "Există deja o promoție cu acest cod", http.StatusConflict)
+		return
+	}
+
+	var err error
+	if create {
+		err = DB.Create(promo).Error
+	} else {
+		err = DB.Save(promo).Error
+	}
+	if err != nil {
+		log.Println("Eroare la salvarea promoției:", err)
+		http.Error(w, "Eroare la salvarea promoției", http.StatusInternalServerError)
+		return
+	}
+	withPromotionAmounts(promo)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promo)
+}
+
+func createAdminPromotion(w http.ResponseWriter, r *http.Request) {
+	var req PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	promo := Promotion{IsActive: true}
+	req.apply(&promo)
+	savePromotion(w, &promo, true)
+}
+
+func updateAdminPromotion(w http.ResponseWriter, r *http.Request) {
+	var promo Promotion
+	if err := DB.First(&promo, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Promoția nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	var req PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.apply(&promo)
+	savePromotion(w, &promo, false)
+}
+
+func deleteAdminPromotion(w http.ResponseWriter, r *http.Request) {
+	var promo Promotion
+	if err := DB.First(&promo, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Promoția nu a fost găsită", http.StatusNotFound)
+		return
+	}
+
+	if err := DB.Delete(&promo).Error; err != nil {
+		http.Error(w, "Eroare la ștergerea promoției", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promo)
+}
+
+// backfillOrderSubtotals completează subtotalul comenzilor create înainte de reduceri
+func backfillOrderSubtotals() {
+	if err := DB.Model(&Order{}).
+		Where("subtotal_cents = 0 AND discount_cents = 0 AND total_cents > 0").
+		Update("subtotal_cents", gorm.Expr("total_cents")).Error; err != nil {
+		log.Println("Eroare la completarea subtotalului comenzilor:", err)
+	}
+}
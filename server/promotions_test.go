@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestEvaluatePromotionLimits(t *testing.T) {
+	db := newTestDB(t, &Product{}, &PromotionRedemption{})
+	if err := db.Create(&[]Product{
+		{ID: 1, Name: "Canapea", CategoryID: 10, PriceCents: 100000},
+		{ID: 2, Name: "Fotoliu", CategoryID: 20, PriceCents: 50000},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	userID, otherUser := uint(7), uint(8)
+	if err := db.Create(&[]PromotionRedemption{
+		{PromotionID: 1, OrderID: 100, UserID: &userID, Phone: "069111111", DiscountCents: 100},
+		{PromotionID: 1, OrderID: 101, Phone: "069222222", DiscountCents: 100},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	items := []OrderItem{{ProductID: 1, Quantity: 1, PriceCents: 100000}}
+	base := Promotion{ID: 1, Code: "VARA10", Type: PromoPercentage, PercentOff: 10, IsActive: true}
+
+	tests := []struct {
+		name    string
+		edit    func(p *Promotion)
+		items   []OrderItem
+		userID  *uint
+		phone   string
+		wantErr string
+	}{
+		{name: "valid", edit: func(p *Promotion) {}},
+		{name: "inactiv", edit: func(p *Promotion) { p.IsActive = false }, wantErr: "Codul promoțional nu este activ"},
+		{name: "neînceput", edit: func(p *Promotion) { p.StartsAt = &future }, wantErr: "Codul promoțional nu este încă valabil"},
+		{name: "expirat", edit: func(p *Promotion) { p.EndsAt = &past }, wantErr: "Codul promoțional a expirat"},
+		{name: "în interval", edit: func(p *Promotion) { p.StartsAt, p.EndsAt = &past, &future }},
+		{
+			name:    "limită totală atinsă",
+			edit:    func(p *Promotion) { p.UsageLimit, p.UsedCount = 5, 5 },
+			wantErr: "Codul promoțional a fost folosit de numărul maxim de ori",
+		},
+		{name: "sub limita totală", edit: func(p *Promotion) { p.UsageLimit, p.UsedCount = 5, 4 }},
+		{
+			name:    "limită per client după cont",
+			edit:    func(p *Promotion) { p.PerUserLimit = 1 },
+			userID:  &userID,
+			phone:   "060000000",
+			wantErr: "Ai folosit deja acest cod promoțional",
+		},
+		{
+			name:    "limită per client după telefon, alt cont",
+			edit:    func(p *Promotion) { p.PerUserLimit = 1 },
+			userID:  &otherUser,
+			phone:   "069222222",
+			wantErr: "Ai folosit deja acest cod promoțional",
+		},
+		{
+			name:    "limită per client pentru oaspete",
+			edit:    func(p *Promotion) { p.PerUserLimit = 1 },
+			phone:   "069111111",
+			wantErr: "Ai folosit deja acest cod promoțional",
+		},
+		{name: "limită per client mai mare", edit: func(p *Promotion) { p.PerUserLimit = 2 }, userID: &userID},
+		{name: "client nou", edit: func(p *Promotion) { p.PerUserLimit = 1 }, phone: "069333333"},
+		{
+			name:    "sub comanda minimă",
+			edit:    func(p *Promotion) { p.MinOrderCents = 100001 },
+			wantErr: "Comanda minimă pentru acest cod este 1000.01 MDL",
+		},
+		{name: "exact comanda minimă", edit: func(p *Promotion) { p.MinOrderCents = 100000 }},
+		{
+			name:    "fără produse eligibile",
+			edit:    func(p *Promotion) { p.CategoryIDs = pq.Int64Array{20} },
+			wantErr: "Codul promoțional nu se aplică produselor din coș",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := base
+			tt.edit(&p)
+			lines := tt.items
+			if lines == nil {
+				lines = items
+			}
+			res, err := evaluatePromotion(db, &p, lines, tt.userID, tt.phone, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("eroare neașteptată: %v", err)
+				}
+				if res.DiscountCents != 10000 {
+					t.Errorf("reducere %d, vrem 10000", res.DiscountCents)
+				}
+				return
+			}
+			var promoErr *PromoError
+			if !errors.As(err, &promoErr) || promoErr.Message != tt.wantErr {
+				t.Errorf("eroare %v, vrem %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluatePromotionDiscounts(t *testing.T) {
+	db := newTestDB(t, &Product{}, &PromotionRedemption{})
+	if err := db.Create(&[]Product{
+		{ID: 1, Name: "Canapea", CategoryID: 10, PriceCents: 33333},
+		{ID: 2, Name: "Fotoliu", CategoryID: 20, PriceCents: 10000},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	items := []OrderItem{
+		{ProductID: 1, Quantity: 2, PriceCents: 33333},
+		{ProductID: 2, Quantity: 1, PriceCents: 10000},
+	}
+
+	tests := []struct {
+		name          string
+		promo         Promotion
+		wantLines     []int64
+		wantTotal     int64
+		wantFreeDeliv bool
+	}{
+		{
+			name:      "procent pe tot coșul",
+			promo:     Promotion{Type: PromoPercentage, PercentOff: 15},
+			wantLines: []int64{9999, 1500},
+			wantTotal: 76666 - 11499,
+		},
+		{
+			name:      "procent doar pe categorie",
+			promo:     Promotion{Type: PromoPercentage, PercentOff: 50, CategoryIDs: pq.Int64Array{20}},
+			wantLines: []int64{0, 5000},
+			wantTotal: 76666 - 5000,
+		},
+		{
+			// 10000 împărțit proporțional: 66666/76666 și 10000/76666, restul pe ultima linie
+			name:      "sumă fixă împărțită pe linii",
+			promo:     Promotion{Type: PromoFixed, AmountOffCents: 10000},
+			wantLines: []int64{8695, 1305},
+			wantTotal: 66666,
+		},
+		{
+			name:      "sumă fixă mai mare decât produsele eligibile",
+			promo:     Promotion{Type: PromoFixed, AmountOffCents: 50000, ProductIDs: pq.Int64Array{2}},
+			wantLines: []int64{0, 10000},
+			wantTotal: 66666,
+		},
+		{
+			name:          "livrare gratuită",
+			promo:         Promotion{Type: PromoFreeDelivery},
+			wantLines:     []int64{0, 0},
+			wantTotal:     76666,
+			wantFreeDeliv: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.promo
+			p.IsActive = true
+			res, err := evaluatePromotion(db, &p, items, nil, "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sum int64
+			for i, want := range tt.wantLines {
+				if res.LineDiscounts[i] != want {
+					t.Errorf("linia %d: reducere %d, vrem %d", i, res.LineDiscounts[i], want)
+				}
+				sum += res.LineDiscounts[i]
+			}
+			if res.SubtotalCents != 76666 || res.DiscountCents != sum || res.TotalCents != tt.wantTotal {
+				t.Errorf("subtotal %d, reducere %d, total %d; vrem 76666, %d, %d",
+					res.SubtotalCents, res.DiscountCents, res.TotalCents, sum, tt.wantTotal)
+			}
+			if res.FreeDelivery != tt.wantFreeDeliv {
+				t.Errorf("livrare gratuită %v, vrem %v", res.FreeDelivery, tt.wantFreeDeliv)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 24
+	maxPageSize     = 100
+)
+
+// Intervalele de preț (în bani) pentru fațetele de căutare; Max 0 = fără limită
+var priceFacetRanges = []struct {
+	Min, Max int64
+}{
+	{0, 500000},
+	{500000, 1000000},
+	{1000000, 2000000},
+	{2000000, 0},
+}
+
+// searchDocument combină vectorul precalculat al produsului cu numele categoriei,
+// astfel încât cuvintele unei căutări pot fi găsite în câmpuri diferite.
+const searchDocument = "(p.search_vector || setweight(to_tsvector('ro_unaccent', coalesce(c.name, '')), 'C'))"
+
+// searchQueryExpr caută în ambele configurații (română și rusă)
+const searchQueryExpr = "(to_tsquery('ro_unaccent', ?) || to_tsquery('ru_unaccent', ?))"
+
+// ensureSearchIndex creează configurațiile full-text fără diacritice, coloana
+// generată search_vector și indexul GIN. Se poate rula la fiecare pornire.
+func ensureSearchIndex() error {
+	stmts := []string{
+		`CREATE EXTENSION IF NOT EXISTS unaccent`,
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'ro_unaccent') THEN
+				CREATE TEXT SEARCH CONFIGURATION ro_unaccent (COPY = romanian);
+				ALTER TEXT SEARCH CONFIGURATION ro_unaccent
+					ALTER MAPPING FOR hword, hword_part, word WITH unaccent, romanian_stem;
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'ru_unaccent') THEN
+				CREATE TEXT SEARCH CONFIGURATION ru_unaccent (COPY = russian);
+				ALTER TEXT SEARCH CONFIGURATION ru_unaccent
+					ALTER MAPPING FOR hword, hword_part, word WITH unaccent, russian_stem;
+			END IF;
+		END $$`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('ro_unaccent', coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('ru_unaccent', coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('ro_unaccent', coalesce(description, '')), 'B') ||
+				setweight(to_tsvector('ru_unaccent', coalesce(description, '')), 'B') ||
+				setweight(to_tsvector('ro_unaccent', coalesce(dimensions, '')), 'D')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+	}
+
+	for _, stmt := range stmts {
+		if err := DB.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildSearchQuery transformă textul utilizatorului într-un tsquery de forma
+// "canapea:* & extensibila:*". Păstrăm doar litere și cifre, deci rezultatul
+// nu poate conține operatori tsquery trimiși de client.
+func buildSearchQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 8 {
+		words = words[:8]
+	}
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, strings.ToLower(w)+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// pageParams citește ?page=1&per_page=24 (page pornește de la 1)
+func pageParams(r *http.Request) (page, perPage int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ = strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = defaultPageSize
+	}
+	if perPage > maxPageSize {
+		perPage = maxPageSize
+	}
+	return page, perPage
+}
+
+// priceParam citește un preț în lei din query și îl întoarce în bani
+func priceParam(r *http.Request, name string) (int64, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	return int64(f*100 + 0.5), true
+}
+
+type CategoryFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type PriceFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+type AvailabilityFacet struct {
+	Available   int64 `json:"available"`
+	Unavailable int64 `json:"unavailable"`
+}
+
+type SearchFacets struct {
+	Categories   []CategoryFacet   `json:"categories"`
+	PriceRanges  []PriceFacet      `json:"price_ranges"`
+	Availability AvailabilityFacet `json:"availability"`
+}
+
+type SearchResponse struct {
+	Query   string       `json:"query"`
+	Items   []Product    `json:"items"`
+	Total   int64        `json:"total"`
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+	Facets  SearchFacets `json:"facets"`
+}
+
+// SearchProducts: GET /api/search?query=...&page=1&per_page=24
+// Filtre opționale: category_id, min_price, max_price (lei), available=true|false.
+// Fațetele se calculează pe toate rezultatele căutării, înainte de filtre.
+func SearchProducts(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("query"))
+	tsq := buildSearchQuery(q)
+	page, perPage := pageParams(r)
+
+	matched := func() *gorm.DB {
+		db := DB.Table("products p").
+			Joins("LEFT JOIN categories c ON c.id = p.category_id").
+			Where("p.is_active = ? AND p.deleted_at IS NULL", true)
+		if tsq != "" {
+			db = db.Where(searchDocument+" @@ "+searchQueryExpr, tsq, tsq)
+		}
+		return db
+	}
+
+	filtered := func() *gorm.DB {
+		db := matched()
+		if id, err := strconv.Atoi(r.URL.Query().Get("category_id")); err == nil && id > 0 {
+			db = db.Where("p.category_id = ?", id)
+		}
+		if v, ok := priceParam(r, "min_price"); ok {
+			db = db.Where(effectivePriceSQL("p")+" >= ?", v)
+		}
+		if v, ok := priceParam(r, "max_price"); ok {
+			db = db.Where(effectivePriceSQL("p")+" <= ?", v)
+		}
+		if v, err := strconv.ParseBool(r.URL.Query().Get("available")); err == nil {
+			db = db.Where("p.is_available = ?", v)
+		}
+		return db
+	}
+
+	resp := SearchResponse{Query: q, Page: page, PerPage: perPage, Items: []Product{}}
+
+	if err := filtered().Count(&resp.Total).Error; err != nil {
+		log.Println("Eroare la numărarea rezultatelor căutării:", err)
+		http.Error(w, "Eroare la căutare", http.StatusInternalServerError)
+		return
+	}
+
+	var hits []struct {
+		ID   uint
+		Rank float64
+	}
+	results := filtered()
+	if tsq != "" {
+		results = results.
+			Select("p.id, ts_rank_cd("+searchDocument+", "+searchQueryExpr+") AS rank", tsq, tsq).
+			Order("rank DESC")
+	} else {
+		results = results.Select("p.id, 0 AS rank").Order("p.created_at DESC")
+	}
+	if err := results.Order("p.id").Offset((page - 1) * perPage).Limit(perPage).Scan(&hits).Error; err != nil {
+		log.Println("Eroare la căutare:", err)
+		http.Error(w, "Eroare la căutare", http.StatusInternalServerError)
+		return
+	}
+
+	if len(hits) > 0 {
+		ids := make([]uint, len(hits))
+		for i, h := range hits {
+			ids[i] = h.ID
+		}
+		var products []Product
+		if err := DB.Preload("Category").Where("id IN ?", ids).Find(&products).Error; err != nil {
+			log.Println("Eroare la încărcarea produselor găsite:", err)
+			http.Error(w, "Eroare la căutare", http.StatusInternalServerError)
+			return
+		}
+		byID := make(map[uint]Product, len(products))
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+		for _, id := range ids {
+			if p, ok := byID[id]; ok {
+				resolveProductImages(&p)
+				withEffectivePrice(&p)
+				resp.Items = append(resp.Items, p)
+			}
+		}
+	}
+
+	facets, err := searchFacets(matched)
+	if err != nil {
+		log.Println("Eroare la calcularea fațetelor:", err)
+		http.Error(w, "Eroare la căutare", http.StatusInternalServerError)
+		return
+	}
+	resp.Facets = facets
+
+	okJSON(w, resp)
+}
+
+func searchFacets(matched func() *gorm.DB) (SearchFacets, error) {
+	facets := SearchFacets{Categories: []CategoryFacet{}, PriceRanges: []PriceFacet{}}
+
+	if err := matched().
+		Select("c.id, c.name, COUNT(*) AS count").
+		Where("c.id IS NOT NULL").
+		Group("c.id, c.name").
+		Order("count DESC, c.name").
+		Scan(&facets.Categories).Error; err != nil {
+		return facets, err
+	}
+
+	// o singură interogare cu câte un COUNT FILTER pentru fiecare interval
+	cols := []string{
+		"COUNT(*) FILTER (WHERE p.is_available) AS available",
+		"COUNT(*) FILTER (WHERE NOT p.is_available) AS unavailable",
+	}
+	price := effectivePriceSQL("p")
+	for i, pr := range priceFacetRanges {
+		cond := fmt.Sprintf("%s >= %d", price, pr.Min)
+		if pr.Max > 0 {
+			cond += fmt.Sprintf(" AND %s < %d", price, pr.Max)
+		}
+		cols = append(cols, fmt.Sprintf("COUNT(*) FILTER (WHERE %s) AS range_%d", cond, i))
+	}
+
+	row := map[string]interface{}{}
+	if err := matched().Select(strings.Join(cols, ", ")).Take(&row).Error; err != nil {
+		return facets, err
+	}
+
+	facets.Availability.Available = toInt64(row["available"])
+	facets.Availability.Unavailable = toInt64(row["unavailable"])
+	for i, pr := range priceFacetRanges {
+		f := PriceFacet{Min: float64(pr.Min) / 100, Count: toInt64(row[fmt.Sprintf("range_%d", i)])}
+		if pr.Max > 0 {
+			max := float64(pr.Max) / 100
+			f.Max = &max
+		}
+		facets.PriceRanges = append(facets.PriceRanges, f)
+	}
+	return facets, nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	}
+	return 0
+}
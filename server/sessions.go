@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	// cereri paralele pot trimite același refresh token imediat după rotire
+	refreshReuseGrace = 30 * time.Second
+	lastSeenInterval  = time.Minute
+
+	refreshCookieName = "refreshToken"
+)
+
+const sessionIDKey contextKey = "sessionID"
+
+var errSessionInvalid = errors.New("session invalid")
+
+// trustedProxies sunt adresele (IP sau CIDR din TRUSTED_PROXIES) ale proxy-urilor
+// din fața serverului. Doar de la ele acceptăm X-Forwarded-For.
+var trustedProxies []*net.IPNet
+
+// InitTrustedProxies citește TRUSTED_PROXIES, ex. "127.0.0.1,10.0.0.0/8"
+func InitTrustedProxies() {
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Fatalf("TRUSTED_PROXIES conține o adresă invalidă: %s", entry)
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP întoarce adresa clientului. X-Forwarded-For e citit doar când cererea
+// vine de la un proxy de încredere, de la dreapta la stânga, până la prima adresă
+// care nu e un proxy de-al nostru; valorile din stânga pot fi scrise de oricine.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(hop) == nil {
+			break // valoare stricată: rămânem la ultimul proxy cunoscut
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// deviceFromUserAgent face o descriere scurtă, ex. "Chrome pe Android"
+func deviceFromUserAgent(ua string) string {
+	browser := "Browser necunoscut"
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+
+	platform := "sistem necunoscut"
+	switch {
+	case strings.Contains(ua, "Android"):
+		platform = "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		platform = "iOS"
+	case strings.Contains(ua, "Windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(ua, "Linux"):
+		platform = "Linux"
+	}
+
+	return fmt.Sprintf("%s pe %s", browser, platform)
+}
+
+// --- JWT ---
+func issueAccessToken(userID uint, email string, sessionID uint) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   userID,
+		"email": email,
+		"sid":   sessionID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(accessTokenTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		panic("JWT_SECRET not set")
+	}
+	return token.SignedString([]byte(secret))
+}
+
+func setAuthCookies(w http.ResponseWriter, accessToken, refreshToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "authToken",
+		Value:    accessToken,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		MaxAge:   int(accessTokenTTL.Seconds()),
+	})
+	if refreshToken != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     refreshCookieName,
+			Value:    refreshToken,
+			Path:     "/api",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+			MaxAge:   int(refreshTokenTTL.Seconds()),
+		})
+	}
+}
+
+// startSession creează o sesiune nouă pentru dispozitivul curent și setează cookie-urile
+func startSession(w http.ResponseWriter, r *http.Request, user User) error {
+	refreshToken, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	ua := r.UserAgent()
+	session := Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		RotatedAt:        now,
+		Device:           deviceFromUserAgent(ua),
+		IP:               clientIP(r),
+		UserAgent:        ua,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(refreshTokenTTL),
+	}
+	if err := DB.Create(&session).Error; err != nil {
+		return err
+	}
+
+	accessToken, err := issueAccessToken(user.ID, user.Email, session.ID)
+	if err != nil {
+		return err
+	}
+	setAuthCookies(w, accessToken, refreshToken)
+	return nil
+}
+
+// activeSession întoarce sesiunea dacă nu a fost revocată și nu a expirat
+func activeSession(r *http.Request, sessionID, userID uint) (*Session, error) {
+	var session Session
+	err := DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, errSessionInvalid
+	}
+
+	if time.Since(session.LastSeenAt) > lastSeenInterval {
+		DB.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip":           clientIP(r),
+		})
+	}
+	return &session, nil
+}
+
+// rotateRefreshToken schimbă refresh tokenul din cookie cu unul nou și emite un access token.
+// Un token deja rotit, prezentat după perioada de grație, revocă sesiunea (posibil furt).
+func rotateRefreshToken(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(refreshCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, errSessionInvalid
+	}
+	hash := hashToken(cookie.Value)
+	now := time.Now()
+
+	var session Session
+	var newRefresh string
+	reused := false
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("refresh_token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hash, now).
+			First(&session).Error
+		if err == nil {
+			newRefresh, err = generateToken()
+			if err != nil {
+				return err
+			}
+			res := tx.Model(&Session{}).
+				Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+				Updates(map[string]interface{}{
+					"refresh_token_hash":  hashToken(newRefresh),
+					"previous_token_hash": hash,
+					"rotated_at":          now,
+					"last_seen_at":        now,
+					"ip":                  clientIP(r),
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errSessionInvalid
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&session).Error; err != nil {
+			return errSessionInvalid
+		}
+		if now.Sub(session.RotatedAt) <= refreshReuseGrace {
+			// cerere paralelă: noul refresh token a plecat deja în alt răspuns
+			newRefresh = ""
+			return nil
+		}
+		log.Printf("Refresh token reutilizat pentru sesiunea %d, revoc sesiunea", session.ID)
+		// revocarea trebuie să rămână scrisă, deci tranzacția se încheie fără eroare
+		reused = true
+		return tx.Model(&session).Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, errSessionInvalid
+	}
+
+	var user User
+	if err := DB.Select("id", "email").First(&user, session.UserID).Error; err != nil {
+		return nil, errSessionInvalid
+	}
+
+	accessToken, err := issueAccessToken(user.ID, user.Email, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	setAuthCookies(w, accessToken, newRefresh)
+	return &session, nil
+}
+
+func revokeSession(db *gorm.DB, sessionID uint) error {
+	return db.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeAllSessions închide toate sesiunile utilizatorului (după schimbarea parolei)
+func revokeAllSessions(db *gorm.DB, userID uint) error {
+	return db.Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// --- Handlers ---
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if _, err := rotateRefreshToken(w, r); err != nil {
+		clearAuthCookie(w)
+		httpError(w, http.StatusUnauthorized, "Sesiune expirată")
+		return
+	}
+	okJSON(w, map[string]string{"message": "Token reîmprospătat"})
+}
+
+func handleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(uint)
+	currentID, _ := r.Context().Value(sessionIDKey).(uint)
+
+	var sessions []Session
+	if err := DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+
+	type sessionResp struct {
+		Session
+		Current bool `json:"current"`
+	}
+	result := make([]sessionResp, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, sessionResp{Session: s, Current: s.ID == currentID})
+	}
+	okJSON(w, result)
+}
+
+func handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(uint)
+	currentID, _ := r.Context().Value(sessionIDKey).(uint)
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "ID invalid")
+		return
+	}
+
+	res := DB.Model(&Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		httpError(w, http.StatusInternalServerError, "Eroare server")
+		return
+	}
+	if res.RowsAffected == 0 {
+		httpError(w, http.StatusNotFound, "Sesiunea nu a fost găsită")
+		return
+	}
+
+	if uint(id) == currentID {
+		clearAuthCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withSession pune în context utilizatorul și sesiunea autentificată
+func withSession(r *http.Request, userID, sessionID uint) *http.Request {
+	ctx := context.WithValue(r.Context(), userIDKey, userID)
+	ctx = context.WithValue(ctx, sessionIDKey, sessionID)
+	return r.WithContext(ctx)
+}
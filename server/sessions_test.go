@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func refreshRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", nil)
+	r.AddCookie(&http.Cookie{Name: refreshCookieName, Value: token})
+	return r
+}
+
+func refreshCookie(w *httptest.ResponseRecorder) string {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == refreshCookieName {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		rotatedAt time.Duration // cât timp a trecut de la rotirea tokenului vechi
+		wantErr   bool
+		revoked   bool
+	}{
+		{name: "token vechi în perioada de grație", rotatedAt: refreshReuseGrace / 2},
+		{name: "token vechi reutilizat după grație", rotatedAt: 2 * refreshReuseGrace, wantErr: true, revoked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &User{}, &Session{})
+			if err := db.Create(&User{ID: 1, Email: "ion@example.com"}).Error; err != nil {
+				t.Fatal(err)
+			}
+			session := Session{UserID: 1, RefreshTokenHash: hashToken("primul"), ExpiresAt: time.Now().Add(time.Hour)}
+			if err := db.Create(&session).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// prima rotire: tokenul „primul” devine cel anterior
+			w := httptest.NewRecorder()
+			if _, err := rotateRefreshToken(w, refreshRequest("primul")); err != nil {
+				t.Fatal(err)
+			}
+			current := refreshCookie(w)
+			if current == "" || current == "primul" {
+				t.Fatalf("refresh token nou lipsă: %q", current)
+			}
+			db.Model(&session).Update("rotated_at", time.Now().Add(-tt.rotatedAt))
+
+			_, err := rotateRefreshToken(httptest.NewRecorder(), refreshRequest("primul"))
+			if tt.wantErr != errors.Is(err, errSessionInvalid) {
+				t.Fatalf("eroare %v, vrem sesiune invalidă: %v", err, tt.wantErr)
+			}
+
+			db.First(&session, session.ID)
+			if (session.RevokedAt != nil) != tt.revoked {
+				t.Fatalf("sesiune revocată: %v, vrem %v", session.RevokedAt != nil, tt.revoked)
+			}
+			// după revocare nici tokenul obținut de cel care a rotit primul nu mai merge
+			_, err = rotateRefreshToken(httptest.NewRecorder(), refreshRequest(current))
+			if tt.revoked != errors.Is(err, errSessionInvalid) {
+				t.Errorf("tokenul curent: eroare %v, vrem respins: %v", err, tt.revoked)
+			}
+		})
+	}
+}
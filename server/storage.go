@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	storage_go "github.com/supabase-community/storage-go"
+)
+
+// Storage abstractizează locul în care păstrăm imaginile produselor.
+// Cheile sunt căi relative, ex. "products/1700000000_canapea.jpg".
+type Storage interface {
+	Put(key string, r io.Reader, contentType string) error
+	Delete(key string) error
+	PublicURL(key string) string
+	List(prefix string) ([]string, error)
+	// Get citește fișierul direct din stocare, fără să treacă prin URL-ul public
+	Get(key string) ([]byte, error)
+}
+
+var Store Storage
+
+// InitStorage alege driverul după STORAGE_DRIVER (local sau supabase).
+// Fără configurare explicită folosim Supabase doar dacă e configurat.
+func InitStorage() {
+	driver := getEnv("STORAGE_DRIVER", "")
+	if driver == "" {
+		driver = "local"
+		if os.Getenv("SUPABASE_URL") != "" && os.Getenv("SUPABASE_SERVICE_KEY") != "" {
+			driver = "supabase"
+		}
+	}
+
+	switch driver {
+	case "supabase":
+		InitSupabase()
+		Store = &supabaseStorage{bucket: getEnv("SUPABASE_BUCKET", "products")}
+	case "local":
+		Store = &localStorage{
+			dir:     getEnv("LOCAL_STORAGE_DIR", "./uploads"),
+			baseURL: strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:8080"), "/") + "/uploads",
+		}
+	default:
+		log.Fatalf("STORAGE_DRIVER necunoscut: %s", driver)
+	}
+
+	log.Printf("Stocare imagini: %s", driver)
+}
+
+// --- Supabase ---
+type supabaseStorage struct {
+	bucket string
+}
+
+func (s *supabaseStorage) Put(key string, r io.Reader, contentType string) error {
+	upsert := true
+	_, err := Supabase.Storage.UploadFile(s.bucket, key, r, storage_go.FileOptions{
+		ContentType: &contentType,
+		Upsert:      &upsert,
+	})
+	return err
+}
+
+func (s *supabaseStorage) Delete(key string) error {
+	_, err := Supabase.Storage.RemoveFile(s.bucket, []string{key})
+	return err
+}
+
+func (s *supabaseStorage) PublicURL(key string) string {
+	return Supabase.Storage.GetPublicUrl(s.bucket, key).SignedURL
+}
+
+func (s *supabaseStorage) Get(key string) ([]byte, error) {
+	return Supabase.Storage.DownloadFile(s.bucket, key)
+}
+
+func (s *supabaseStorage) List(prefix string) ([]string, error) {
+	files, err := Supabase.Storage.ListFiles(s.bucket, prefix, storage_go.FileSearchOptions{Limit: 1000})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(files))
+	for _, f := range files {
+		keys = append(keys, path.Join(prefix, f.Name))
+	}
+	return keys, nil
+}
+
+// --- Disc local, servit de server sub /uploads/ ---
+type localStorage struct {
+	dir     string
+	baseURL string
+}
+
+// resolve împiedică ieșirea din directorul de stocare prin chei de tip "../"
+func (s *localStorage) resolve(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("cheie invalidă: %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *localStorage) Put(key string, r io.Reader, contentType string) error {
+	p, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *localStorage) Delete(key string) error {
+	p, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStorage) PublicURL(key string) string {
+	return s.baseURL + path.Clean("/"+key)
+}
+
+func (s *localStorage) Get(key string) ([]byte, error) {
+	p, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxImageUpload+1))
+}
+
+func (s *localStorage) List(prefix string) ([]string, error) {
+	p, err := s.resolve(prefix)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			keys = append(keys, path.Join(prefix, e.Name()))
+		}
+	}
+	return keys, nil
+}
+
+// resolveImageURL transformă căile vechi salvate fără domeniu (ex. "coltar.jpg"
+// sau "/uploads/products/coltar.jpg") în URL-uri publice ale stocării curente.
+func resolveImageURL(u string) string {
+	if u == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(u, "/"), "uploads/")
+	if !strings.Contains(key, "/") {
+		key = "products/" + key
+	}
+	return Store.PublicURL(key)
+}
+
+// storageKey întoarce cheia pentru un URL servit de stocarea curentă. URL-urile
+// din alte surse nu au cheie, ca reprocesarea să nu poată fi folosită pentru a
+// face serverul să descarce adrese arbitrare.
+func storageKey(u string) (string, bool) {
+	const probe = "products/probe"
+	base := strings.TrimSuffix(Store.PublicURL(probe), probe)
+	resolved := resolveImageURL(u)
+	if base == "" || !strings.HasPrefix(resolved, base) {
+		return "", false
+	}
+	key := strings.TrimPrefix(resolved, base)
+	if key == "" || strings.ContainsAny(key, "?#") || path.Clean("/"+key) != "/"+key {
+		return "", false
+	}
+	return key, true
+}
+
+func resolveImageURLs(urls []string) []string {
+	out := make([]string, len(urls))
+	for i, u := range urls {
+		out[i] = resolveImageURL(u)
+	}
+	return out
+}
+
+// resolveProductImages se aplică produselor trimise direct ca JSON din rutele publice
+func resolveProductImages(p *Product) {
+	p.ImageURLs = resolveImageURLs(p.ImageURLs)
+	p.Images = imageSetsFromURLs(p.ImageURLs)
+	for i := range p.Variants {
+		p.Variants[i].ImageURLs = resolveImageURLs(p.Variants[i].ImageURLs)
+		p.Variants[i].Images = imageSetsFromURLs(p.Variants[i].ImageURLs)
+	}
+}
+
+// migrateLocalImagesToStorage urcă imaginile vechi din ./uploads/products în stocarea
+// configurată și înlocuiește în produse căile relative cu URL-urile publice.
+func migrateLocalImagesToStorage() error {
+	if local, ok := Store.(*localStorage); ok && filepath.Clean(local.dir) == filepath.Clean("./uploads") {
+		return nil // imaginile sunt deja acolo unde le servim
+	}
+
+	dir := "./uploads/products"
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err // Dacă folderul nu există local, nu avem ce migra
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+
+		key := "products/" + f.Name()
+		contentType := mime.TypeByExtension(filepath.Ext(f.Name()))
+		if contentType == "" {
+			contentType = "image/jpeg"
+		}
+		err = Store.Put(key, file, contentType)
+		file.Close()
+
+		if err != nil {
+			log.Println("Eroare upload la migrare:", f.Name(), err)
+			continue
+		}
+
+		newPublicURL := Store.PublicURL(key)
+
+		// Căutăm produsele unde image_urls conține numele fișierului vechi
+		var products []Product
+		DB.Where("image_urls::text LIKE ?", "%"+f.Name()+"%").Find(&products)
+
+		for _, p := range products {
+			changed := false
+			for i, oldUrl := range p.ImageURLs {
+				// Dacă URL-ul vechi conține numele fișierului și NU este deja un URL complet
+				if strings.Contains(oldUrl, f.Name()) && !strings.HasPrefix(oldUrl, "http") {
+					p.ImageURLs[i] = newPublicURL
+					changed = true
+				}
+			}
+			if changed {
+				DB.Model(&p).Update("image_urls", p.ImageURLs)
+				log.Printf("Migrat cu succes imaginea pentru produsul: %s\n", p.Name)
+			}
+		}
+	}
+	return nil
+}
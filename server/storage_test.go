@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withLocalStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous := Store
+	Store = &localStorage{dir: dir, baseURL: "http://localhost:8080/uploads"}
+	t.Cleanup(func() { Store = previous })
+	return dir
+}
+
+func TestStorageKey(t *testing.T) {
+	withLocalStore(t)
+	tests := []struct {
+		url  string
+		key  string
+		owns bool
+	}{
+		{"http://localhost:8080/uploads/products/canapea.jpg", "products/canapea.jpg", true},
+		{"/uploads/products/canapea.jpg", "products/canapea.jpg", true},
+		{"canapea.jpg", "products/canapea.jpg", true},
+		{"http://169.254.169.254/latest/meta-data/", "", false},
+		{"http://localhost:8080/admin/products", "", false},
+		{"http://localhost:8080/uploads/../.env", "", false},
+		{"http://localhost:8080/uploads/products/canapea.jpg?x=1", "", false},
+		{"http://localhost:8080/uploads-vechi/canapea.jpg", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			key, ok := storageKey(tt.url)
+			if ok != tt.owns || key != tt.key {
+				t.Errorf("storageKey = %q, %v; vrem %q, %v", key, ok, tt.key, tt.owns)
+			}
+		})
+	}
+}
+
+func TestFetchImageReadsFromStore(t *testing.T) {
+	dir := withLocalStore(t)
+	if err := os.MkdirAll(filepath.Join(dir, "products"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "products", "canapea.jpg"), []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fetchImage("http://localhost:8080/uploads/products/canapea.jpg")
+	if err != nil || string(data) != "jpeg" {
+		t.Fatalf("fetchImage = %q, %v", data, err)
+	}
+	if _, err := fetchImage("http://127.0.0.1:6379/"); !errors.Is(err, errImageNotStored) {
+		t.Errorf("URL străin: eroare %v, vrem %v", err, errImageNotStored)
+	}
+}
@@ -1,35 +1,44 @@
 package main
 
 import (
-	"net/smtp"
-	"os"
+	"time"
 )
 
-func SendEmail(to, subject, body string) error {
-	from := os.Getenv("EMAIL_FROM")
-	pass := os.Getenv("EMAIL_APP_PASSWORD")
-
-	msg := "From: " + from + "\n" +
-		"To: " + to + "\n" +
-		"Subject: " + subject + "\n\n" +
-		body
+func productToResponse(p Product) ProductResponse {
+	effective, original := effectivePrice(p, time.Now())
 
-	return smtp.SendMail("smtp.gmail.com:587",
-		smtp.PlainAuth("", from, pass, "smtp.gmail.com"),
-		from, []string{to}, []byte(msg))
-}
+	variants := make([]ProductVariantResponse, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		variants = append(variants, variantToResponse(p.PriceCents, effective, v))
+	}
 
-func productToResponse(p Product) ProductResponse {
 	return ProductResponse{
-		ID:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       float64(p.PriceCents) / 100,
-		PriceCents:  p.PriceCents,
-		CategoryID:  p.CategoryID,
-		ImageURLs:   []string(p.ImageURLs),
-		Dimensions:  p.Dimensions,
-		IsActive:    p.IsActive,
-		IsAvailable: p.IsAvailable,
+		ID:                  p.ID,
+		Name:                p.Name,
+		Description:         p.Description,
+		Price:               float64(p.PriceCents) / 100,
+		PriceCents:          p.PriceCents,
+		EffectivePrice:      float64(effective) / 100,
+		EffectivePriceCents: effective,
+		OriginalPrice:       centsToFloatPtr(original),
+		OriginalPriceCents:  original,
+		OnSale:              original != nil,
+		CompareAtPrice:      centsToFloatPtr(p.CompareAtCents),
+		SalePrice:           centsToFloatPtr(p.SalePriceCents),
+		SaleStartsAt:        p.SaleStartsAt,
+		SaleEndsAt:          p.SaleEndsAt,
+		CategoryID:          p.CategoryID,
+		ImageURLs:           resolveImageURLs(p.ImageURLs),
+		Images:              imageSetsFromURLs(p.ImageURLs),
+		Dimensions:          p.Dimensions,
+		WidthCm:             p.WidthCm,
+		DepthCm:             p.DepthCm,
+		HeightCm:            p.HeightCm,
+		IsActive:            p.IsActive,
+		IsAvailable:         p.IsAvailable,
+		TrackStock:          p.TrackStock,
+		StockQty:            p.StockQty,
+		DepositPercent:      p.DepositPercent,
+		Variants:            variants,
 	}
 }
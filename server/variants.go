@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errVariantRequired    = errors.New("Selectați o variantă a produsului")
+	errVariantNotFound    = errors.New("Varianta selectată nu există")
+	errVariantUnavailable = errors.New("Varianta selectată nu este disponibilă")
+)
+
+// variantLabel descrie varianta pentru atelier, ex. "Velur / Gri / 3 locuri"
+func variantLabel(v ProductVariant) string {
+	parts := []string{}
+	if v.Fabric != "" {
+		parts = append(parts, v.Fabric)
+	}
+	if v.Color != "" {
+		parts = append(parts, v.Color)
+	}
+	if v.Seats > 0 {
+		parts = append(parts, fmt.Sprintf("%d locuri", v.Seats))
+	}
+	if v.Dimensions != "" {
+		parts = append(parts, v.Dimensions)
+	}
+	return strings.Join(parts, " / ")
+}
+
+func variantToResponse(basePriceCents, effectiveBaseCents int64, v ProductVariant) ProductVariantResponse {
+	priceCents := basePriceCents + v.PriceDeltaCents
+	effectiveCents := effectiveBaseCents + v.PriceDeltaCents
+	return ProductVariantResponse{
+		ID:                  v.ID,
+		SKU:                 v.SKU,
+		Fabric:              v.Fabric,
+		Color:               v.Color,
+		Seats:               v.Seats,
+		Dimensions:          v.Dimensions,
+		PriceDelta:          float64(v.PriceDeltaCents) / 100,
+		Price:               float64(priceCents) / 100,
+		PriceCents:          priceCents,
+		EffectivePrice:      float64(effectiveCents) / 100,
+		EffectivePriceCents: effectiveCents,
+		ImageURLs:           resolveImageURLs(v.ImageURLs),
+		Images:              imageSetsFromURLs(v.ImageURLs),
+		IsAvailable:         v.IsAvailable,
+		TrackStock:          v.TrackStock,
+		StockQty:            v.StockQty,
+	}
+}
+
+// resolveVariant verifică varianta aleasă pentru un produs.
+// Produsele fără variante acceptă doar variantID nil.
+func resolveVariant(db *gorm.DB, productID uint, variantID *uint) (*ProductVariant, error) {
+	if variantID == nil || *variantID == 0 {
+		var count int64
+		if err := db.Model(&ProductVariant{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errVariantRequired
+		}
+		return nil, nil
+	}
+
+	var variant ProductVariant
+	if err := db.Where("id = ? AND product_id = ?", *variantID, productID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errVariantNotFound
+		}
+		return nil, err
+	}
+	if !variant.IsAvailable {
+		return nil, errVariantUnavailable
+	}
+	return &variant, nil
+}
+
+func isVariantError(err error) bool {
+	return errors.Is(err, errVariantRequired) ||
+		errors.Is(err, errVariantNotFound) ||
+		errors.Is(err, errVariantUnavailable)
+}
+
+func validateVariantRequests(reqs []ProductVariantRequest) error {
+	seen := map[string]bool{}
+	for _, v := range reqs {
+		sku := strings.TrimSpace(v.SKU)
+		if sku == "" {
+			return errors.New("Fiecare variantă trebuie să aibă un SKU")
+		}
+		if seen[sku] {
+			return fmt.Errorf("SKU duplicat: %s", sku)
+		}
+		if v.StockQty != nil && *v.StockQty < 0 {
+			return fmt.Errorf("Stocul variantei %s nu poate fi negativ", sku)
+		}
+		if v.ID != 0 && v.StockQty != nil && v.ExpectedStockQty == nil {
+			return fmt.Errorf("Stocul variantei %s trebuie trimis împreună cu expected_stock_quantity", sku)
+		}
+		seen[sku] = true
+	}
+	return nil
+}
+
+// VariantSyncError e o problemă cu lista de variante trimisă din admin, arătată adminului
+type VariantSyncError struct {
+	Message string
+}
+
+func (e *VariantSyncError) Error() string { return e.Message }
+
+// syncProductVariants aduce variantele produsului la lista primită din admin:
+// actualizează variantele existente după ID, creează cele noi și le șterge pe cele lipsă.
+// Ordinea contează pentru indexul unic pe SKU: întâi ștergem variantele scoase și
+// eliberăm SKU-urile schimbate, abia apoi scriem, ca un SKU mutat de la o variantă
+// la alta (sau două SKU-uri inversate) să nu lovească indexul la jumătatea drumului.
+func syncProductVariants(tx *gorm.DB, productID uint, reqs []ProductVariantRequest) error {
+	ids := []uint{}
+	skus := []string{}
+	for _, req := range reqs {
+		if req.ID != 0 {
+			ids = append(ids, req.ID)
+		}
+		skus = append(skus, strings.TrimSpace(req.SKU))
+	}
+
+	var taken []string
+	if len(skus) > 0 {
+		if err := tx.Model(&ProductVariant{}).Where("sku IN ? AND product_id <> ?", skus, productID).
+			Pluck("sku", &taken).Error; err != nil {
+			return err
+		}
+	}
+	if len(taken) > 0 {
+		return &VariantSyncError{fmt.Sprintf("SKU-ul %s este folosit deja de alt produs", taken[0])}
+	}
+
+	// variantele existente sunt blocate, ca stocul lor să nu fie rezervat de o comandă
+	// între verificarea valorii așteptate și scriere
+	existing := map[uint]ProductVariant{}
+	if len(ids) > 0 {
+		var found []ProductVariant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND product_id = ?", ids, productID).Find(&found).Error; err != nil {
+			return err
+		}
+		for _, v := range found {
+			existing[v.ID] = v
+		}
+	}
+	for _, id := range ids {
+		if _, ok := existing[id]; !ok {
+			return &VariantSyncError{fmt.Sprintf("Varianta %d nu aparține produsului", id)}
+		}
+	}
+
+	removed := tx.Where("product_id = ?", productID)
+	if len(ids) > 0 {
+		removed = removed.Where("id NOT IN ?", ids)
+	}
+	if err := removed.Delete(&ProductVariant{}).Error; err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if v, ok := existing[req.ID]; ok && v.SKU != strings.TrimSpace(req.SKU) {
+			// SKU temporar, unic prin ID, până la scrierea celui nou mai jos
+			if err := tx.Model(&v).Update("sku", fmt.Sprintf("~%d", v.ID)).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, req := range reqs {
+		variant := existing[req.ID]
+
+		variant.ProductID = productID
+		variant.SKU = strings.TrimSpace(req.SKU)
+		variant.Fabric = req.Fabric
+		variant.Color = req.Color
+		variant.Seats = req.Seats
+		variant.Dimensions = req.Dimensions
+		variant.PriceDeltaCents = int64(math.Round(req.PriceDelta * 100))
+		variant.ImageURLs = pq.StringArray(req.ImageURLs)
+		variant.IsAvailable = req.IsAvailable
+		variant.TrackStock = req.TrackStock
+		oldStockQty := variant.StockQty
+		if req.StockQty != nil {
+			if variant.ID != 0 {
+				if err := checkExpectedStock(variant.SKU, oldStockQty, req.ExpectedStockQty); err != nil {
+					return err
+				}
+			}
+			variant.StockQty = *req.StockQty
+		}
+
+		if variant.ID == 0 {
+			if err := tx.Create(&variant).Error; err != nil {
+				return err
+			}
+			// default:true ar suprascrie false la creare
+			if !req.IsAvailable {
+				if err := tx.Model(&variant).Update("is_available", false).Error; err != nil {
+					return err
+				}
+			}
+		} else if err := tx.Save(&variant).Error; err != nil {
+			return err
+		}
+		if err := recordStockAdjustment(tx, productID, &variant.ID, oldStockQty, variant.StockQty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateVariantSKUIndex renunță la vechiul index unic pe SKU, care includea și
+// variantele șterse și bloca refolosirea SKU-urilor lor
+func migrateVariantSKUIndex() {
+	if !DB.Migrator().HasIndex(&ProductVariant{}, "idx_product_variants_sku") {
+		return
+	}
+	if err := DB.Migrator().DropIndex(&ProductVariant{}, "idx_product_variants_sku"); err != nil {
+		log.Println("Eroare la înlocuirea indexului pe SKU-ul variantelor:", err)
+	}
+}
+
+func sameVariant(a, b *uint) bool {
+	if a == nil || *a == 0 {
+		return b == nil || *b == 0
+	}
+	return b != nil && *a == *b
+}
+
+// whereVariant filtrează după variant_id, tratând lipsa variantei ca NULL
+func whereVariant(q *gorm.DB, variantID *uint) *gorm.DB {
+	if variantID == nil || *variantID == 0 {
+		return q.Where("variant_id IS NULL")
+	}
+	return q.Where("variant_id = ?", *variantID)
+}
+
+// variantIDFromQuery citește ?variantId= pentru rutele coșului de oaspete
+func variantIDFromQuery(raw string) *uint {
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		return nil
+	}
+	v := uint(id)
+	return &v
+}
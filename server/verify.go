@@ -20,23 +20,14 @@ func handleVerify(w http.ResponseWriter, r *http.Request) {
 
 	user.IsVerified = true
 	user.VerificationToken = ""
-	token, err := issueToken(user.ID, user.Email)
-	if err != nil {
-		httpError(w, http.StatusInternalServerError, "Eroare server")
+	if err := DB.Save(&user).Error; err != nil {
+		httpError(w, http.StatusInternalServerError, "Could not verify email")
 		return
 	}
 
-	// trimite tokenul în cookie HTTP-only
-	http.SetCookie(w, &http.Cookie{
-		Name:     "authToken",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-	})
-	if err := DB.Save(&user).Error; err != nil {
-		httpError(w, http.StatusInternalServerError, "Could not verify email")
+	// pornește sesiunea și trimite tokenurile în cookie HTTP-only
+	if err := startSession(w, r, user); err != nil {
+		httpError(w, http.StatusInternalServerError, "Eroare server")
 		return
 	}
 
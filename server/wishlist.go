@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// WishlistEntry e forma comună a favoritelor pentru utilizatori și oaspeți
+type WishlistEntry struct {
+	ProductID uint            `json:"productId"`
+	VariantID *uint           `json:"variantId"`
+	Product   Product         `json:"product"`
+	Variant   *ProductVariant `json:"variant,omitempty"`
+	AddedAt   time.Time       `json:"addedAt"`
+}
+
+// wishlistEntries întoarce favoritele, cele mai noi primele. Produsele șterse
+// sau dezactivate între timp nu mai apar.
+func wishlistEntries(r *http.Request) ([]WishlistEntry, error) {
+	entries := []WishlistEntry{}
+
+	if userID, ok := r.Context().Value(userIDKey).(uint); ok {
+		var items []WishlistItem
+		if err := DB.Preload("Product").Preload("Variant").
+			Where("user_id = ?", userID).
+			Order("created_at DESC, id DESC").
+			Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			if it.Product.ID == 0 || !it.Product.IsActive {
+				continue
+			}
+			entries = append(entries, WishlistEntry{
+				ProductID: it.ProductID,
+				VariantID: it.VariantID,
+				Product:   it.Product,
+				Variant:   it.Variant,
+				AddedAt:   it.CreatedAt,
+			})
+		}
+	} else {
+		items, _ := getGuestWishlist(r)
+		for i := len(items) - 1; i >= 0; i-- {
+			it := items[i]
+			var product Product
+			if err := DB.Where("id = ? AND is_active = ?", it.ProductID, true).First(&product).Error; err != nil {
+				continue
+			}
+			var variant *ProductVariant
+			if it.VariantID != nil {
+				var v ProductVariant
+				if err := DB.Where("id = ? AND product_id = ?", *it.VariantID, it.ProductID).First(&v).Error; err != nil {
+					continue
+				}
+				variant = &v
+			}
+			entries = append(entries, WishlistEntry{
+				ProductID: it.ProductID,
+				VariantID: it.VariantID,
+				Product:   product,
+				Variant:   variant,
+				AddedAt:   it.AddedAt,
+			})
+		}
+	}
+
+	for i := range entries {
+		resolveProductImages(&entries[i].Product)
+		withEffectivePrice(&entries[i].Product)
+	}
+	return entries, nil
+}
+
+// getWishlist: GET /api/wishlist
+func getWishlist(w http.ResponseWriter, r *http.Request) {
+	entries, err := wishlistEntries(r)
+	if err != nil {
+		http.Error(w, "Eroare la preluarea favoritelor", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// addToWishlist: POST /api/wishlist {productId, variantId?}. Adăugarea repetată nu dublează.
+func addToWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(uint)
+
+	var input struct {
+		ProductID uint  `json:"productId"`
+		VariantID *uint `json:"variantId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if input.VariantID != nil && *input.VariantID == 0 {
+		input.VariantID = nil
+	}
+
+	var product Product
+	if err := DB.Where("id = ? AND is_active = ?", input.ProductID, true).First(&product).Error; err != nil {
+		http.Error(w, "Produsul nu a fost găsit", http.StatusNotFound)
+		return
+	}
+	// varianta e opțională: clientul poate salva produsul și alege varianta la mutarea în coș
+	if input.VariantID != nil {
+		var count int64
+		DB.Model(&ProductVariant{}).Where("id = ? AND product_id = ?", *input.VariantID, input.ProductID).Count(&count)
+		if count == 0 {
+			http.Error(w, "Varianta nu a fost găsită", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !ok {
+		items, _ := getGuestWishlist(r)
+		exists := false
+		for _, it := range items {
+			if it.ProductID == input.ProductID && sameVariant(it.VariantID, input.VariantID) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			items = append(items, GuestWishlistItem{ProductID: input.ProductID, VariantID: input.VariantID, AddedAt: time.Now()})
+		}
+		saveGuestWishlist(w, items)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	var item WishlistItem
+	err := whereVariant(DB.Where("user_id = ? AND product_id = ?", userID, input.ProductID), input.VariantID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		item = WishlistItem{UserID: userID, ProductID: input.ProductID, VariantID: input.VariantID}
+		err = DB.Create(&item).Error
+	}
+	if err != nil {
+		http.Error(w, "Eroare la salvarea favoritelor", http.StatusInternalServerError)
+		return
+	}
+
+	DB.Preload("Product").Preload("Variant").First(&item, item.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// removeFromWishlist: DELETE /api/wishlist/{productId}?variantId=
+func removeFromWishlist(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(uint)
+	productID, err := strconv.Atoi(mux.Vars(r)["productId"])
+	if err != nil {
+		http.Error(w, "ID invalid", http.StatusBadRequest)
+		return
+	}
+	variantID := variantIDFromQuery(r.URL.Query().Get("variantId"))
+
+	if !ok {
+		items, _ := getGuestWishlist(r)
+		saveGuestWishlist(w, withoutGuestWishlistItem(items, uint(productID), variantID))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := whereVariant(DB.Where("user_id = ? AND product_id = ?", userID, productID), variantID).
+		Delete(&WishlistItem{}).Error; err != nil {
+		http.Error(w, "Eroare la ștergerea din favorite", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func withoutGuestWishlistItem(items []GuestWishlistItem, productID uint, variantID *uint) []GuestWishlistItem {
+	kept := []GuestWishlistItem{}
+	for _, it := range items {
+		if it.ProductID != productID || !sameVariant(it.VariantID, variantID) {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
+
+// moveWishlistToCart: POST /api/wishlist/{productId}/move-to-cart?variantId=
+// Corp opțional {quantity, variantId}; variantId din corp alege varianta când
+// produsul a fost salvat fără una.
+func moveWishlistToCart(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(uint)
+	productID, err := strconv.Atoi(mux.Vars(r)["productId"])
+	if err != nil {
+		http.Error(w, "ID invalid", http.StatusBadRequest)
+		return
+	}
+	savedVariantID := variantIDFromQuery(r.URL.Query().Get("variantId"))
+
+	var input struct {
+		Quantity  int   `json:"quantity"`
+		VariantID *uint `json:"variantId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if input.Quantity < 1 {
+		input.Quantity = 1
+	}
+	cartVariantID := savedVariantID
+	if input.VariantID != nil && *input.VariantID != 0 {
+		cartVariantID = input.VariantID
+	}
+
+	// intrarea trebuie să existe în favorite
+	var guestWishlist []GuestWishlistItem
+	if ok {
+		var count int64
+		whereVariant(DB.Model(&WishlistItem{}).Where("user_id = ? AND product_id = ?", userID, productID), savedVariantID).Count(&count)
+		if count == 0 {
+			http.Error(w, "Produsul nu este în favorite", http.StatusNotFound)
+			return
+		}
+	} else {
+		guestWishlist, _ = getGuestWishlist(r)
+		found := false
+		for _, it := range guestWishlist {
+			if it.ProductID == uint(productID) && sameVariant(it.VariantID, savedVariantID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "Produsul nu este în favorite", http.StatusNotFound)
+			return
+		}
+	}
+
+	var product Product
+	if err := DB.First(&product, productID).Error; err != nil || !product.IsActive || !product.IsAvailable {
+		http.Error(w, "Produsul nu mai este disponibil", http.StatusConflict)
+		return
+	}
+	variant, err := resolveVariant(DB, product.ID, cartVariantID)
+	if err != nil {
+		if isVariantError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Eroare la verificarea variantei", http.StatusInternalServerError)
+		return
+	}
+	cartVariantID = nil
+	if variant != nil {
+		cartVariantID = &variant.ID
+	}
+	priceCents := cartUnitPrice(product, variant, time.Now())
+
+	if !ok {
+		cart, _ := getGuestCart(r)
+		cart = addGuestCartItem(cart, product.ID, cartVariantID, input.Quantity, priceCents)
+		if len(cart) > maxGuestCartItems {
+			// produsul rămâne în favorite dacă nu încape în coș
+			http.Error(w, fmt.Sprintf("Coșul poate conține cel mult %d produse", maxGuestCartItems), http.StatusConflict)
+			return
+		}
+		saveGuestCart(w, cart)
+		saveGuestWishlist(w, withoutGuestWishlistItem(guestWishlist, product.ID, savedVariantID))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cart)
+		return
+	}
+
+	var item CartItem
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if item, err = addUserCartItem(tx, userID, product.ID, cartVariantID, input.Quantity, priceCents); err != nil {
+			return err
+		}
+		return whereVariant(tx.Where("user_id = ? AND product_id = ?", userID, product.ID), savedVariantID).
+			Delete(&WishlistItem{}).Error
+	})
+	if err != nil {
+		log.Printf("Eroare la mutarea produsului %d în coș pentru user %d: %v", product.ID, userID, err)
+		http.Error(w, "Eroare la mutarea în coș", http.StatusInternalServerError)
+		return
+	}
+
+	DB.Preload("Product").Preload("Variant").First(&item, item.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// mergeGuestWishlistToUser mută favoritele din cookie în cont, la autentificare
+func mergeGuestWishlistToUser(w http.ResponseWriter, r *http.Request, userID uint) {
+	items, err := getGuestWishlist(r)
+	if err != nil || len(items) == 0 {
+		return
+	}
+
+	for _, it := range items {
+		var count int64
+		if err := DB.Model(&Product{}).Where("id = ?", it.ProductID).Count(&count).Error; err != nil || count == 0 {
+			continue
+		}
+		var existing WishlistItem
+		err := whereVariant(DB.Where("user_id = ? AND product_id = ?", userID, it.ProductID), it.VariantID).First(&existing).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		item := WishlistItem{UserID: userID, ProductID: it.ProductID, VariantID: it.VariantID, CreatedAt: it.AddedAt}
+		if err := DB.Create(&item).Error; err != nil {
+			log.Printf("Eroare la mutarea favoritului %d pentru user %d: %v", it.ProductID, userID, err)
+		}
+	}
+
+	saveGuestWishlist(w, []GuestWishlistItem{})
+}